@@ -17,10 +17,15 @@ type Entry struct {
 	Command    string              `json:"command"`
 	Question   string              `json:"question"`
 	Model      string              `json:"model"`
+	Variant    string              `json:"variant,omitempty"`
+	Section    string              `json:"section,omitempty"`
+	Platform   string              `json:"platform,omitempty"`
 	Response   *llm.QueryResponse  `json:"response"`
 	CreatedAt  time.Time           `json:"created_at"`
 	AccessedAt time.Time           `json:"accessed_at"`
 	AccessCount int                `json:"access_count"`
+	Rating     string              `json:"rating,omitempty"` // RatingGood once rated with heyman feedback
+	Pinned     bool                `json:"pinned,omitempty"` // Pinned entries never expire
 }
 
 // Cache manages response caching
@@ -38,9 +43,8 @@ func New(maxAgeDays int) *Cache {
 }
 
 // Get retrieves a cached response
-func (c *Cache) Get(command, question, model string) (*llm.QueryResponse, bool) {
-	key := GenerateKey(command, question, model)
-	entryPath := filepath.Join(c.cacheDir, key+".json")
+func (c *Cache) Get(key Key) (*llm.QueryResponse, bool) {
+	entryPath := filepath.Join(c.cacheDir, key.Hash()+".json")
 
 	// Check if cached file exists
 	data, err := os.ReadFile(entryPath)
@@ -54,7 +58,7 @@ func (c *Cache) Get(command, question, model string) (*llm.QueryResponse, bool)
 	}
 
 	// Check if entry has expired
-	if c.isExpired(entry.CreatedAt) {
+	if !entry.Pinned && c.isExpired(entry.CreatedAt) {
 		// Delete expired entry
 		os.Remove(entryPath)
 		return nil, false
@@ -79,15 +83,31 @@ func (c *Cache) Get(command, question, model string) (*llm.QueryResponse, bool)
 	return response, true
 }
 
-// Set stores a response in the cache
-func (c *Cache) Set(command, question, model string, response *llm.QueryResponse) error {
-	key := GenerateKey(command, question, model)
+// Has reports whether a response is cached for the key, without recording an
+// access or removing expired entries
+func (c *Cache) Has(key Key) bool {
+	data, err := os.ReadFile(filepath.Join(c.cacheDir, key.Hash()+".json"))
+	if err != nil {
+		return false
+	}
 
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Response == nil {
+		return false
+	}
+	return entry.Pinned || !c.isExpired(entry.CreatedAt)
+}
+
+// Set stores a response in the cache
+func (c *Cache) Set(key Key, response *llm.QueryResponse) error {
 	entry := &Entry{
-		Key:         key,
-		Command:     command,
-		Question:    question,
-		Model:       model,
+		Key:         key.Hash(),
+		Command:     key.Command,
+		Question:    key.Question,
+		Model:       key.Model,
+		Variant:     key.Variant,
+		Section:     key.Section,
+		Platform:    key.Platform,
 		Response:    response,
 		CreatedAt:   time.Now(),
 		AccessedAt:  time.Now(),
@@ -153,7 +173,7 @@ func (c *Cache) CleanExpired() (int, error) {
 				continue
 			}
 
-			if c.isExpired(cacheEntry.CreatedAt) {
+			if !cacheEntry.Pinned && c.isExpired(cacheEntry.CreatedAt) {
 				if err := os.Remove(entryPath); err == nil {
 					removed++
 				}
@@ -194,6 +214,9 @@ type Stats struct {
 	OldestEntry  *time.Time `json:"oldest_entry,omitempty"`
 	NewestEntry  *time.Time `json:"newest_entry,omitempty"`
 	TotalHits    int        `json:"total_hits"`
+	Pinned       int        `json:"pinned"`
+	RatedGood    int        `json:"rated_good"` // From the ratings log, including entries since cleared
+	RatedBad     int        `json:"rated_bad"`  // Bad entries are evicted, so only the log counts them
 }
 
 // GetStats returns cache statistics
@@ -207,6 +230,7 @@ func (c *Cache) GetStats() (*Stats, error) {
 	}
 
 	stats := &Stats{}
+	stats.RatedGood, stats.RatedBad = c.countRatings()
 
 	for _, entry := range entries {
 		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
@@ -234,6 +258,9 @@ func (c *Cache) GetStats() (*Stats, error) {
 		}
 
 		stats.TotalHits += cacheEntry.AccessCount
+		if cacheEntry.Pinned {
+			stats.Pinned++
+		}
 
 		if stats.OldestEntry == nil || cacheEntry.CreatedAt.Before(*stats.OldestEntry) {
 			stats.OldestEntry = &cacheEntry.CreatedAt
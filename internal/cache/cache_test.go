@@ -0,0 +1,170 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecf/heyman/internal/llm"
+)
+
+func testCache(t *testing.T) *Cache {
+	t.Helper()
+	t.Setenv("HEYMAN_CACHE_DIR", t.TempDir())
+	return New(30)
+}
+
+func TestVariants(t *testing.T) {
+	c := testCache(t)
+	if _, ok := c.GetVariant("/usr/bin/sed"); ok {
+		t.Fatal("variant found before any was recorded")
+	}
+
+	if err := c.SetVariant("/usr/bin/sed", "gnu"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetVariant("/usr/bin/jq", ""); err != nil {
+		t.Fatal(err)
+	}
+	if variant, ok := New(30).GetVariant("/usr/bin/sed"); !ok || variant != "gnu" {
+		t.Errorf("GetVariant(sed) = %q, %v; want gnu", variant, ok)
+	}
+	if variant, ok := c.GetVariant("/usr/bin/jq"); !ok || variant != "" {
+		t.Errorf("GetVariant(jq) = %q, %v; want an inconclusive probe remembered", variant, ok)
+	}
+
+	// Stale records are ignored
+	records := c.readVariants()
+	records["/usr/bin/sed"] = variantRecord{Variant: "gnu", CheckedAt: time.Now().Add(-variantTTL - time.Hour)}
+	if err := c.writeFile(variantsFile, records); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.GetVariant("/usr/bin/sed"); ok {
+		t.Error("stale variant still trusted")
+	}
+}
+
+// storeAnswer caches a response for a question to ls and returns its entry
+func storeAnswer(t *testing.T, c *Cache, question, command string) *Entry {
+	t.Helper()
+	key := Key{Command: "ls", Question: question, Model: "m", Variant: "alternatives=2", Section: "1"}
+	if err := c.Set(key, &llm.QueryResponse{Content: command}); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := c.Lookup(key.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestLookup(t *testing.T) {
+	c := testCache(t)
+	if _, err := c.Lookup("abc"); err == nil {
+		t.Error("Lookup in an empty cache succeeded")
+	}
+
+	entry := storeAnswer(t, c, "list files", "ls -l")
+	if entry.Question != "list files" || entry.Variant != "alternatives=2" || entry.Section != "1" {
+		t.Errorf("entry = %+v, want the question, variant and section recorded", entry)
+	}
+	found, err := c.Lookup(entry.Key[:8])
+	if err != nil || found.Key != entry.Key {
+		t.Errorf("Lookup(prefix) = %v, %v; want the entry", found, err)
+	}
+
+	storeAnswer(t, c, "show hidden files", "ls -a")
+	if _, err := c.Lookup(""); err == nil {
+		t.Error("Lookup(\"\") succeeded")
+	}
+	// Every hex key starts with one of 16 digits, so with many entries some
+	// one-digit prefix is shared
+	for i := range 32 {
+		storeAnswer(t, c, strings.Repeat("?", i+1), "ls")
+	}
+	ambiguous := false
+	for _, digit := range "0123456789abcdef" {
+		if _, err := c.Lookup(string(digit)); err != nil && strings.Contains(err.Error(), "ambiguous") {
+			ambiguous = true
+		}
+	}
+	if !ambiguous {
+		t.Error("no one-digit prefix reported ambiguous")
+	}
+}
+
+func TestRateGoodPinsEntry(t *testing.T) {
+	c := testCache(t)
+	entry := storeAnswer(t, c, "list files", "ls -l")
+
+	if err := c.Rate(entry, RatingGood, "ls -l", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pinned entries outlive the cache's age limit
+	entry.CreatedAt = time.Now().AddDate(0, 0, -60)
+	if err := c.saveEntry(entry); err != nil {
+		t.Fatal(err)
+	}
+	key := Key{Command: "ls", Question: "list files", Model: "m", Variant: "alternatives=2"}
+	if resp, ok := c.Get(key); !ok || resp.Content != "ls -l" {
+		t.Errorf("Get after rating good = %v, %v; want the pinned answer", resp, ok)
+	}
+
+	stats, err := c.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pinned != 1 || stats.RatedGood != 1 || stats.RatedBad != 0 {
+		t.Errorf("stats = %+v, want one pinned entry rated good", stats)
+	}
+}
+
+func TestRateBadEvictsEntry(t *testing.T) {
+	c := testCache(t)
+	entry := storeAnswer(t, c, "list files", "ls -l")
+
+	if err := c.Rate(entry, RatingBad, "ls -l", "not sorted"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Lookup(entry.Key); err == nil {
+		t.Error("entry still cached after rating bad")
+	}
+	// Rating an already evicted entry still records the rating
+	if err := c.Rate(entry, RatingBad, "ls -l", ""); err != nil {
+		t.Errorf("rating an evicted entry: %v", err)
+	}
+	if err := c.Rate(entry, "meh", "ls -l", ""); err == nil {
+		t.Error("invalid rating accepted")
+	}
+
+	stats, err := c.GetStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.RatedBad != 2 || stats.RatedGood != 0 {
+		t.Errorf("stats = %+v, want two bad ratings logged", stats)
+	}
+}
+
+func TestLast(t *testing.T) {
+	c := testCache(t)
+	if _, err := c.GetLast(); err == nil {
+		t.Error("GetLast succeeded before any answer was shown")
+	}
+
+	want := Last{Key: "3f9a2c", Section: "1", Answer: "ls -l"}
+	if err := c.SetLast(want); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetLast(Last{Key: "b71e05", Answer: "ls -a"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := New(30).GetLast()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != (Last{Key: "b71e05", Answer: "ls -a"}) {
+		t.Errorf("GetLast = %+v, want the most recent answer", got)
+	}
+}
@@ -5,6 +5,32 @@ import (
 	"fmt"
 )
 
+// Key identifies a cached response
+type Key struct {
+	Command  string
+	Question string
+	Model    string
+	Variant  string // Distinguishes other answer shapes (e.g. "alternatives=3"); empty for a single command
+	Platform string // OS and command implementation (e.g. "darwin/bsd"), so answers are never shared across platforms
+	Section  string // Man page section asked about; recorded with the entry but not hashed
+}
+
+// NewKey creates a key for the default single-command answer
+func NewKey(command, question, model string) Key {
+	return Key{Command: command, Question: question, Model: model}
+}
+
+// Hash returns the file name stem for the key. Keys without a variant or
+// platform hash the same way they always have.
+func (k Key) Hash() string {
+	if k.Variant == "" && k.Platform == "" {
+		return GenerateKey(k.Command, k.Question, k.Model)
+	}
+	data := fmt.Sprintf("%s:%s:%s:%s:%s", k.Command, k.Question, k.Model, k.Variant, k.Platform)
+	hash := sha256.Sum256([]byte(data))
+	return fmt.Sprintf("%x", hash)
+}
+
 // GenerateKey creates a SHA-256 hash key for caching
 // Key is based on: command + question + model_id
 func GenerateKey(command, question, model string) string {
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alecf/heyman/internal/llm"
+)
+
+// modelsFile records what providers reported about models, so they need not
+// be asked on every query. Like the ratings log it has no .json extension.
+const modelsFile = "models"
+
+// modelInfoTTL is how long reported model information is trusted; models can
+// be re-pulled or recreated with a different Modelfile
+const modelInfoTTL = 24 * time.Hour
+
+// modelRecord is reported model information and when it was reported
+type modelRecord struct {
+	llm.ModelInfo
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// GetModelInfo returns the information recorded for a model (keyed by server
+// and model name) if it was recorded within the last day
+func (c *Cache) GetModelInfo(key string) (*llm.ModelInfo, bool) {
+	record, ok := c.readModels()[key]
+	if !ok || time.Since(record.DetectedAt) > modelInfoTTL {
+		return nil, false
+	}
+	return &record.ModelInfo, true
+}
+
+// SetModelInfo records information reported about a model
+func (c *Cache) SetModelInfo(key string, info llm.ModelInfo) error {
+	records := c.readModels()
+	records[key] = modelRecord{ModelInfo: info, DetectedAt: time.Now()}
+
+	// Drop stale records so the file doesn't grow with every model ever used
+	for k, record := range records {
+		if time.Since(record.DetectedAt) > modelInfoTTL {
+			delete(records, k)
+		}
+	}
+
+	return c.writeFile(modelsFile, records)
+}
+
+// readModels reads the recorded model information; a missing or corrupt file
+// reads as empty
+func (c *Cache) readModels() map[string]modelRecord {
+	records := make(map[string]modelRecord)
+	data, err := os.ReadFile(filepath.Join(c.cacheDir, modelsFile))
+	if err != nil {
+		return records
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return make(map[string]modelRecord)
+	}
+	return records
+}
+
+// serversFile records which optional endpoints servers offer
+const serversFile = "servers"
+
+// serverRecord is whether a server offers an endpoint and when that was checked
+type serverRecord struct {
+	Supported bool      `json:"supported"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// GetServerSupport returns whether a server was found to offer an endpoint
+// (keyed by server and endpoint) within the last day; ok is false if it
+// hasn't been checked
+func (c *Cache) GetServerSupport(key string) (supported, ok bool) {
+	record, ok := c.readServers()[key]
+	if !ok || time.Since(record.CheckedAt) > modelInfoTTL {
+		return false, false
+	}
+	return record.Supported, true
+}
+
+// SetServerSupport records whether a server offers an endpoint
+func (c *Cache) SetServerSupport(key string, supported bool) error {
+	records := c.readServers()
+	records[key] = serverRecord{Supported: supported, CheckedAt: time.Now()}
+	for k, record := range records {
+		if time.Since(record.CheckedAt) > modelInfoTTL {
+			delete(records, k)
+		}
+	}
+	return c.writeFile(serversFile, records)
+}
+
+// readServers reads the recorded server endpoints; a missing or corrupt file
+// reads as empty
+func (c *Cache) readServers() map[string]serverRecord {
+	records := make(map[string]serverRecord)
+	data, err := os.ReadFile(filepath.Join(c.cacheDir, serversFile))
+	if err != nil {
+		return records
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return make(map[string]serverRecord)
+	}
+	return records
+}
+
+// variantsFile records which implementation (GNU, BSD, BusyBox) "--version"
+// probes found installed executables to be
+const variantsFile = "variants"
+
+// variantTTL is how long a probed variant is trusted; a binary only changes
+// variant when it is replaced by a different implementation at the same path
+const variantTTL = 30 * 24 * time.Hour
+
+// variantRecord is the variant probed for an executable ("" if inconclusive)
+// and when it was probed
+type variantRecord struct {
+	Variant   string    `json:"variant"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// GetVariant returns the variant recorded for an executable path within the
+// last 30 days; ok is false if it hasn't been probed
+func (c *Cache) GetVariant(path string) (variant string, ok bool) {
+	record, ok := c.readVariants()[path]
+	if !ok || time.Since(record.CheckedAt) > variantTTL {
+		return "", false
+	}
+	return record.Variant, true
+}
+
+// SetVariant records the variant probed for an executable path
+func (c *Cache) SetVariant(path, variant string) error {
+	records := c.readVariants()
+	records[path] = variantRecord{Variant: variant, CheckedAt: time.Now()}
+	for k, record := range records {
+		if time.Since(record.CheckedAt) > variantTTL {
+			delete(records, k)
+		}
+	}
+	return c.writeFile(variantsFile, records)
+}
+
+// readVariants reads the recorded variants; a missing or corrupt file reads
+// as empty
+func (c *Cache) readVariants() map[string]variantRecord {
+	records := make(map[string]variantRecord)
+	data, err := os.ReadFile(filepath.Join(c.cacheDir, variantsFile))
+	if err != nil {
+		return records
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return make(map[string]variantRecord)
+	}
+	return records
+}
+
+// writeFile writes records as JSON to a file in the cache directory. It writes
+// then renames, so concurrent readers never see a partial file.
+func (c *Cache) writeFile(name string, records any) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	if err := os.MkdirAll(c.cacheDir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(c.cacheDir, name+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.cacheDir, name)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
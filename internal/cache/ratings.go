@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Ratings given with heyman feedback
+const (
+	RatingGood = "good"
+	RatingBad  = "bad"
+)
+
+// ratingsLogFile records every rating, including those of evicted entries.
+// It has no .json extension so it is never mistaken for an entry.
+const ratingsLogFile = "ratings.log"
+
+// lastFile points at the most recent answer, for heyman feedback --last
+const lastFile = "last"
+
+// Rating is one line of the ratings log
+type Rating struct {
+	Key      string    `json:"key"`
+	Command  string    `json:"command"`
+	Question string    `json:"question"`
+	Model    string    `json:"model"`
+	Answer   string    `json:"answer,omitempty"`
+	Rating   string    `json:"rating"`
+	Reason   string    `json:"reason,omitempty"`
+	RatedAt  time.Time `json:"rated_at"`
+}
+
+// Last identifies the most recently shown answer
+type Last struct {
+	Key     string `json:"key"`
+	Section string `json:"section,omitempty"`
+	Answer  string `json:"answer"` // The command shown (the first, for alternatives)
+}
+
+// Lookup finds an entry by its key or a unique prefix of it, as shown by
+// heyman history and heyman feedback
+func (c *Cache) Lookup(key string) (*Entry, error) {
+	if key == "" {
+		return nil, fmt.Errorf("no cache key given")
+	}
+
+	entries, err := os.ReadDir(c.cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no cache entry matches %q", key)
+		}
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	var match string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" || !strings.HasPrefix(name, key) {
+			continue
+		}
+		if match != "" {
+			return nil, fmt.Errorf("cache key %q is ambiguous", key)
+		}
+		match = name
+	}
+	if match == "" {
+		return nil, fmt.Errorf("no cache entry matches %q (it may have expired)", key)
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.cacheDir, match))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse cache entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// Rate records a rating for an entry. Good answers are pinned so they never
+// expire; bad answers are evicted so the next query asks the model again.
+// answer is the command the user saw, recorded in the ratings log.
+func (c *Cache) Rate(entry *Entry, rating, answer, reason string) error {
+	switch rating {
+	case RatingGood:
+		entry.Rating = RatingGood
+		entry.Pinned = true
+		if err := c.saveEntry(entry); err != nil {
+			return err
+		}
+	case RatingBad:
+		if err := os.Remove(filepath.Join(c.cacheDir, entry.Key+".json")); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to evict cache entry: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid rating %q: must be %s or %s", rating, RatingGood, RatingBad)
+	}
+
+	return c.appendRating(Rating{
+		Key:      entry.Key,
+		Command:  entry.Command,
+		Question: entry.Question,
+		Model:    entry.Model,
+		Answer:   answer,
+		Rating:   rating,
+		Reason:   reason,
+		RatedAt:  time.Now(),
+	})
+}
+
+// appendRating adds a line to the ratings log
+func (c *Cache) appendRating(rating Rating) error {
+	if err := os.MkdirAll(c.cacheDir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(rating)
+	if err != nil {
+		return fmt.Errorf("failed to marshal rating: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(c.cacheDir, ratingsLogFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open ratings log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write rating: %w", err)
+	}
+	return nil
+}
+
+// countRatings counts the good and bad ratings in the log
+func (c *Cache) countRatings() (good, bad int) {
+	f, err := os.Open(filepath.Join(c.cacheDir, ratingsLogFile))
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rating Rating
+		if err := json.Unmarshal(scanner.Bytes(), &rating); err != nil {
+			continue
+		}
+		switch rating.Rating {
+		case RatingGood:
+			good++
+		case RatingBad:
+			bad++
+		}
+	}
+	return good, bad
+}
+
+// SetLast remembers the most recently shown answer
+func (c *Cache) SetLast(last Last) error {
+	if err := os.MkdirAll(c.cacheDir, 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(last)
+	if err != nil {
+		return fmt.Errorf("failed to marshal last answer: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(c.cacheDir, lastFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write last answer: %w", err)
+	}
+	return nil
+}
+
+// GetLast returns the most recently shown answer
+func (c *Cache) GetLast() (*Last, error) {
+	data, err := os.ReadFile(filepath.Join(c.cacheDir, lastFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no previous answer to rate")
+		}
+		return nil, fmt.Errorf("failed to read last answer: %w", err)
+	}
+
+	var last Last
+	if err := json.Unmarshal(data, &last); err != nil {
+		return nil, fmt.Errorf("failed to parse last answer: %w", err)
+	}
+	return &last, nil
+}
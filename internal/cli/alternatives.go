@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/llm"
+	"github.com/alecf/heyman/internal/output"
+	"github.com/alecf/heyman/internal/parser"
+	"github.com/alecf/heyman/internal/pricing"
+	"github.com/spf13/cobra"
+)
+
+// maxAlternatives caps --alternatives to keep responses within MaxTokens
+const maxAlternatives = 5
+
+// outputAlternatives prints alternative commands as a numbered list (or JSON
+// array) and, with --copy, asks which one to copy and returns it
+func outputAlternatives(cmd *cobra.Command, alternatives []parser.ParsedResponse, resp *llm.QueryResponse, activeProfile *config.Profile) (copied string, err error) {
+	jsonFlag, _ := cmd.Flags().GetBool("json")
+	tokensFlag, _ := cmd.Flags().GetBool("tokens")
+	copyFlag, _ := cmd.Flags().GetBool("copy")
+	explainFlag, _ := cmd.Flags().GetBool("explain")
+
+	if jsonFlag {
+		jsonOutput, err := output.FormatJSONAlternatives(alternatives, resp, estimateCost(activeProfile.Model, resp.TokensInput, resp.TokensOutput))
+		if err != nil {
+			return "", fmt.Errorf("failed to format JSON: %w", err)
+		}
+		fmt.Println(jsonOutput)
+	} else {
+		fmt.Print(output.FormatAlternatives(alternatives, explainFlag))
+
+		if tokensFlag {
+			fmt.Println()
+			pricingDB := pricing.GetDatabase()
+			modelPricing := pricingDB.GetPricing(activeProfile.Model)
+			fmt.Println(pricing.FormatTokenUsage(resp.TokensInput, resp.TokensOutput, modelPricing, pricingDB.LastUpdated))
+		}
+	}
+
+	if copyFlag {
+		choice, err := chooseAlternative(len(alternatives))
+		if err != nil {
+			return "", err
+		}
+		if err := output.CopyToClipboard(alternatives[choice].Command); err != nil {
+			return "", fmt.Errorf("failed to copy to clipboard: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "✓ Copied %d to clipboard\n", choice+1)
+		copied = alternatives[choice].Command
+	}
+
+	return copied, nil
+}
+
+// chooseAlternative asks which of n alternatives to use and returns its
+// 0-based index. The prompt goes to stderr so JSON output stays clean; an
+// empty answer picks the first alternative.
+func chooseAlternative(n int) (int, error) {
+	if n == 1 {
+		return 0, nil
+	}
+
+	fmt.Fprintf(os.Stderr, "\nCopy which command? [1-%d, default 1]: ", n)
+
+	var choice string
+	fmt.Scanln(&choice)
+
+	choice = strings.TrimSpace(choice)
+	if choice == "" {
+		return 0, nil
+	}
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > n {
+		return 0, fmt.Errorf("invalid choice: must be between 1 and %d", n)
+	}
+	return index - 1, nil
+}
@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/alecf/heyman/internal/output"
+	"github.com/alecf/heyman/internal/pricing"
+	"github.com/spf13/cobra"
+)
+
+// batchQuestion is one question read from a batch input
+type batchQuestion struct {
+	Line  int // 1-based line in the input
+	Input output.BatchInput
+	Err   error // Why the line could not be read as a question
+}
+
+func batchCmd() *cobra.Command {
+	var workers int
+	var explain bool
+
+	cmd := &cobra.Command{
+		Use:   "batch [file]",
+		Short: "Answer many questions from a file or stdin",
+		Long: `Answer every question in a file (or stdin, if no file or "-" is given),
+one per line, and print the results as JSON lines in input order.
+
+Each line is either "<command> <question>", with an optional section as on
+the command line ("3 printf how do I pad with zeros"), or a JSON object:
+  {"command": "tar", "section": "1", "question": "extract a .tar.gz"}
+Blank lines and lines starting with # are skipped.
+
+Each result has the input line number, the question, and either the answer
+(as heyman --json prints it) or the error for that question; one failed
+question does not stop the rest. Questions use the active profile, its
+fallbacks and the cache, and each man page is fetched once.
+
+Example:
+  heyman batch questions.txt --workers 8 > answers.jsonl`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if workers < 1 {
+				return fmt.Errorf("--workers must be at least 1")
+			}
+
+			input := cmd.InOrStdin()
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return fmt.Errorf("failed to open batch file: %w", err)
+				}
+				defer f.Close()
+				input = f
+			}
+			questions, err := readBatch(input)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			activeProfile, err := cfg.GetActiveProfile()
+			if err != nil {
+				return fmt.Errorf("no profile configured: %w\nRun 'heyman setup' to configure", err)
+			}
+			fallbacks, err := cfg.FallbackChain(activeProfile)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			// Progress and verbose output would be interleaved with the results.
+			// Commands come from the file, so none is run with --version.
+			pipe := &pipeline{
+				cfg:       cfg,
+				explain:   explain,
+				noCache:   noCache,
+				platforms: new(sync.Map),
+				providers: new(sync.Map),
+			}
+			failed := runBatch(ctx, pipe, activeProfile, fallbacks, questions, workers, os.Stdout)
+
+			fmt.Fprintf(os.Stderr, "Answered %d of %d questions", len(questions)-failed, len(questions))
+			if failed > 0 {
+				fmt.Fprintf(os.Stderr, " (%d failed)", failed)
+			}
+			fmt.Fprintln(os.Stderr)
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&workers, "workers", "w", 4, "number of questions to answer at once")
+	cmd.Flags().BoolVarP(&explain, "explain", "e", false, "include explanations")
+
+	return cmd
+}
+
+// readBatch reads the questions from a batch input. Lines that are not valid
+// questions are returned with an error rather than failing the whole batch.
+func readBatch(r io.Reader) ([]batchQuestion, error) {
+	var questions []batchQuestion
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		question := batchQuestion{Line: line}
+		if strings.HasPrefix(text, "{") {
+			if err := json.Unmarshal([]byte(text), &question.Input); err != nil {
+				question.Err = fmt.Errorf("invalid JSON: %w", err)
+			}
+		} else {
+			command, section, questionParts := manpage.ParseCommand(strings.Fields(text))
+			question.Input = output.BatchInput{
+				Command:  command,
+				Section:  section,
+				Question: strings.Join(questionParts, " "),
+			}
+		}
+
+		if question.Err == nil {
+			if question.Input.Command == "" {
+				question.Err = fmt.Errorf("no command specified")
+			} else if question.Input.Question == "" {
+				question.Err = fmt.Errorf("no question specified")
+			}
+		}
+		questions = append(questions, question)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read batch input: %w", err)
+	}
+
+	return questions, nil
+}
+
+// runBatch answers the questions with a pool of workers, writing each result
+// to w as a JSON line in input order as soon as it and those before it are
+// done. It returns the number of questions that failed.
+func runBatch(ctx context.Context, pipe *pipeline, profile *config.Profile, fallbacks []*config.Profile, questions []batchQuestion, workers int, w io.Writer) int {
+	type result struct {
+		line string
+		ok   bool
+	}
+	results := make([]chan result, len(questions))
+	for i := range results {
+		results[i] = make(chan result, 1)
+	}
+
+	jobs := make(chan int)
+	go func() {
+		defer close(jobs)
+		for i := range questions {
+			jobs <- i
+		}
+	}()
+
+	for range min(workers, len(questions)) {
+		go func() {
+			for i := range jobs {
+				line, ok := answerBatchQuestion(ctx, pipe, profile, fallbacks, questions[i])
+				results[i] <- result{line, ok}
+			}
+		}()
+	}
+
+	failed := 0
+	for _, ch := range results {
+		result := <-ch
+		fmt.Fprintln(w, result.line)
+		if !result.ok {
+			failed++
+		}
+	}
+	return failed
+}
+
+// answerBatchQuestion answers one batch question and formats the result,
+// reporting whether it was answered
+func answerBatchQuestion(ctx context.Context, pipe *pipeline, profile *config.Profile, fallbacks []*config.Profile, question batchQuestion) (string, bool) {
+	input := question.Input
+
+	fail := func(err error) (string, bool) {
+		line, _ := output.FormatBatchError(question.Line, input, err)
+		return line, false
+	}
+	if question.Err != nil {
+		return fail(question.Err)
+	}
+
+	manPageContent, err := fetchManPage(input.Command, input.Section)
+	if err != nil {
+		return fail(err)
+	}
+
+	result, err := pipe.answer(ctx, profile, fallbacks, input.Command, input.Section, manPageContent, input.Question)
+	if err != nil {
+		return fail(err)
+	}
+
+	var costPtr *float64
+	if modelPricing := pricing.GetDatabase().GetPricing(result.Provider.Profile.Model); modelPricing != nil {
+		cost := modelPricing.CalculateCost(result.Response.TokensInput, result.Response.TokensOutput)
+		costPtr = &cost
+	}
+	line, err := output.FormatBatchAnswer(question.Line, input, result.Parsed, result.Response, costPtr)
+	if err != nil {
+		return fail(err)
+	}
+	return line, true
+}
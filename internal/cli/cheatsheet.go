@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/examples"
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/alecf/heyman/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// maxCheatsheetTasks caps the tasks a cheat sheet can ask for
+const maxCheatsheetTasks = 20
+
+func cheatsheetCmd() *cobra.Command {
+	var format string
+	var count int
+
+	cmd := &cobra.Command{
+		Use:   "cheatsheet [section] <command>",
+		Short: "Generate a cheat sheet of a command's most useful tasks",
+		Long: `Ask for the most useful tasks a command's man page documents, each with a
+validated command, and print them as Markdown or as a tldr page.
+
+Cheat sheets are cached like answers. Each task is also added to the examples
+used in future prompts about the command.
+
+Example:
+  heyman cheatsheet rsync
+  heyman cheatsheet --format tldr --count 10 tar > tar.md`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format != "markdown" && format != "tldr" {
+				return fmt.Errorf("invalid format %q: must be markdown or tldr", format)
+			}
+			if count < 1 || count > maxCheatsheetTasks {
+				return fmt.Errorf("--count must be between 1 and %d", maxCheatsheetTasks)
+			}
+
+			command, section, rest := manpage.ParseCommand(args)
+			if len(rest) > 0 {
+				return fmt.Errorf("unexpected argument %q: cheat sheets cover one command", rest[0])
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			activeProfile, err := cfg.GetActiveProfile()
+			if err != nil {
+				return fmt.Errorf("no profile configured: %w\nRun 'heyman setup' to configure", err)
+			}
+			fallbacks, err := cfg.FallbackChain(activeProfile)
+			if err != nil {
+				return err
+			}
+
+			manPageContent, err := fetchManPage(command, section)
+			if err != nil {
+				return err
+			}
+
+			pipe := newPipeline(cfg, false)
+			pipe.cheatsheet = count
+			question := fmt.Sprintf("What are the %d most useful things to do with %s?", count, command)
+			result, err := pipe.answer(cmd.Context(), activeProfile, fallbacks, command, section, manPageContent, question)
+			if err != nil {
+				return err
+			}
+
+			// Freshly generated tasks become examples; cached ones already are
+			if !result.Response.Cached {
+				library := examples.Open()
+				for _, entry := range result.Entries {
+					if entry.Task == "" {
+						continue
+					}
+					err := library.Add(examples.Example{
+						Command:  command,
+						Question: entry.Task,
+						Answer:   entry.Command,
+						Platform: result.Platform.Key(),
+						Source:   examples.SourceCheatsheet,
+					})
+					if err != nil && verbose {
+						fmt.Printf("Warning: failed to save example: %v\n", err)
+					}
+				}
+			}
+
+			description := manpage.Description(manPageContent)
+			if format == "tldr" {
+				fmt.Print(output.FormatCheatsheetTLDR(command, description, result.Entries))
+			} else {
+				fmt.Print(output.FormatCheatsheetMarkdown(command, description, result.Entries))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "markdown", "output format: markdown or tldr")
+	cmd.Flags().IntVarP(&count, "count", "n", 15, fmt.Sprintf("number of tasks (at most %d)", maxCheatsheetTasks))
+
+	return cmd
+}
@@ -1,12 +1,14 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
 
 	"github.com/alecf/heyman/internal/cache"
 	"github.com/alecf/heyman/internal/config"
+	"github.com/ollama/ollama/envconfig"
 	"github.com/spf13/cobra"
 )
 
@@ -242,8 +244,11 @@ func testConfigCmd() *cobra.Command {
 						continue
 					}
 				case "ollama":
-					// Check if Ollama is running
-					// For now, just assume it's OK
+					if err := checkOllamaProfile(cmd.Context(), &profile); err != nil {
+						fmt.Printf("❌ %v\n", err)
+						hasErrors = true
+						continue
+					}
 				}
 
 				fmt.Println("✓")
@@ -259,6 +264,29 @@ func testConfigCmd() *cobra.Command {
 	}
 }
 
+// checkOllamaProfile checks that the Ollama server is running and has the profile's model
+func checkOllamaProfile(ctx context.Context, profile *config.Profile) error {
+	provider, err := newOllamaProvider(profile)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, profile.GetConnectTimeout())
+	defer cancel()
+
+	if err := provider.Heartbeat(ctx); err != nil {
+		return fmt.Errorf("Ollama not reachable at %s (is 'ollama serve' running?)", envconfig.Host())
+	}
+	installed, err := provider.HasModel(ctx, profile.Model)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		return fmt.Errorf("model not installed (run: ollama pull %s)", profile.Model)
+	}
+	return nil
+}
+
 func cacheStatsCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "cache-stats",
@@ -279,6 +307,8 @@ func cacheStatsCmd() *cobra.Command {
 			fmt.Printf("  Total entries:    %d\n", stats.TotalEntries)
 			fmt.Printf("  Total size:       %.2f KB\n", float64(stats.TotalSizeBytes)/1024.0)
 			fmt.Printf("  Total hits:       %d\n", stats.TotalHits)
+			fmt.Printf("  Pinned entries:   %d\n", stats.Pinned)
+			fmt.Printf("  Ratings:          %d good, %d bad\n", stats.RatedGood, stats.RatedBad)
 
 			if stats.OldestEntry != nil {
 				fmt.Printf("  Oldest entry:     %s\n", stats.OldestEntry.Format("2006-01-02 15:04:05"))
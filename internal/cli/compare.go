@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/spf13/cobra"
+)
+
+// comparison is one profile's answer in a compare run
+type comparison struct {
+	Profile *config.Profile
+	Answer  *answer
+	Err     error
+	Latency time.Duration
+	Group   string // Label shared by profiles that gave the same command
+}
+
+func compareCmd() *cobra.Command {
+	var profileNames []string
+	var useCache bool
+
+	cmd := &cobra.Command{
+		Use:   "compare -p <profile> -p <profile> <command> <question>",
+		Short: "Compare answers from several profiles side by side",
+		Long: `Send the same question to several profiles concurrently and show each
+answer with its validity, latency, token counts and cost. Profiles that give
+the same command are marked with the same letter in the AGREE column.
+
+Answers bypass the cache unless --use-cache is set.
+
+Example:
+  heyman compare -p openai-gpt4o-mini -p ollama-llama3 tar how do I extract a .tar.gz`,
+		Args: cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(profileNames) < 2 {
+				return fmt.Errorf("specify at least two profiles to compare, e.g. -p openai-gpt4o-mini -p ollama-llama")
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			command, section, questionParts := manpage.ParseCommand(args)
+			if command == "" {
+				return fmt.Errorf("no command specified")
+			}
+			if len(questionParts) == 0 {
+				return fmt.Errorf("no question specified")
+			}
+			question := strings.Join(questionParts, " ")
+
+			profiles := make([]*config.Profile, 0, len(profileNames))
+			for _, name := range profileNames {
+				profile, err := cfg.GetProfile(name)
+				if err != nil {
+					return err
+				}
+				profiles = append(profiles, profile)
+			}
+
+			manPageContent, err := fetchManPage(command, section)
+			if err != nil {
+				return err
+			}
+
+			pipe := &pipeline{
+				cfg:          cfg,
+				noCache:      !useCache,
+				verbose:      verbose,
+				debug:        debug,
+				probeVersion: true,
+				platforms:    new(sync.Map),
+				providers:    new(sync.Map),
+			}
+			results := runComparison(cmd.Context(), pipe, profiles, command, section, manPageContent, question)
+
+			return writeComparison(os.Stdout, results)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&profileNames, "profile", "p", nil, "profile to compare (repeatable, at least two)")
+	cmd.Flags().BoolVar(&useCache, "use-cache", false, "serve answers from the cache when available")
+
+	return cmd
+}
+
+// runComparison answers the question with every profile concurrently and
+// groups profiles whose commands agree
+func runComparison(ctx context.Context, pipe *pipeline, profiles []*config.Profile, command, section, manPage, question string) []*comparison {
+	results := make([]*comparison, len(profiles))
+
+	var wg sync.WaitGroup
+	for i, profile := range profiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := &comparison{Profile: profile}
+			results[i] = result
+
+			start := time.Now()
+			result.Answer, result.Err = pipe.answer(ctx, profile, nil, command, section, manPage, question)
+			result.Latency = time.Since(start)
+		}()
+	}
+	wg.Wait()
+
+	// Label groups of identical commands A, B, C... in order of first appearance
+	groups := make(map[string][]*comparison)
+	var order []string
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		key := agreementKey(result.Answer.Parsed.Command)
+		if _, ok := groups[key]; !ok {
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], result)
+	}
+
+	label := 'A'
+	for _, key := range order {
+		if len(groups[key]) < 2 {
+			continue
+		}
+		for _, result := range groups[key] {
+			result.Group = string(label)
+		}
+		label++
+	}
+
+	return results
+}
+
+// agreementKey is what two answers must share to count as agreeing: the command
+// with whitespace collapsed, so trivially different answers compare equal
+func agreementKey(command string) string {
+	return strings.Join(strings.Fields(command), " ")
+}
+
+// writeComparison renders the comparison table and an agreement summary
+func writeComparison(w io.Writer, results []*comparison) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "AGREE\tPROFILE\tMODEL\tVALID\tLATENCY\tTOKENS IN/OUT\tCOST\tCOMMAND")
+
+	agreeing := make(map[string]int)
+	for _, r := range results {
+		group := r.Group
+		if group == "" {
+			group = "-"
+		} else {
+			agreeing[r.Group]++
+		}
+
+		if r.Err != nil {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t✗\t%s\t-\t-\t%s\n",
+				group, r.Profile.Name, r.Profile.Model, formatLatency(r.Latency), truncate(r.Err.Error(), 80))
+			continue
+		}
+
+		resp := r.Answer.Response
+		latency := formatLatency(r.Latency)
+		if resp.Cached {
+			latency += " (cached)"
+		}
+		cost := "-"
+		if c := estimateCost(r.Profile.Model, resp.TokensInput, resp.TokensOutput); c != nil {
+			cost = fmt.Sprintf("$%.4f", *c)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t✓\t%s\t%d/%d\t%s\t%s\n",
+			group, r.Profile.Name, r.Profile.Model, latency, resp.TokensInput, resp.TokensOutput, cost, r.Answer.Parsed.Command)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	switch {
+	case len(agreeing) == 0:
+		fmt.Fprintln(w, "No two profiles gave the same command")
+	case len(agreeing) == 1 && agreeing["A"] == len(results):
+		fmt.Fprintln(w, "✓ All profiles agree")
+	default:
+		for _, r := range results {
+			if count, ok := agreeing[r.Group]; ok {
+				fmt.Fprintf(w, "%s: %d of %d profiles agree on: %s\n", r.Group, count, len(results), r.Answer.Parsed.Command)
+				delete(agreeing, r.Group)
+			}
+		}
+	}
+
+	return nil
+}
+
+// formatLatency rounds a latency for display
+func formatLatency(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alecf/heyman/internal/cache"
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/alecf/heyman/internal/pricing"
+)
+
+// dryRunReport describes the request heyman would send for a question
+type dryRunReport struct {
+	Profile      string        `json:"profile"`
+	Provider     string        `json:"provider"`
+	Model        string        `json:"model"`
+	Fallbacks    []string      `json:"fallbacks,omitempty"`
+	Command      string        `json:"command"`
+	Section      string        `json:"section,omitempty"`
+	Question     string        `json:"question"`
+	ManPage      dryRunManPage `json:"man_page"`
+	Platform     string        `json:"platform"`
+	Structured   bool          `json:"structured_output"`
+	Examples     int           `json:"examples"` // Accepted answers included as few-shot examples
+	Tokens       dryRunTokens  `json:"tokens"`
+	Cost         *dryRunCost   `json:"cost"` // Nil when the model has no known pricing
+	Cache        dryRunCache   `json:"cache"`
+	SystemPrompt string        `json:"system_prompt"`
+	UserPrompt   string        `json:"user_prompt"`
+}
+
+// dryRunManPage describes the man page a prompt is built from
+type dryRunManPage struct {
+	Source   string   `json:"source,omitempty"` // File man reads the page from, if man can say
+	Sections []string `json:"sections"`
+	Bytes    int      `json:"bytes"`
+}
+
+// dryRunTokens compares the prompt's size with the model's context window
+type dryRunTokens struct {
+	Prompt        int    `json:"prompt"` // System and user prompt together
+	Exact         bool   `json:"exact"`
+	Tokenizer     string `json:"tokenizer"`
+	MaxResponse   int    `json:"max_response"`
+	ContextWindow int    `json:"context_window"`
+	Fits          bool   `json:"fits"` // Whether the prompt and the longest response allowed fit together
+}
+
+// dryRunCost is the range a query could cost, in dollars
+type dryRunCost struct {
+	Min float64 `json:"min"` // The prompt alone
+	Max float64 `json:"max"` // The prompt and the longest response allowed
+}
+
+// dryRunCache says whether the answer would come from the cache
+type dryRunCache struct {
+	Key     string `json:"key"`
+	Hit     bool   `json:"hit"`
+	Skipped bool   `json:"skipped"` // --no-cache
+}
+
+// dryRun builds the prompt for a question exactly as answer would, and
+// reports what would be sent without querying the provider
+func (p *pipeline) dryRun(ctx context.Context, providerConfig *ProviderConfig, fallbacks []*config.Profile, command, section, manPage, question string) *dryRunReport {
+	promptBuilder, platform := p.buildPrompt(ctx, providerConfig, command, section, manPage, question)
+	profile := providerConfig.Profile
+	systemPrompt := promptBuilder.SystemPrompt()
+	userPrompt := promptBuilder.UserPrompt()
+
+	report := &dryRunReport{
+		Profile:      profile.Name,
+		Provider:     profile.Provider,
+		Model:        profile.Model,
+		Command:      command,
+		Section:      section,
+		Question:     question,
+		ManPage:      dryRunManPage{Bytes: len(manPage)},
+		Platform:     platform.Describe(command),
+		Structured:   promptBuilder.Structured(),
+		Examples:     len(promptBuilder.Examples()),
+		SystemPrompt: systemPrompt,
+		UserPrompt:   userPrompt,
+	}
+	for _, fallback := range fallbacks {
+		report.Fallbacks = append(report.Fallbacks, fallback.Name)
+	}
+	if source, err := manPages.Locate(command, section); err == nil {
+		report.ManPage.Source = source
+	}
+	for _, s := range manpage.Sections(manPage) {
+		report.ManPage.Sections = append(report.ManPage.Sections, s.Name)
+	}
+
+	tokenizer := providerConfig.tokenizer()
+	maxResponse := p.maxResponseTokens()
+	promptTokens := tokenizer.Count(ctx, systemPrompt) + tokenizer.Count(ctx, userPrompt)
+	report.Tokens = dryRunTokens{
+		Prompt:        promptTokens,
+		Exact:         tokenizer.Exact(),
+		Tokenizer:     tokenizer.Name(),
+		MaxResponse:   maxResponse,
+		ContextWindow: providerConfig.ContextWindow,
+		Fits:          promptTokens+maxResponse <= providerConfig.ContextWindow,
+	}
+	if low := estimateCost(profile.Model, promptTokens, 0); low != nil {
+		high := estimateCost(profile.Model, promptTokens, maxResponse)
+		report.Cost = &dryRunCost{Min: *low, Max: *high}
+	}
+
+	key := p.cacheKey(providerConfig, command, section, question, platform)
+	report.Cache = dryRunCache{Key: key.Hash(), Skipped: p.noCache}
+	if !p.noCache {
+		report.Cache.Hit = cache.New(p.cfg.CacheDays).Has(key)
+	}
+	return report
+}
+
+// printDryRun prints a dry-run report as JSON, or as a summary with the full
+// prompt written to a temporary file
+func printDryRun(report *dryRunReport, asJSON bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode dry run: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	promptFile, err := writeDryRunPrompt(report)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Dry run: nothing was sent to the model")
+	fmt.Println()
+	fmt.Printf("Profile:   %s (%s %s)\n", report.Profile, report.Provider, report.Model)
+	if len(report.Fallbacks) > 0 {
+		fmt.Printf("Fallbacks: %s\n", strings.Join(report.Fallbacks, ", "))
+	}
+
+	page := report.Command
+	if report.Section != "" {
+		page += "(" + report.Section + ")"
+	}
+	source := report.ManPage.Source
+	if source == "" {
+		source = "unknown source"
+	}
+	fmt.Printf("Man page:  %s from %s, %d bytes\n", page, source, report.ManPage.Bytes)
+	if len(report.ManPage.Sections) > 0 {
+		fmt.Printf("Sections:  %s\n", strings.Join(report.ManPage.Sections, ", "))
+	}
+	fmt.Printf("Platform:  %s\n", report.Platform)
+
+	format := "free text"
+	if report.Structured {
+		format = "structured JSON"
+	}
+	fmt.Printf("Output:    %s, %d few-shot examples\n", format, report.Examples)
+
+	approx := "~"
+	if report.Tokens.Exact {
+		approx = ""
+	}
+	fmt.Printf("Tokens:    %s%d of %d-token context window (%s), up to %d more for the response\n",
+		approx, report.Tokens.Prompt, report.Tokens.ContextWindow, report.Tokens.Tokenizer, report.Tokens.MaxResponse)
+	if room := report.Tokens.ContextWindow - report.Tokens.Prompt; room < 0 {
+		fmt.Printf("           ⚠️  The prompt doesn't fit. Try a more specific section: heyman <section> %s <question>\n", report.Command)
+	} else if !report.Tokens.Fits {
+		fmt.Printf("           ⚠️  The prompt leaves room for only %d of the %d response tokens. Try a more specific section: heyman <section> %s <question>\n",
+			room, report.Tokens.MaxResponse, report.Command)
+	}
+
+	switch {
+	case report.Cost != nil:
+		fmt.Printf("Cost:      $%.4f to $%.4f (estimated, based on %s pricing)\n",
+			report.Cost.Min, report.Cost.Max, pricing.GetDatabase().LastUpdated.Format("2006-01-02"))
+	case report.Provider == "ollama":
+		fmt.Println("Cost:      free (Ollama)")
+	default:
+		fmt.Printf("Cost:      unknown (no pricing for %s)\n", report.Model)
+	}
+
+	switch {
+	case report.Cache.Skipped:
+		fmt.Println("Cache:     skipped (--no-cache)")
+	case report.Cache.Hit:
+		fmt.Println("Cache:     hit, the cached answer would be shown")
+	default:
+		fmt.Println("Cache:     miss")
+	}
+
+	fmt.Printf("Prompt:    %s\n", promptFile)
+	return nil
+}
+
+// writeDryRunPrompt writes the full system and user prompts to a temporary
+// file and returns its path
+func writeDryRunPrompt(report *dryRunReport) (string, error) {
+	file, err := os.CreateTemp("", "heyman-prompt-*.txt")
+	if err != nil {
+		return "", fmt.Errorf("failed to write prompt: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "=== System Prompt ===\n%s\n\n=== User Prompt ===\n%s\n", report.SystemPrompt, report.UserPrompt); err != nil {
+		return "", fmt.Errorf("failed to write prompt: %w", err)
+	}
+	return file.Name(), nil
+}
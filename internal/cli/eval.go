@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/eval"
+	"github.com/spf13/cobra"
+)
+
+func evalCmd() *cobra.Command {
+	var profileNames []string
+	var jsonOutput, useCache bool
+
+	cmd := &cobra.Command{
+		Use:   "eval <suite.yaml>",
+		Short: "Measure answer accuracy across profiles",
+		Long: `Run a suite of questions with known-good answers against one or more
+profiles in parallel, and report pass rate, latency, tokens and cost per profile.
+
+Suite format:
+  name: coreutils basics
+  profiles: [openai-gpt4o-mini, ollama-llama]
+  cases:
+    - command: ls
+      question: how do I list files by size
+      expect:
+        match: ['^ls .*-[a-zA-Z]*S']
+        require_flags: [-S]
+        forbid_flags: [-R]
+
+Profiles given with -p override the suite's list; with neither, the active
+profile is used. Answers bypass the cache unless --use-cache is set.
+
+Example:
+  heyman eval suite.yaml -p openai-gpt4o-mini -p ollama-llama
+  heyman eval suite.yaml --json > results.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			suite, err := eval.Load(args[0])
+			if err != nil {
+				return err
+			}
+
+			if len(profileNames) == 0 {
+				profileNames = suite.Profiles
+			}
+			if len(profileNames) == 0 {
+				activeProfile, err := cfg.GetActiveProfile()
+				if err != nil {
+					return fmt.Errorf("no profiles to evaluate: %w", err)
+				}
+				profileNames = []string{activeProfile.Name}
+			}
+
+			// Create every provider up front so configuration errors fail fast
+			providers := make([]*ProviderConfig, 0, len(profileNames))
+			models := make(map[string]string)
+			for _, name := range profileNames {
+				profile, err := cfg.GetProfile(name)
+				if err != nil {
+					return err
+				}
+				providerConfig, err := createProvider(cmd.Context(), cfg, profile, ProviderOptions{Verbose: verbose})
+				if err != nil {
+					return fmt.Errorf("profile %s: %w", name, err)
+				}
+				providers = append(providers, providerConfig)
+				models[name] = profile.Model
+			}
+
+			if !quiet && !jsonOutput {
+				fmt.Fprintf(os.Stderr, "Evaluating %d cases against %d profiles...\n", len(suite.Cases), len(providers))
+			}
+
+			// Commands come from the suite, so none is run with --version
+			pipe := &pipeline{
+				cfg:       cfg,
+				noCache:   !useCache,
+				verbose:   verbose,
+				debug:     debug,
+				platforms: new(sync.Map),
+				providers: new(sync.Map),
+			}
+			report := runEval(cmd.Context(), pipe, suite, providers, models)
+
+			if jsonOutput {
+				out, err := report.FormatJSON()
+				if err != nil {
+					return err
+				}
+				fmt.Println(out)
+				return nil
+			}
+
+			return report.WriteTable(os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&profileNames, "profile", "p", nil, "profile to evaluate (repeatable)")
+	cmd.Flags().BoolVarP(&jsonOutput, "json", "j", false, "JSON report for tracking regressions")
+	cmd.Flags().BoolVar(&useCache, "use-cache", false, "serve answers from the cache when available")
+
+	return cmd
+}
+
+// runEval runs every case against every provider. Profiles run in parallel;
+// cases run sequentially within a profile so latencies are comparable.
+func runEval(ctx context.Context, pipe *pipeline, suite *eval.Suite, providers []*ProviderConfig, models map[string]string) *eval.Report {
+	startedAt := time.Now()
+
+	// Fetch each man page once and share it across profiles
+	type fetched struct {
+		content string
+		err     error
+	}
+	manPages := make(map[string]fetched)
+	for _, c := range suite.Cases {
+		key := c.Section + "/" + c.Command
+		if _, ok := manPages[key]; !ok {
+			content, err := fetchManPage(c.Command, c.Section)
+			manPages[key] = fetched{content, err}
+		}
+	}
+
+	// Use the providers already created rather than creating them again
+	for _, providerConfig := range providers {
+		pipe.providers.Store(providerConfig.Profile.Name, providerConfig)
+	}
+
+	perProfile := make([][]eval.Result, len(providers))
+	var wg sync.WaitGroup
+	for i, providerConfig := range providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, c := range suite.Cases {
+				page := manPages[c.Section+"/"+c.Command]
+				perProfile[i] = append(perProfile[i], evalCase(ctx, pipe, providerConfig, c, page.content, page.err))
+			}
+		}()
+	}
+	wg.Wait()
+
+	var results []eval.Result
+	for _, r := range perProfile {
+		results = append(results, r...)
+	}
+
+	return eval.NewReport(suite.Name, startedAt, models, results)
+}
+
+// evalCase answers one case with one provider and checks the answer
+func evalCase(ctx context.Context, pipe *pipeline, providerConfig *ProviderConfig, c eval.Case, manPage string, fetchErr error) eval.Result {
+	result := eval.Result{
+		Profile: providerConfig.Profile.Name,
+		Case:    c.Name,
+	}
+
+	if fetchErr != nil {
+		result.Error = fetchErr.Error()
+		return result
+	}
+
+	start := time.Now()
+	ans, err := pipe.answer(ctx, providerConfig.Profile, nil, c.Command, c.Section, manPage, c.Question)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Command = ans.Parsed.Command
+	result.TokensInput = ans.Response.TokensInput
+	result.TokensOutput = ans.Response.TokensOutput
+	result.Cached = ans.Response.Cached
+	result.Cost = estimateCost(providerConfig.Profile.Model, ans.Response.TokensInput, ans.Response.TokensOutput)
+	result.Failures = c.Check(ans.Parsed.Command, manPage)
+	result.Passed = len(result.Failures) == 0
+
+	return result
+}
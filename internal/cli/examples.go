@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/alecf/heyman/internal/examples"
+)
+
+// recordAccepted adds an answer the user accepted (for a platform key such as
+// "darwin/bsd") to the few-shot example library. Failures only matter in
+// verbose mode; the answer was still given.
+func recordAccepted(command, question, answer, platform, source string) {
+	err := examples.Open().Add(examples.Example{
+		Command:  command,
+		Question: question,
+		Answer:   answer,
+		Platform: platform,
+		Source:   source,
+	})
+	if err != nil && verbose {
+		fmt.Printf("Warning: failed to save accepted answer: %v\n", err)
+	}
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alecf/heyman/internal/parser"
+)
+
+// explainStream prints an explain-mode answer as it streams: the command as
+// soon as its line is complete, then the explanation as it arrives
+type explainStream struct {
+	w         io.Writer
+	name      string // Command the answer is about
+	extractor *parser.StreamExtractor
+	command   string // Printed command; empty until then
+	explained bool   // Whether any explanation has been printed
+	atLineEnd bool   // Whether the last text printed ended a line
+}
+
+// newExplainStream creates a stream printing answers about command to w
+func newExplainStream(w io.Writer, command string) *explainStream {
+	return &explainStream{
+		w:         w,
+		name:      command,
+		extractor: parser.New(command, true).NewStreamExtractor(),
+	}
+}
+
+// write handles the next chunk of the response
+func (s *explainStream) write(chunk string) {
+	s.show(s.extractor.Write(chunk))
+}
+
+// show prints a newly extracted command and explanation text
+func (s *explainStream) show(command, explanation string) {
+	if command != "" {
+		s.command = command
+		fmt.Fprintln(s.w, command)
+	}
+	if explanation == "" {
+		return
+	}
+
+	if !s.explained {
+		s.explained = true
+		fmt.Fprintln(s.w)
+	}
+	fmt.Fprint(s.w, explanation)
+	s.atLineEnd = strings.HasSuffix(explanation, "\n")
+}
+
+// restart marks anything printed from a response that failed partway as cut
+// off, and starts afresh for the answer from the next profile
+func (s *explainStream) restart(profile string) {
+	if s.command != "" || s.explained {
+		if s.explained && !s.atLineEnd {
+			fmt.Fprintln(s.w)
+		}
+		fmt.Fprintf(s.w, "\n⚠️  The answer above was cut off. Asking %s instead:\n\n", profile)
+	}
+
+	s.extractor = parser.New(s.name, true).NewStreamExtractor()
+	s.command = ""
+	s.explained = false
+	s.atLineEnd = false
+}
+
+// finish ends the streamed output, reporting whether it showed the final
+// command and so need not be printed again
+func (s *explainStream) finish(final string) bool {
+	s.show(s.extractor.Close())
+	if s.explained && !s.atLineEnd {
+		fmt.Fprintln(s.w)
+		s.atLineEnd = true
+	}
+	return s.command != "" && s.command == final
+}
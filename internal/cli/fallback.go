@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/llm"
+	"github.com/alecf/heyman/internal/prompt"
+)
+
+// queryWithFallback queries each profile in the chain in turn, moving on to
+// the next when its provider can't be created or the query fails with a
+// retryable error. Returns the response along with the provider that produced
+// it and the prompt it was given.
+func (p *pipeline) queryWithFallback(ctx context.Context, chain []*config.Profile, command, section, manPage, question string) (*llm.QueryResponse, *ProviderConfig, *prompt.Builder, error) {
+	var failed []string
+	var lastErr error
+
+	for i, profile := range chain {
+		providerConfig, err := p.provider(ctx, profile, i == 0)
+		if err != nil {
+			if p.verbose && len(chain) > 1 {
+				fmt.Fprintf(os.Stderr, "Skipping profile %s: %v\n", profile.Name, err)
+			}
+			failed = append(failed, profile.Name)
+			lastErr = err
+			continue
+		}
+
+		if i > 0 && p.onFallback != nil {
+			p.onFallback(profile.Name)
+		}
+
+		promptBuilder := p.preparePrompt(ctx, providerConfig, command, section, manPage, question)
+		resp, err := p.queryWithCache(ctx, providerConfig, promptBuilder, command, question, p.platform(command, manPage))
+		if err == nil {
+			resp.Profile = profile.Name
+			if p.verbose && i > 0 {
+				fmt.Fprintf(os.Stderr, "Answered by fallback profile: %s\n", profile.Name)
+			}
+			return resp, providerConfig, promptBuilder, nil
+		}
+
+		if !isRetryableError(err) {
+			return nil, nil, nil, err
+		}
+
+		failed = append(failed, profile.Name)
+		lastErr = err
+		if p.verbose && i < len(chain)-1 {
+			fmt.Fprintf(os.Stderr, "Profile %s failed: %v\nFalling back to %s\n", profile.Name, err, chain[i+1].Name)
+		}
+	}
+
+	if len(chain) == 1 {
+		return nil, nil, nil, lastErr
+	}
+	return nil, nil, nil, fmt.Errorf("all profiles failed (%s): %w", strings.Join(failed, ", "), lastErr)
+}
+
+// isRetryableError reports whether a query error is worth retrying on another profile:
+// rate limits, unreachable servers, timeouts and context-length errors
+func isRetryableError(err error) bool {
+	return llm.IsTransient(err) || errors.Is(err, llm.ErrTimeout) || errors.Is(err, llm.ErrContextLength)
+}
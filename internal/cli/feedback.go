@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/alecf/heyman/internal/cache"
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/examples"
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/alecf/heyman/internal/output"
+	"github.com/alecf/heyman/internal/parser"
+	"github.com/alecf/heyman/internal/prompt"
+	"github.com/spf13/cobra"
+)
+
+func feedbackCmd() *cobra.Command {
+	var last, requery bool
+	var reason string
+
+	cmd := &cobra.Command{
+		Use:   "feedback good|bad [key]",
+		Short: "Rate an answer to curate the cache",
+		Long: `Rate the last answer (or the cached answer with the given key or key
+prefix, as shown by 'heyman history').
+
+  good  pins the answer so it never expires and adds it to the examples
+        used in future prompts
+  bad   evicts the answer from the cache and the examples, so the next
+        query asks the model again; --requery does that right away,
+        telling the model its previous answer was wrong (and --reason why)
+
+Example:
+  heyman feedback bad --requery --reason "-r is not recursive on BSD"`,
+		Args:      cobra.RangeArgs(1, 2),
+		ValidArgs: []string{cache.RatingGood, cache.RatingBad},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rating := args[0]
+			if rating != cache.RatingGood && rating != cache.RatingBad {
+				return fmt.Errorf("invalid rating %q: must be %s or %s", rating, cache.RatingGood, cache.RatingBad)
+			}
+			if len(args) == 2 && last {
+				return fmt.Errorf("specify either a key or --last, not both")
+			}
+			if requery && rating != cache.RatingBad {
+				return fmt.Errorf("--requery only applies to bad answers")
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+			cacheManager := cache.New(cfg.CacheDays)
+
+			// Find the entry: by key, or the last answer shown
+			var entry *cache.Entry
+			var answer string
+			if len(args) == 2 {
+				if entry, err = cacheManager.Lookup(args[1]); err != nil {
+					return err
+				}
+				answer = entryAnswer(entry)
+			} else {
+				lastAnswer, err := cacheManager.GetLast()
+				if err != nil {
+					return err
+				}
+				if entry, err = cacheManager.Lookup(lastAnswer.Key); err != nil {
+					return err
+				}
+				answer = lastAnswer.Answer
+				if entry.Section == "" {
+					// Entries cached before sections were recorded
+					entry.Section = lastAnswer.Section
+				}
+			}
+
+			if err := cacheManager.Rate(entry, rating, answer, reason); err != nil {
+				return err
+			}
+
+			library := examples.Open()
+			if rating == cache.RatingGood {
+				if answer != "" {
+					err = library.Add(examples.Example{
+						Command:  entry.Command,
+						Question: entry.Question,
+						Answer:   answer,
+						Platform: entry.Platform,
+						Source:   examples.SourceRating,
+					})
+					if err != nil && verbose {
+						fmt.Printf("Warning: failed to save accepted answer: %v\n", err)
+					}
+				}
+				fmt.Printf("✓ Pinned: %s\n", answer)
+				return nil
+			}
+
+			if _, err := library.Remove(entry.Command, entry.Question, answer); err != nil && verbose {
+				fmt.Printf("Warning: failed to remove example: %v\n", err)
+			}
+			fmt.Printf("✗ Evicted: %s\n", answer)
+
+			if !requery {
+				return nil
+			}
+			fmt.Println()
+			return requeryAnswer(cmd, cfg, entry, &prompt.Correction{Previous: answer, Reason: reason})
+		},
+	}
+
+	cmd.Flags().BoolVar(&last, "last", false, "rate the last answer (the default)")
+	cmd.Flags().BoolVar(&requery, "requery", false, "ask again right away, saying the previous answer was wrong")
+	cmd.Flags().StringVar(&reason, "reason", "", "why the answer was wrong (recorded, and sent with --requery)")
+
+	return cmd
+}
+
+// entryAnswer extracts the command from a cached response, or "" if it has none
+func entryAnswer(entry *cache.Entry) string {
+	responseParser := parser.New(entry.Command, false)
+	for _, parsed := range responseParser.ParseAlternatives(entry.Response.Content) {
+		if parsed.Valid {
+			return parsed.Command
+		}
+	}
+	return ""
+}
+
+// requeryAnswer asks a rejected question again the way it was first asked
+// (same model, section and answer shape), including the rejected answer in
+// the prompt, so the new answer replaces it in the cache
+func requeryAnswer(cmd *cobra.Command, cfg *config.Config, entry *cache.Entry, correction *prompt.Correction) error {
+	profile, err := entryProfile(cfg, entry)
+	if err != nil {
+		return err
+	}
+	fallbacks, err := cfg.FallbackChain(profile)
+	if err != nil {
+		return err
+	}
+
+	manPageContent, err := fetchManPage(entry.Command, entry.Section)
+	if err != nil {
+		return err
+	}
+
+	pipe := newPipeline(cfg, false)
+	pipe.noCache = true
+	pipe.correction = correction
+	pipe.restoreVariant(entry.Variant)
+	result, err := pipe.answer(cmd.Context(), profile, fallbacks, entry.Command, entry.Section, manPageContent, entry.Question)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Entries != nil:
+		description := manpage.Description(manPageContent)
+		fmt.Print(output.FormatCheatsheetMarkdown(entry.Command, description, result.Entries))
+		return nil
+	case result.Alternatives != nil:
+		_, err = outputAlternatives(cmd, result.Alternatives, result.Response, result.Provider.Profile)
+	default:
+		_, err = outputResult(cmd, result.Parsed, result.Response, result.Provider.Profile, cfg, false)
+	}
+	if err != nil {
+		return err
+	}
+	rememberLast(cfg, result, entry.Section)
+	recordHistory(result, entry.Command, entry.Section, entry.Question)
+	return nil
+}
+
+// entryProfile returns the profile to ask again with: the active profile if
+// it uses the entry's model, else another profile that does. With none, the
+// active profile answers (and caches under its own model).
+func entryProfile(cfg *config.Config, entry *cache.Entry) (*config.Profile, error) {
+	activeProfile, err := cfg.GetActiveProfile()
+	if err != nil {
+		return nil, fmt.Errorf("no profile configured: %w\nRun 'heyman setup' to configure", err)
+	}
+	if activeProfile.Model == entry.Model {
+		return activeProfile, nil
+	}
+
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cfg.Profiles[name].Model == entry.Model {
+			return cfg.GetProfile(name)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "⚠️  No profile uses %s; asking %s (%s) instead\n\n", entry.Model, activeProfile.Name, activeProfile.Model)
+	return activeProfile, nil
+}
+
+// rememberLast records the answer just shown for heyman feedback --last
+func rememberLast(cfg *config.Config, result *answer, section string) {
+	err := cache.New(cfg.CacheDays).SetLast(cache.Last{
+		Key:     result.CacheKey.Hash(),
+		Section: section,
+		Answer:  result.Parsed.Command,
+	})
+	if err != nil && verbose {
+		fmt.Printf("Warning: failed to record last answer: %v\n", err)
+	}
+}
@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/examples"
+	"github.com/alecf/heyman/internal/history"
+	"github.com/alecf/heyman/internal/llm"
+	"github.com/alecf/heyman/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+// historyRefRegex matches "!!" (the last answer), "!N" and "!-N"
+var historyRefRegex = regexp.MustCompile(`^!(!|-?[1-9][0-9]*)$`)
+
+func historyCmd() *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "history [search terms...]",
+		Short: "List or search previous answers",
+		Long: `List recent answers, or search them. Search terms match the command,
+question and answer fuzzily ("tgz" matches "tar.gz"); every term must match.
+
+Show an earlier answer again, without querying the model, with its number:
+  heyman '!12'         # answer 12 (quoted, or the shell expands it)
+  heyman '!!' --copy   # copy the last answer`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if limit < 0 {
+				return fmt.Errorf("--limit must not be negative")
+			}
+			store := history.Open()
+
+			var entries []history.Entry
+			var err error
+			if len(args) == 0 {
+				entries, err = store.Load()
+				// Most recent last, like shell history
+				if limit > 0 && len(entries) > limit {
+					entries = entries[len(entries)-limit:]
+				}
+			} else {
+				entries, err = store.Search(args)
+				if limit > 0 && len(entries) > limit {
+					entries = entries[:limit]
+				}
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(entries) == 0 {
+				if len(args) == 0 {
+					fmt.Println("History is empty")
+				} else {
+					fmt.Println("No matching history entries")
+				}
+				return nil
+			}
+
+			for _, entry := range entries {
+				fmt.Printf("%4d  %s  %s: %s\n", entry.N, entry.Time.Local().Format("2006-01-02 15:04"), entry.Command, entry.Question)
+				fmt.Printf("      %s\n", entry.Answer)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 20, "maximum number of entries to list (0 for all)")
+	cmd.AddCommand(historyShowCmd())
+
+	return cmd
+}
+
+func historyShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show N",
+		Short: "Show the details of a history entry",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			n, err := strconv.Atoi(strings.TrimPrefix(args[0], "!"))
+			if err != nil {
+				return fmt.Errorf("invalid history entry %q", args[0])
+			}
+			entry, err := history.Open().Get(n)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("#%d  %s\n\n", entry.N, entry.Time.Local().Format(time.DateTime))
+			command := entry.Command
+			if entry.Section != "" {
+				command = fmt.Sprintf("%s (section %s)", command, entry.Section)
+			}
+			fmt.Printf("Command:   %s\n", command)
+			fmt.Printf("Question:  %s\n", entry.Question)
+			fmt.Printf("Answer:    %s\n", entry.Answer)
+			fmt.Printf("Profile:   %s (%s)\n", entry.Profile, entry.Model)
+			if entry.Platform != "" {
+				fmt.Printf("Platform:  %s\n", entry.Platform)
+			}
+			if entry.Cwd != "" {
+				fmt.Printf("Directory: %s\n", entry.Cwd)
+			}
+			if entry.CacheKey != "" {
+				fmt.Printf("Cache key: %s\n", entry.CacheKey)
+			}
+			if entry.Explanation != "" {
+				fmt.Printf("\n%s\n", entry.Explanation)
+			}
+			return nil
+		},
+	}
+}
+
+// isHistoryRef reports whether an argument refers to a history entry ("!12")
+func isHistoryRef(arg string) bool {
+	return historyRefRegex.MatchString(arg)
+}
+
+// showHistoryRef outputs a previous answer again, honoring --json, --explain
+// and --copy, without querying the model
+func showHistoryRef(cmd *cobra.Command, ref string) error {
+	n := -1 // "!!"
+	if ref != "!!" {
+		n, _ = strconv.Atoi(ref[1:])
+	}
+	entry, err := history.Open().Get(n)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	parsed := parser.ParsedResponse{
+		Command:     entry.Answer,
+		Explanation: entry.Explanation,
+		Valid:       true,
+	}
+	resp := &llm.QueryResponse{
+		Model:    entry.Model,
+		Provider: entry.Provider,
+		Profile:  entry.Profile,
+		Cached:   true,
+	}
+	profile := &config.Profile{Name: entry.Profile, Provider: entry.Provider, Model: entry.Model}
+
+	copied, err := outputResult(cmd, parsed, resp, profile, cfg, false)
+	if err != nil {
+		return err
+	}
+	if copied != "" {
+		recordAccepted(entry.Command, entry.Question, copied, entry.Platform, examples.SourceCopy)
+	}
+	return nil
+}
+
+// recordHistory appends an answer to the query history. Failures only matter
+// in verbose mode; the answer was still given.
+func recordHistory(result *answer, command, section, question string) {
+	cwd, _ := os.Getwd()
+	err := history.Open().Append(history.Entry{
+		Time:        time.Now(),
+		Command:     command,
+		Section:     section,
+		Question:    question,
+		Answer:      result.Parsed.Command,
+		Explanation: result.Parsed.Explanation,
+		Profile:     result.Provider.Profile.Name,
+		Provider:    result.Provider.Profile.Provider,
+		Model:       result.Provider.Profile.Model,
+		Platform:    result.Platform.Key(),
+		Cwd:         cwd,
+		CacheKey:    result.CacheKey.Hash(),
+		Cached:      result.Response.Cached,
+	})
+	if err != nil && verbose {
+		fmt.Printf("Warning: failed to save history: %v\n", err)
+	}
+}
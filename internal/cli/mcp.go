@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/alecf/heyman/internal/mcp"
+	"github.com/spf13/cobra"
+)
+
+// maxSearchResults caps the paragraphs search_manpage returns
+const maxSearchResults = 20
+
+func mcpCmd(version string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "mcp",
+		Short: "Run a Model Context Protocol server over stdio",
+		Long: `Run a Model Context Protocol (MCP) server on stdin/stdout, so coding agents
+can ground their use of command-line tools in the installed man pages.
+
+Tools:
+  fetch_manpage   the full man page for a command
+  search_manpage  the man page paragraphs most relevant to a query
+  ask             a validated command answering a question, with the flags
+                  it uses cited from the man page
+
+ask uses the default profile, with its fallbacks and the cache.
+
+Example configuration for an MCP client:
+  {"mcpServers": {"heyman": {"command": "heyman", "args": ["mcp"]}}}`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			server := mcp.NewServer("heyman", version, mcpTools(newServer(cfg, 1))...)
+			return server.Serve(ctx, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+}
+
+// mcpTools returns the tools the MCP server exposes. ask answers through the
+// HTTP server's query path, so both share providers and detected platforms.
+func mcpTools(s *server) []mcp.Tool {
+	return []mcp.Tool{
+		{
+			Name:        "fetch_manpage",
+			Description: "Fetch the man page for a command-line tool installed on this machine. Man pages can be long; prefer search_manpage when looking for something specific.",
+			InputSchema: objectSchema(map[string]any{
+				"command": stringProperty("The command, e.g. \"tar\""),
+				"section": stringProperty("Man page section, e.g. \"1\" (optional)"),
+			}, "command"),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				var args struct {
+					Command string `json:"command"`
+					Section string `json:"section"`
+				}
+				if err := decodeArguments(arguments, &args); err != nil {
+					return "", err
+				}
+				return fetchManPage(args.Command, args.Section)
+			},
+		},
+		{
+			Name:        "search_manpage",
+			Description: "Search the man page for a command-line tool installed on this machine, returning the paragraphs most relevant to the query with their section and line numbers. Flags in the query (like -r) match exactly.",
+			InputSchema: objectSchema(map[string]any{
+				"command": stringProperty("The command, e.g. \"tar\""),
+				"query":   stringProperty("Words or flags to look for, e.g. \"extract gzip -z\""),
+				"section": stringProperty("Man page section, e.g. \"1\" (optional)"),
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of paragraphs to return (default 5, at most %d)", maxSearchResults),
+				},
+			}, "command", "query"),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				var args struct {
+					Command string `json:"command"`
+					Query   string `json:"query"`
+					Section string `json:"section"`
+					Limit   int    `json:"limit"`
+				}
+				if err := decodeArguments(arguments, &args); err != nil {
+					return "", err
+				}
+				if args.Limit <= 0 {
+					args.Limit = 5
+				}
+				args.Limit = min(args.Limit, maxSearchResults)
+
+				content, err := fetchManPage(args.Command, args.Section)
+				if err != nil {
+					return "", err
+				}
+				matches := manpage.Search(content, args.Query, args.Limit)
+				if len(matches) == 0 {
+					return fmt.Sprintf("Nothing in the %s man page matches %q", args.Command, args.Query), nil
+				}
+
+				var b strings.Builder
+				for i, match := range matches {
+					if i > 0 {
+						b.WriteString("\n")
+					}
+					location := fmt.Sprintf("lines %d-%d", match.StartLine, match.EndLine)
+					if match.StartLine == match.EndLine {
+						location = fmt.Sprintf("line %d", match.StartLine)
+					}
+					if match.Section != "" {
+						location = match.Section + ", " + location
+					}
+					fmt.Fprintf(&b, "[%s]\n%s\n", location, match.Text)
+				}
+				return b.String(), nil
+			},
+		},
+		{
+			Name:        "ask",
+			Description: "Ask how to do something with a command-line tool. Returns JSON with a command validated against the installed tool's man page and citations for the flags it uses.",
+			InputSchema: objectSchema(map[string]any{
+				"command":  stringProperty("The command, e.g. \"tar\""),
+				"question": stringProperty("What to do, e.g. \"extract a .tar.gz into /tmp\""),
+				"section":  stringProperty("Man page section, e.g. \"1\" (optional)"),
+				"explain": map[string]any{
+					"type":        "boolean",
+					"description": "Include an explanation of the command",
+				},
+			}, "command", "question"),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				var req queryRequest
+				if err := decodeArguments(arguments, &req); err != nil {
+					return "", err
+				}
+				if req.Question == "" {
+					return "", fmt.Errorf("question is required")
+				}
+				req.Profile = "" // The default profile only
+
+				body, _, err := s.answer(ctx, req, nil)
+				return body, err
+			},
+		},
+	}
+}
+
+// decodeArguments decodes tool arguments, which must include a command. The
+// command and section are checked before they can reach man, since clients
+// (or models following injected instructions) can send anything.
+func decodeArguments(arguments json.RawMessage, args any) error {
+	if err := json.Unmarshal(arguments, args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	var page struct {
+		Command string `json:"command"`
+		Section string `json:"section"`
+	}
+	json.Unmarshal(arguments, &page)
+	if strings.TrimSpace(page.Command) == "" {
+		return fmt.Errorf("command is required")
+	}
+	return manpage.Validate(page.Command, page.Section)
+}
+
+// objectSchema returns a JSON schema for an object with the given properties
+func objectSchema(properties map[string]any, required ...string) map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// stringProperty returns a JSON schema for a string property
+func stringProperty(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alecf/heyman/internal/cache"
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/llm"
+	"github.com/alecf/heyman/internal/spinner"
+	"github.com/ollama/ollama/envconfig"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// newOllamaProvider creates an Ollama provider with the profile's connection
+// and keep-alive settings
+func newOllamaProvider(profile *config.Profile) (*llm.OllamaProvider, error) {
+	provider, err := llm.NewOllamaProvider(profile.GetConnectTimeout())
+	if err != nil {
+		return nil, err
+	}
+	if profile.KeepAlive != nil {
+		keepAlive := time.Duration(*profile.KeepAlive)
+		provider.KeepAlive = &keepAlive
+	}
+	return provider, nil
+}
+
+// ensureOllamaModel checks that a model is installed, offering to pull it if
+// it isn't. An unreachable server is not an error here: the query will fail
+// and can fall back to another profile.
+func ensureOllamaModel(ctx context.Context, provider *llm.OllamaProvider, model string) error {
+	installed, err := provider.HasModel(ctx, model)
+	if err != nil || installed {
+		return nil
+	}
+	return offerOllamaPull(ctx, provider, model)
+}
+
+// ollamaModelInfo returns what Ollama reports about a model, from the cache if
+// it was asked recently. If the model is missing and offerPull is set, offers
+// to pull it first.
+func ollamaModelInfo(ctx context.Context, cfg *config.Config, provider *llm.OllamaProvider, model string, offerPull bool) (*llm.ModelInfo, error) {
+	if info, ok := cachedOllamaModelInfo(cfg, model); ok {
+		return info, nil
+	}
+
+	info, err := provider.GetModelInfo(ctx, model)
+	if errors.Is(err, llm.ErrModelNotFound) {
+		if !offerPull {
+			return nil, modelNotInstalled(model)
+		}
+		if err := offerOllamaPull(ctx, provider, model); err != nil {
+			return nil, err
+		}
+		info, err = provider.GetModelInfo(ctx, model)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_ = cache.New(cfg.CacheDays).SetModelInfo(ollamaModelKey(model), *info) // Only saves asking again
+	return info, nil
+}
+
+// cachedOllamaModelInfo returns what Ollama reported about a model recently,
+// without asking it
+func cachedOllamaModelInfo(cfg *config.Config, model string) (*llm.ModelInfo, bool) {
+	return cache.New(cfg.CacheDays).GetModelInfo(ollamaModelKey(model))
+}
+
+// errTokenizeUnsupported is returned for servers known to have no tokenize endpoint
+var errTokenizeUnsupported = errors.New("the Ollama server has no tokenize endpoint")
+
+// ollamaTokenize returns a function that tokenizes text with a model on the
+// Ollama server. Whether the server has the endpoint is remembered, so servers
+// without it aren't asked on every query.
+func ollamaTokenize(cfg *config.Config, provider *llm.OllamaProvider, model string) func(ctx context.Context, text string) ([]int, error) {
+	store := cache.New(cfg.CacheDays)
+	key := envconfig.Host().String() + " tokenize"
+	return func(ctx context.Context, text string) ([]int, error) {
+		supported, checked := store.GetServerSupport(key)
+		if checked && !supported {
+			return nil, errTokenizeUnsupported
+		}
+
+		tokens, err := provider.Tokenize(ctx, model, text)
+		var llmErr *llm.Error
+		switch {
+		case err == nil && !checked:
+			_ = store.SetServerSupport(key, true)
+		case errors.As(err, &llmErr) && llmErr.StatusCode == http.StatusNotFound:
+			_ = store.SetServerSupport(key, false)
+		}
+		return tokens, err
+	}
+}
+
+// ollamaModelKey identifies a model on the configured Ollama server in the cache
+func ollamaModelKey(model string) string {
+	return envconfig.Host().String() + "/" + model
+}
+
+// contextWindowSource explains where a detected context window came from, for verbose output
+func contextWindowSource(info *llm.ModelInfo, contextWindow int) string {
+	switch {
+	case info.NumCtx > 0 && contextWindow == info.NumCtx:
+		return " (num_ctx from the Modelfile)"
+	case contextWindow < info.ContextLength:
+		return fmt.Sprintf(" (model supports %d, capped by max_context_window)", info.ContextLength)
+	}
+	return ""
+}
+
+// offerOllamaPull offers to pull a missing model if someone is at the
+// terminal to answer, and otherwise explains how to
+func offerOllamaPull(ctx context.Context, provider *llm.OllamaProvider, model string) error {
+	notInstalled := modelNotInstalled(model)
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return notInstalled
+	}
+
+	fmt.Fprintf(os.Stderr, "Model %s is not installed in Ollama. Pull it now? [Y/n] ", model)
+	reply, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(reply)) {
+	case "", "y", "yes":
+		return pullOllamaModel(ctx, provider, model)
+	}
+	return notInstalled
+}
+
+// modelNotInstalled explains how to pull a missing model
+func modelNotInstalled(model string) error {
+	return fmt.Errorf("%w: %s is not installed in Ollama\nPull it with: ollama pull %s", llm.ErrModelNotFound, model, model)
+}
+
+// pullOllamaModel downloads a model, showing a progress bar
+func pullOllamaModel(ctx context.Context, provider *llm.OllamaProvider, model string) error {
+	progress := spinner.NewProgress()
+	if err := provider.PullModel(ctx, model, progress.Update); err != nil {
+		progress.Done("")
+		return err
+	}
+	progress.Done(fmt.Sprintf("✓ Pulled %s", model))
+	return nil
+}
+
+func warmCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "warm [profile...]",
+		Short: "Load Ollama models into memory ahead of queries",
+		Long: `Load the models of Ollama profiles into memory, so the next query doesn't
+wait for the model to load. With no profiles, warms the active profile.
+
+A model stays loaded for its profile's keep_alive (Ollama's default is 5
+minutes). Missing models can be pulled first.
+
+Example:
+  heyman warm
+  heyman warm ollama-llama ollama-qwen`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			var profiles []*config.Profile
+			if len(args) == 0 {
+				activeProfile, err := cfg.GetActiveProfile()
+				if err != nil {
+					return fmt.Errorf("no profile configured: %w\nRun 'heyman setup' to configure", err)
+				}
+				profiles = append(profiles, activeProfile)
+			}
+			for _, name := range args {
+				profile, err := cfg.GetProfile(name)
+				if err != nil {
+					return err
+				}
+				profiles = append(profiles, profile)
+			}
+
+			attempted, failed := 0, 0
+			for _, profile := range profiles {
+				if profile.Provider != "ollama" {
+					fmt.Printf("Skipping %s: %s models don't need loading\n", profile.Name, profile.Provider)
+					continue
+				}
+				attempted++
+				if err := warmProfile(cmd.Context(), profile); err != nil {
+					fmt.Printf("❌ %s: %v\n", profile.Name, err)
+					failed++
+				}
+			}
+
+			skipped := len(profiles) - attempted
+			if failed > 0 {
+				return fmt.Errorf("failed to load %d of %d Ollama models (%d other profiles skipped)", failed, attempted, skipped)
+			}
+			if attempted == 0 {
+				fmt.Printf("Nothing to load: skipped %d non-Ollama profiles\n", skipped)
+			}
+			return nil
+		},
+	}
+}
+
+// warmProfile loads an Ollama profile's model, pulling it first if needed
+func warmProfile(ctx context.Context, profile *config.Profile) error {
+	provider, err := newOllamaProvider(profile)
+	if err != nil {
+		return err
+	}
+	if err := ensureOllamaModel(ctx, provider, profile.Model); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, profile.GetTimeout())
+	defer cancel()
+
+	spin := spinner.New(fmt.Sprintf("Loading %s...", profile.Model))
+	spin.Start()
+	start := time.Now()
+	err = provider.Warm(ctx, profile.Model)
+	spin.Stop()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Loaded %s for %s in %s\n", profile.Model, profile.Name, time.Since(start).Round(100*time.Millisecond))
+	return nil
+}
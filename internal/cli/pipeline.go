@@ -0,0 +1,331 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecf/heyman/internal/cache"
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/examples"
+	"github.com/alecf/heyman/internal/llm"
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/alecf/heyman/internal/parser"
+	"github.com/alecf/heyman/internal/prompt"
+)
+
+// pipeline is the shared query path used by every command that answers
+// questions: prompt building, cached querying with fallbacks, and validation.
+// Holding the settings here (rather than reading the global flags) lets
+// commands like eval run several queries concurrently without progress output.
+type pipeline struct {
+	cfg          *config.Config
+	explain      bool
+	noCache      bool
+	showProgress bool
+	verbose      bool
+	debug        bool
+	alternatives int                // Number of alternative commands to ask for; <= 1 asks for one
+	cheatsheet   int                // Number of cheat sheet tasks to ask for instead of an answer; 0 for an answer
+	correction   *prompt.Correction // Previous answer the user rated bad, when re-asking
+	onChunk      func(string)       // Receives the response as it streams from the provider, if set
+	onFallback   func(string)       // Told the profile about to be queried after another failed, which may have streamed part of a response
+	probeVersion bool               // Run "<command> --version" to detect the platform; only for commands typed on the command line
+	offerPull    bool               // Offer to pull the primary profile's missing Ollama model; only when a person is at the terminal
+
+	platforms *sync.Map // Detected manpage.Platform by command, shared by copies of the pipeline
+	providers *sync.Map // Created *ProviderConfig by profile name, shared by copies of the pipeline
+}
+
+// newPipeline creates a pipeline configured from the global flags
+func newPipeline(cfg *config.Config, explain bool) *pipeline {
+	return &pipeline{
+		cfg:          cfg,
+		explain:      explain,
+		noCache:      noCache,
+		showProgress: !quiet && !verbose && !debug,
+		verbose:      verbose,
+		debug:        debug,
+		probeVersion: true,
+		platforms:    new(sync.Map),
+		providers:    new(sync.Map),
+	}
+}
+
+// answer is a validated response to a question
+type answer struct {
+	Parsed       parser.ParsedResponse
+	Alternatives []parser.ParsedResponse // Every valid command, best first, when alternatives were requested
+	Entries      []parser.ParsedResponse // Every valid task, most useful first, when a cheat sheet was requested
+	Response     *llm.QueryResponse
+	Provider     *ProviderConfig // Provider (and profile) that answered
+	Latency      time.Duration   // Time spent querying and validating
+	Platform     manpage.Platform
+	CacheKey     cache.Key // Where the answer is cached
+}
+
+// answer queries the profile's provider for a question (falling back to other
+// profiles when the provider can't be created or fails with a retryable error)
+// and validates the response
+func (p *pipeline) answer(ctx context.Context, profile *config.Profile, fallbacks []*config.Profile, command, section, manPage, question string) (*answer, error) {
+	start := time.Now()
+
+	chain := append([]*config.Profile{profile}, fallbacks...)
+	resp, answeredBy, promptBuilder, err := p.queryWithFallback(ctx, chain, command, section, manPage, question)
+	if err != nil {
+		return nil, err
+	}
+
+	platform := p.platform(command, manPage)
+	parsed, err := p.parseAndValidate(ctx, answeredBy, promptBuilder, resp, command, question, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &answer{
+		Parsed:   parsed[0],
+		Response: resp,
+		Provider: answeredBy,
+		Latency:  time.Since(start),
+		Platform: platform,
+		CacheKey: p.cacheKey(answeredBy, command, section, question, platform),
+	}
+	if p.alternatives > 1 {
+		result.Alternatives = parsed
+	}
+	if p.cheatsheet > 0 {
+		result.Entries = parsed
+	}
+	return result, nil
+}
+
+// provider returns the provider for a profile, creating it on first use.
+// Only the primary profile may offer to pull a missing model.
+func (p *pipeline) provider(ctx context.Context, profile *config.Profile, primary bool) (*ProviderConfig, error) {
+	if providerConfig, ok := p.providers.Load(profile.Name); ok {
+		return providerConfig.(*ProviderConfig), nil
+	}
+
+	providerConfig, err := createProvider(ctx, p.cfg, profile, ProviderOptions{Verbose: p.verbose, OfferPull: p.offerPull && primary})
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := p.providers.LoadOrStore(profile.Name, providerConfig)
+	return actual.(*ProviderConfig), nil
+}
+
+// preparePrompt builds the prompt for a question to a provider, warning if it
+// doesn't fit the provider's context window
+func (p *pipeline) preparePrompt(ctx context.Context, providerConfig *ProviderConfig, command, section, manPage, question string) *prompt.Builder {
+	promptBuilder, _ := p.buildPrompt(ctx, providerConfig, command, section, manPage, question)
+	userPrompt := promptBuilder.UserPrompt()
+
+	// Count tokens and warn if exceeds context window
+	actualTokens := countTokens(ctx, providerConfig.tokenizer(), userPrompt, p.verbose)
+	if actualTokens > providerConfig.ContextWindow {
+		fmt.Fprintf(os.Stderr, "⚠️  Warning: Prompt (%d tokens) exceeds %d token context window.\n", actualTokens, providerConfig.ContextWindow)
+		fmt.Fprintf(os.Stderr, "    Try a more specific section: heyman <section> %s <question>\n\n", command)
+	}
+
+	// Debug output
+	if p.debug {
+		fmt.Fprintf(os.Stderr, "\n=== DEBUG: System Prompt ===\n%s\n", promptBuilder.SystemPrompt())
+		fmt.Fprintf(os.Stderr, "\n=== DEBUG: User Prompt (first 500 chars) ===\n%s\n", truncate(userPrompt, 500))
+		fmt.Fprintf(os.Stderr, "\n=== DEBUG: User Prompt length: %d chars ===\n\n", len(userPrompt))
+	}
+	return promptBuilder
+}
+
+// buildPrompt builds the prompt for a question to a provider, with few-shot
+// examples from accepted answers, and reports the platform it was built for
+func (p *pipeline) buildPrompt(ctx context.Context, providerConfig *ProviderConfig, command, section, manPage, question string) (*prompt.Builder, manpage.Platform) {
+	platform := p.platform(command, manPage)
+	promptBuilder := prompt.NewBuilder(command, manPage, question, p.explain).
+		WithTemplates(providerConfig.Templates).
+		WithSection(section).
+		WithPlatform(platform.Describe(command)).
+		WithCorrection(p.correction).
+		WithAlternatives(p.alternatives).
+		WithCheatsheet(p.cheatsheet).
+		WithStructuredOutput(providerConfig.StructuredOutput)
+	if fewShot := p.fewShotExamples(ctx, providerConfig, promptBuilder, command, question, platform); len(fewShot) > 0 {
+		promptBuilder = promptBuilder.WithExamples(fewShot)
+	}
+	return promptBuilder, platform
+}
+
+// parse parses a response into its valid commands: one, up to p.alternatives
+// when alternatives were requested, or up to p.cheatsheet cheat sheet
+// entries. If none are valid the error explains why.
+func (p *pipeline) parse(responseParser *parser.Parser, content string) ([]parser.ParsedResponse, error) {
+	limit := 1
+	candidates := []parser.ParsedResponse{responseParser.Parse(content)}
+	switch {
+	case p.cheatsheet > 0:
+		limit = p.cheatsheet
+		candidates = responseParser.ParseCheatsheet(content)
+	case p.alternatives > 1:
+		limit = p.alternatives
+		candidates = responseParser.ParseAlternatives(content)
+	}
+
+	var valid []parser.ParsedResponse
+	for _, candidate := range candidates {
+		if !candidate.Valid {
+			if p.verbose && len(candidates) > 1 {
+				fmt.Printf("Dropping invalid command: %v\n", candidate.Error)
+			}
+			continue
+		}
+		valid = append(valid, candidate)
+	}
+
+	if len(valid) == 0 {
+		return nil, candidates[0].Error
+	}
+	if len(valid) > limit {
+		valid = valid[:limit]
+	}
+	return valid, nil
+}
+
+// answerTokens is the MaxTokens requested for a single answer, and
+// extraAnswerTokens the room added for each further alternative or cheat sheet
+// entry, which in structured output carries its own flags and citations
+const (
+	answerTokens      = 2000
+	extraAnswerTokens = 600
+)
+
+// maxResponseTokens is the MaxTokens to request: enough for every alternative
+// or cheat sheet entry asked for, so the response isn't cut off mid-JSON
+func (p *pipeline) maxResponseTokens() int {
+	answers := max(p.alternatives, p.cheatsheet, 1)
+	return answerTokens + (answers-1)*extraAnswerTokens
+}
+
+// fewShotExamples picks the user's accepted answers for the command that are
+// most relevant to the question, as many as the profile allows and the
+// context window has room for after the prompt and response
+func (p *pipeline) fewShotExamples(ctx context.Context, providerConfig *ProviderConfig, promptBuilder *prompt.Builder, command, question string, platform manpage.Platform) []prompt.Example {
+	// A cheat sheet would repeat the examples rather than be guided by them
+	limit := providerConfig.Profile.GetExamples()
+	if limit == 0 || p.cheatsheet > 0 {
+		return nil
+	}
+
+	accepted, err := examples.Open().Find(command, platform.Key(), question, limit)
+	if err != nil {
+		if p.verbose {
+			fmt.Printf("Warning: failed to load examples: %v\n", err)
+		}
+		return nil
+	}
+
+	tokenizer := providerConfig.tokenizer()
+	budget := providerConfig.ContextWindow - p.maxResponseTokens() -
+		tokenizer.Count(ctx, promptBuilder.SystemPrompt()+promptBuilder.UserPrompt())
+
+	var selected []prompt.Example
+	for _, example := range accepted {
+		cost := tokenizer.Count(ctx, fmt.Sprintf("User asks: %q\nAccepted command: %s\n", example.Question, example.Answer))
+		if cost > budget {
+			break
+		}
+		budget -= cost
+		selected = append(selected, prompt.Example{Question: example.Question, Command: example.Answer})
+	}
+
+	if p.verbose && len(accepted) > 0 {
+		fmt.Printf("Examples: %d of %d accepted answers fit in the context window\n", len(selected), len(accepted))
+	}
+	return selected
+}
+
+// platform detects which implementation of a command is installed, once per
+// command for the life of the pipeline
+func (p *pipeline) platform(command, manPage string) manpage.Platform {
+	if platform, ok := p.platforms.Load(command); ok {
+		return platform.(manpage.Platform)
+	}
+
+	platform := detectPlatform(command, manPage, p.probeVersion)
+	if p.verbose {
+		fmt.Printf("Platform: %s", platform.Describe(command))
+		if platform.Source != "" {
+			fmt.Printf(" (detected from %s)", platform.Source)
+		}
+		fmt.Println()
+	}
+	p.platforms.Store(command, platform)
+	return platform
+}
+
+// cacheKey returns the cache key for a question, distinguishing alternatives
+// and cheat sheets from single answers, custom prompts from built-in ones, and
+// platforms from each other
+func (p *pipeline) cacheKey(providerConfig *ProviderConfig, command, section, question string, platform manpage.Platform) cache.Key {
+	key := cache.NewKey(command, question, providerConfig.Profile.Model)
+	key.Platform = platform.Key()
+	key.Section = section
+
+	var variant []string
+	if p.cheatsheet > 0 {
+		variant = append(variant, fmt.Sprintf("cheatsheet=%d", p.cheatsheet))
+	} else if p.alternatives > 1 {
+		variant = append(variant, fmt.Sprintf("alternatives=%d", p.alternatives))
+	}
+	if providerConfig.Templates != nil {
+		if fingerprint := providerConfig.Templates.Fingerprint(); fingerprint != "" {
+			variant = append(variant, "prompts="+fingerprint)
+		}
+	}
+	key.Variant = strings.Join(variant, ",")
+	return key
+}
+
+// restoreVariant sets up the pipeline to give the answer shape recorded in a
+// cache key's variant, so asking again caches under the same key
+func (p *pipeline) restoreVariant(variant string) {
+	for _, part := range strings.Split(variant, ",") {
+		name, value, _ := strings.Cut(part, "=")
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			continue
+		}
+		switch name {
+		case "cheatsheet":
+			p.cheatsheet = n
+		case "alternatives":
+			p.alternatives = n
+		}
+	}
+}
+
+// responseSchema returns the JSON schema to constrain the response to, or nil
+// if the prompt asks for free text
+func responseSchema(promptBuilder *prompt.Builder) *llm.Schema {
+	if !promptBuilder.Structured() {
+		return nil
+	}
+	if promptBuilder.Cheatsheet() > 0 {
+		return &llm.Schema{
+			Name:   parser.CheatsheetSchemaName,
+			Schema: parser.CheatsheetSchema(),
+		}
+	}
+	if promptBuilder.Alternatives() > 0 {
+		return &llm.Schema{
+			Name:   parser.AlternativesSchemaName,
+			Schema: parser.AlternativesSchema(),
+		}
+	}
+	return &llm.Schema{
+		Name:   parser.ResponseSchemaName,
+		Schema: parser.ResponseSchema(),
+	}
+}
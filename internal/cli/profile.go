@@ -0,0 +1,472 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/ollama/ollama/envconfig"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func profileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Add, remove, rename, show and edit profiles",
+		Long: `Manage the profiles in the config file without editing it by hand.
+
+Example:
+  heyman profile add --provider openai --model gpt-4o --name openai-gpt4o
+  heyman profile show openai-gpt4o
+  heyman profile edit openai-gpt4o
+  heyman profile rename openai-gpt4o gpt4o
+  heyman profile remove gpt4o`,
+	}
+
+	cmd.AddCommand(profileAddCmd())
+	cmd.AddCommand(profileRemoveCmd())
+	cmd.AddCommand(profileRenameCmd())
+	cmd.AddCommand(profileShowCmd())
+	cmd.AddCommand(profileEditCmd())
+	return cmd
+}
+
+// checkProfileName checks that a new profile name is safe to use as a TOML
+// key and on the command line
+func checkProfileName(name string) error {
+	if name == "" || sanitizeProfileName(name) != name {
+		return fmt.Errorf("invalid profile name %q: only letters, digits, hyphens and underscores allowed", name)
+	}
+	return nil
+}
+
+func profileAddCmd() *cobra.Command {
+	var name, provider, model string
+	var contextWindow int
+	var fallback []string
+	var makeDefault, force bool
+
+	cmd := &cobra.Command{
+		Use:   "add --provider <provider> --model <model> [--name <name>]",
+		Short: "Add a profile",
+		Long: fmt.Sprintf(`Add a profile without prompts, for scripts. The name defaults to the
+provider and model, e.g. openai-gpt-4o. The first profile becomes the
+default.
+
+Providers: %s
+
+Example:
+  heyman profile add --provider openai --model gpt-4o --name openai-gpt4o
+  heyman profile add --provider ollama --model qwen2.5-coder:7b --fallback openai-gpt4o --default`, strings.Join(config.Providers, ", ")),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if name == "" {
+				name = sanitizeProfileName(provider + "-" + strings.Split(model, ":")[0])
+			}
+			if err := checkProfileName(name); err != nil {
+				return err
+			}
+			if _, exists := cfg.Profiles[name]; exists && !force {
+				return fmt.Errorf("profile %q already exists (use --force to replace it)", name)
+			}
+
+			profile := config.Profile{
+				Name:          name,
+				Provider:      provider,
+				Model:         model,
+				ContextWindow: contextWindow,
+				Fallback:      fallback,
+			}
+			if err := validateProfile(cfg, profile); err != nil {
+				return err
+			}
+
+			cfg.AddProfile(name, profile)
+			if makeDefault || cfg.DefaultProfile == "" {
+				cfg.DefaultProfile = name
+			}
+			if err := config.Save(cfg); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+
+			fmt.Printf("✓ Added profile %s (%s %s)\n", name, provider, model)
+			if cfg.DefaultProfile == name {
+				fmt.Println("  Set as the default profile")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "profile name (default: <provider>-<model>)")
+	cmd.Flags().StringVar(&provider, "provider", "", "provider: "+strings.Join(config.Providers, ", "))
+	cmd.Flags().StringVar(&model, "model", "", "model name")
+	cmd.Flags().IntVar(&contextWindow, "context-window", 0, "context window in tokens (default: 8192, detected for Ollama)")
+	cmd.Flags().StringSliceVar(&fallback, "fallback", nil, "profiles to fall back to, in order")
+	cmd.Flags().BoolVar(&makeDefault, "default", false, "make this the default profile")
+	cmd.Flags().BoolVar(&force, "force", false, "replace an existing profile with the same name")
+	cmd.MarkFlagRequired("provider")
+	cmd.MarkFlagRequired("model")
+	return cmd
+}
+
+func profileRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove <profile-name>",
+		Aliases: []string{"rm"},
+		Short:   "Remove a profile",
+		Long: `Remove a profile. Other profiles stop falling back to it, and if it was the
+default there is no default until you set one with 'heyman set-profile'.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			wasDefault := cfg.DefaultProfile == name
+			if err := cfg.RemoveProfile(name); err != nil {
+				return err
+			}
+			if err := config.Save(cfg); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+
+			fmt.Printf("✓ Removed profile %s\n", name)
+			if wasDefault {
+				fmt.Println("  It was the default profile. Set a new one with: heyman set-profile <profile-name>")
+			}
+			return nil
+		},
+	}
+}
+
+func profileRenameCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "rename <old-name> <new-name>",
+		Aliases: []string{"mv"},
+		Short:   "Rename a profile",
+		Long: `Rename a profile, updating the default profile and fallbacks that refer to it.
+
+Answers cached for the profile are kept; the cache is keyed by model, not
+profile name.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			oldName, newName := args[0], args[1]
+			if err := checkProfileName(newName); err != nil {
+				return err
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			if err := cfg.RenameProfile(oldName, newName); err != nil {
+				return err
+			}
+			if err := config.Save(cfg); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+
+			fmt.Printf("✓ Renamed profile %s to %s\n", oldName, newName)
+			return nil
+		},
+	}
+}
+
+func profileShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show [profile-name]",
+		Short: "Show a profile's effective settings",
+		Long: `Show every setting of a profile (the active one by default), with defaults
+filled in for settings the config file leaves out.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, profile, err := loadProfileArg(args)
+			if err != nil {
+				return err
+			}
+			return showProfile(cfg, profile)
+		},
+	}
+}
+
+// loadProfileArg loads the config and the profile named in args, or the
+// active profile if none is named
+func loadProfileArg(args []string) (*config.Config, *config.Profile, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	if len(args) == 0 {
+		profile, err := cfg.GetActiveProfile()
+		if err != nil {
+			return nil, nil, fmt.Errorf("no profile configured: %w\nRun 'heyman setup' to configure", err)
+		}
+		return cfg, profile, nil
+	}
+
+	profile, err := cfg.GetProfile(args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, profile, nil
+}
+
+// showProfile prints a profile's settings as heyman will use them
+func showProfile(cfg *config.Config, profile *config.Profile) error {
+	templates, err := loadTemplates(profile)
+	if err != nil {
+		return err
+	}
+
+	title := profile.Name
+	if profile.Name == cfg.DefaultProfile {
+		title += " (default)"
+	}
+	fmt.Printf("Profile: %s\n\n", title)
+
+	setting := func(name, value string) {
+		fmt.Printf("  %-20s %s\n", name+":", value)
+	}
+	orDefault := func(configured bool, value string) string {
+		if configured {
+			return value
+		}
+		return value + " (default)"
+	}
+
+	setting("provider", profile.Provider)
+	setting("model", profile.Model)
+
+	switch {
+	case profile.ContextWindow > 0:
+		setting("context_window", fmt.Sprint(profile.ContextWindow))
+	case profile.Provider == "ollama":
+		setting("context_window", fmt.Sprintf("detected from the model, up to %d", profile.GetMaxContextWindow()))
+		setting("max_context_window", orDefault(profile.MaxContextWindow > 0, fmt.Sprint(profile.GetMaxContextWindow())))
+	default:
+		setting("context_window", orDefault(false, fmt.Sprint(profile.GetContextWindow())))
+	}
+
+	if profile.StructuredOutput != nil {
+		setting("structured_output", fmt.Sprint(*profile.StructuredOutput))
+	} else {
+		setting("structured_output", "when the model supports it (default)")
+	}
+	setting("examples", orDefault(profile.Examples != nil, fmt.Sprint(profile.GetExamples())))
+
+	fallback := "none"
+	if len(profile.Fallback) > 0 {
+		fallback = strings.Join(profile.Fallback, ", ")
+	}
+	setting("fallback", fallback)
+
+	setting("connect_timeout", orDefault(profile.ConnectTimeout > 0, profile.GetConnectTimeout().String()))
+	setting("first_token_timeout", orDefault(profile.FirstTokenTimeout > 0, profile.GetFirstTokenTimeout().String()))
+	setting("timeout", orDefault(profile.Timeout > 0, profile.GetTimeout().String()))
+
+	switch profile.Provider {
+	case "ollama":
+		if profile.KeepAlive != nil {
+			setting("keep_alive", time.Duration(*profile.KeepAlive).String())
+		} else {
+			setting("keep_alive", "server default")
+		}
+		host := envconfig.Host().String()
+		if os.Getenv("OLLAMA_HOST") != "" {
+			host += " (OLLAMA_HOST)"
+		}
+		setting("host", host)
+	case "openai":
+		apiKey := "OPENAI_API_KEY not set"
+		if cfg.GetAPIKey("openai") != "" {
+			apiKey = "from OPENAI_API_KEY"
+		}
+		setting("api key", apiKey)
+	}
+
+	if len(profile.Options) > 0 {
+		keys := make([]string, 0, len(profile.Options))
+		for key := range profile.Options {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Println("\n  Options:")
+		for _, key := range keys {
+			fmt.Printf("    %s = %v\n", key, profile.Options[key])
+		}
+	}
+
+	fmt.Println("\n  Prompts:")
+	width := 0
+	for _, source := range templates.Sources() {
+		width = max(width, len(source.Name)+1)
+	}
+	for _, source := range templates.Sources() {
+		fmt.Printf("    %-*s %s\n", width, source.Name+":", source.Source)
+	}
+	return nil
+}
+
+// validateProfile checks a new or edited profile: its settings, fallbacks
+// and prompt template overrides
+func validateProfile(cfg *config.Config, profile config.Profile) error {
+	if err := cfg.ValidateProfile(profile); err != nil {
+		return fmt.Errorf("profile %s: %w", profile.Name, err)
+	}
+	_, err := loadTemplates(&profile)
+	return err
+}
+
+func profileEditCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit [profile-name]",
+		Short: "Edit a profile in $EDITOR",
+		Long: `Open a profile (the active one by default) in $VISUAL or $EDITOR, falling
+back to vi. The profile is checked when you save and quit: unknown settings,
+unsupported providers, missing fallbacks and broken prompt templates are
+reported, and you can edit again or abandon the change. Empty the file to
+cancel.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, profile, err := loadProfileArg(args)
+			if err != nil {
+				return err
+			}
+
+			edited, changed, err := editProfile(cfg, *profile)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				fmt.Println("No changes")
+				return nil
+			}
+
+			cfg.AddProfile(profile.Name, edited)
+			if err := config.Save(cfg); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+			fmt.Printf("✓ Saved profile %s\n", profile.Name)
+			return nil
+		},
+	}
+}
+
+// editProfile opens a profile in the editor until it is saved valid, and
+// reports whether it changed
+func editProfile(cfg *config.Config, profile config.Profile) (config.Profile, bool, error) {
+	original, err := config.MarshalProfile(profile)
+	if err != nil {
+		return profile, false, err
+	}
+
+	file, err := os.CreateTemp("", "heyman-profile-*.toml")
+	if err != nil {
+		return profile, false, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	header := fmt.Sprintf("# Settings for profile %s. Save and quit to apply; empty the file to cancel.\n", profile.Name)
+	_, err = file.WriteString(header + string(original))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return profile, false, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	for {
+		if err := runEditor(file.Name()); err != nil {
+			return profile, false, err
+		}
+
+		data, err := os.ReadFile(file.Name())
+		if err != nil {
+			return profile, false, fmt.Errorf("failed to read edited profile: %w", err)
+		}
+		if isBlankTOML(data) {
+			return profile, false, fmt.Errorf("edit cancelled")
+		}
+
+		edited, err := config.UnmarshalProfile(data)
+		if err == nil {
+			edited.Name = profile.Name
+			err = validateProfile(cfg, edited)
+		}
+		if err == nil {
+			updated, _ := config.MarshalProfile(edited)
+			return edited, !bytes.Equal(updated, original), nil
+		}
+
+		// Without a terminal to ask at, the edit is abandoned
+		if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+			return profile, false, fmt.Errorf("profile %s not changed: %w", profile.Name, err)
+		}
+		if !confirmReedit(err) {
+			return profile, false, fmt.Errorf("profile %s not changed", profile.Name)
+		}
+	}
+}
+
+// runEditor opens a file in the user's editor and waits for it to exit
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// The editor may come with arguments, e.g. "code --wait"
+	fields := strings.Fields(editor)
+	editorCmd := exec.Command(fields[0], append(fields[1:], path)...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	if err := editorCmd.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
+
+// isBlankTOML reports whether a file has nothing but comments and whitespace
+func isBlankTOML(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// confirmReedit reports a problem with an edited profile and asks whether to
+// edit again
+func confirmReedit(problem error) bool {
+	fmt.Fprintf(os.Stderr, "❌ %v\n", problem)
+	fmt.Fprint(os.Stderr, "Edit again? [Y/n] ")
+	reply, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(reply)) {
+	case "", "y", "yes":
+		return true
+	}
+	return false
+}
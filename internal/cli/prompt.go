@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/prompt"
+	"github.com/spf13/cobra"
+)
+
+// loadTemplates loads the prompt templates for a profile: the built-in ones,
+// overridden by files in the prompts directory and then by the profile's prompts
+func loadTemplates(profile *config.Profile) (*prompt.Templates, error) {
+	templates, err := prompt.LoadTemplates(config.GetPromptsDir(), profile.Prompts)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", profile.Name, err)
+	}
+	return templates, nil
+}
+
+func promptCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prompt",
+		Short: "Inspect prompt templates",
+		Long: fmt.Sprintf(`Prompts are Go text/template files. Override one for every profile by
+creating <name>.tmpl in:
+  %s
+or for a single profile with a prompts table in its config:
+  [profiles.ollama-llama.prompts]
+  default = "small-model.tmpl"
+
+Templates: %s
+
+Variables: .Command .Section .Platform .ManPage .Question .Explain
+.Structured .Alternatives .Examples`, config.GetPromptsDir(), strings.Join(prompt.TemplateNames, ", ")),
+	}
+
+	cmd.AddCommand(promptShowCmd())
+	return cmd
+}
+
+func promptShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show [template...]",
+		Short: "Print the effective prompt templates for the active profile",
+		Long: `Print the prompt templates the active profile (or --profile) uses, and where
+each comes from. Templates are validated as they load, so errors in overrides
+are reported here too.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range args {
+				if !slices.Contains(prompt.TemplateNames, name) {
+					return fmt.Errorf("unknown prompt template %q (expected one of: %s)", name, strings.Join(prompt.TemplateNames, ", "))
+				}
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			activeProfile, err := cfg.GetActiveProfile()
+			if err != nil {
+				return fmt.Errorf("no profile configured: %w\nRun 'heyman setup' to configure", err)
+			}
+
+			templates, err := loadTemplates(activeProfile)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Profile: %s\n", activeProfile.Name)
+			for _, source := range templates.Sources() {
+				if len(args) > 0 && !slices.Contains(args, source.Name) {
+					continue
+				}
+				fmt.Printf("\n=== %s (%s) ===\n", source.Name, source.Source)
+				fmt.Println(strings.TrimRight(source.Text, "\n"))
+			}
+			return nil
+		},
+	}
+}
@@ -2,76 +2,126 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/alecf/heyman/internal/config"
 	"github.com/alecf/heyman/internal/llm"
+	"github.com/alecf/heyman/internal/prompt"
+	"github.com/alecf/heyman/internal/tokens"
 )
 
-// ProviderConfig holds the provider and its context window
+// ProviderConfig holds the provider, its context window and the profile it was created from
 type ProviderConfig struct {
-	Provider      llm.Provider
-	ContextWindow int
+	Provider         llm.Provider
+	ContextWindow    int
+	Profile          *config.Profile
+	StructuredOutput bool              // Request JSON-schema constrained responses
+	Templates        *prompt.Templates // Prompt templates for the profile; nil means built-in
+	Tokenizer        tokens.Tokenizer  // Counts tokens for the model; nil means tokens.For the profile
 }
 
+// tokenizer returns the tokenizer for the provider's model
+func (c *ProviderConfig) tokenizer() tokens.Tokenizer {
+	if c.Tokenizer != nil {
+		return c.Tokenizer
+	}
+	return tokens.For(c.Profile.Provider, c.Profile.Model)
+}
+
+// ProviderOptions controls what creating a provider may do besides building it
+type ProviderOptions struct {
+	Verbose   bool // Report detected settings
+	DryRun    bool // Don't contact the provider; Ollama context windows come from the cache or the profile
+	OfferPull bool // Offer to pull a missing Ollama model. Only for a person at the terminal running one query.
+}
+
+// createProvider is the factory commands use to build providers.
+// Tests replace it to inject an llm.FakeProvider.
+var createProvider = CreateProvider
+
 // CreateProvider initializes a provider based on the profile configuration
-func CreateProvider(ctx context.Context, cfg *config.Config, profile *config.Profile, verbose bool) (*ProviderConfig, error) {
+func CreateProvider(ctx context.Context, cfg *config.Config, profile *config.Profile, opts ProviderOptions) (*ProviderConfig, error) {
+	templates, err := loadTemplates(profile)
+	if err != nil {
+		return nil, err
+	}
+
 	var provider llm.Provider
 	var contextWindow int
-	var err error
+	tokenizer := tokens.For(profile.Provider, profile.Model)
 
 	switch profile.Provider {
 	case "openai":
 		apiKey := cfg.GetAPIKey("openai")
+		if apiKey == "" && llm.IsReplaying() {
+			apiKey = "replay" // Replayed traffic never reaches the API
+		}
 		if apiKey == "" {
 			return nil, fmt.Errorf("OpenAI API key not found. Set OPENAI_API_KEY environment variable")
 		}
-		provider, err = llm.NewOpenAIProvider(apiKey)
+		provider, err = llm.NewOpenAIProvider(apiKey, profile.GetConnectTimeout())
 		if err != nil {
 			return nil, fmt.Errorf("failed to create OpenAI provider: %w", err)
 		}
 		contextWindow = profile.GetContextWindow()
 
 	case "ollama":
-		provider, err = llm.NewOllamaProvider()
+		ollamaProvider, err := newOllamaProvider(profile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Ollama provider: %w", err)
 		}
+		provider = ollamaProvider
+
+		var info *llm.ModelInfo
+		if opts.DryRun {
+			info, _ = cachedOllamaModelInfo(cfg, profile.Model)
+		} else {
+			tokenizer = tokens.Ollama(ollamaTokenize(cfg, ollamaProvider, profile.Model), tokenizer)
+
+			info, err = ollamaModelInfo(ctx, cfg, ollamaProvider, profile.Model, opts.OfferPull)
+			if errors.Is(err, llm.ErrModelNotFound) {
+				return nil, err
+			}
+		}
 
 		// Auto-detect context window from Ollama if not configured
-		if profile.ContextWindow == 0 {
-			if ollamaProvider, ok := provider.(*llm.OllamaProvider); ok {
-				if ctxWindow, err := ollamaProvider.GetModelContextWindow(ctx, profile.Model); err == nil {
-					// Cap at 8k tokens for practical memory/performance reasons
-					// Models may advertise larger context windows than Ollama can handle
-					const maxPracticalContext = 8192
-					if ctxWindow > maxPracticalContext {
-						contextWindow = maxPracticalContext
-						if verbose {
-							fmt.Printf("Auto-detected context window: %d tokens (capped at %d for memory)\n", ctxWindow, maxPracticalContext)
-						}
-					} else {
-						contextWindow = ctxWindow
-						if verbose {
-							fmt.Printf("Auto-detected context window: %d tokens\n", ctxWindow)
-						}
-					}
-				} else {
-					contextWindow = profile.GetContextWindow() // Fallback to default
-				}
-			} else {
-				contextWindow = profile.GetContextWindow()
+		contextWindow = profile.ContextWindow
+		if contextWindow == 0 && info != nil {
+			contextWindow = info.ContextWindow(profile.GetMaxContextWindow())
+			if opts.Verbose && contextWindow > 0 {
+				fmt.Printf("Auto-detected context window: %d tokens%s\n", contextWindow, contextWindowSource(info, contextWindow))
 			}
-		} else {
-			contextWindow = profile.ContextWindow
+		}
+		if contextWindow == 0 {
+			contextWindow = profile.GetContextWindow() // Fallback to default
 		}
 
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", profile.Provider)
 	}
 
+	// Retry rate limits and transient outages before giving up (or falling back)
+	retryProvider := llm.NewRetryProvider(provider, llm.DefaultRetryPolicy())
+	if opts.Verbose {
+		retryProvider.OnRetry = func(attempt int, err error, delay time.Duration) {
+			fmt.Printf("Attempt %d failed (%v), retrying in %s\n", attempt, err, delay.Round(time.Millisecond))
+		}
+	}
+
+	// Use structured output where the model supports it, unless the profile says otherwise
+	structuredOutput := provider.SupportsStructuredOutput(profile.Model)
+	if profile.StructuredOutput != nil {
+		structuredOutput = *profile.StructuredOutput
+	}
+
 	return &ProviderConfig{
-		Provider:      provider,
-		ContextWindow: contextWindow,
+		Provider:         retryProvider,
+		ContextWindow:    contextWindow,
+		Profile:          profile,
+		StructuredOutput: structuredOutput,
+		Templates:        templates,
+		Tokenizer:        tokenizer,
 	}, nil
 }
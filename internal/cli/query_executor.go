@@ -2,8 +2,10 @@ package cli
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/alecf/heyman/internal/config"
 	"github.com/alecf/heyman/internal/llm"
@@ -16,22 +18,78 @@ type QueryOptions struct {
 	Verbose      bool
 	Debug        bool
 	Profile      *config.Profile
+	OnChunk      func(content string) // Called with each piece of the response as it streams
 }
 
-// ExecuteQuery sends a query to the LLM provider with appropriate progress indicators
+// ExecuteQuery sends a query to the LLM provider with appropriate progress indicators,
+// within the profile's time limits (the first-token limit applies only to streamed queries)
 func ExecuteQuery(ctx context.Context, provider llm.Provider, req llm.QueryRequest, opts QueryOptions) (*llm.QueryResponse, error) {
-	if opts.ShowProgress {
-		// Use streaming with progress indicators
-		return executeStreamingQuery(ctx, provider, req, opts)
+	if opts.Profile != nil {
+		limit := opts.Profile.GetTimeout()
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, limit, &llm.TimeoutError{Model: req.Model, Limit: limit, Wait: "response"})
+		defer cancel()
+	}
+
+	var resp *llm.QueryResponse
+	var err error
+	if opts.ShowProgress || opts.OnChunk != nil {
+		// Use streaming with progress indicators (or to pass chunks on)
+		resp, err = executeStreamingQuery(ctx, provider, req, opts)
+	} else {
+		// Use non-streaming query for verbose/debug modes
+		resp, err = provider.Query(ctx, req)
+	}
+
+	// Report the time limit rather than the cancellation it caused
+	if err != nil {
+		if timeout := timeoutCause(ctx); timeout != nil {
+			return nil, timeout
+		}
+	}
+	return resp, err
+}
+
+// streamError stops the spinner and returns the error that ended a stream:
+// the time limit that cancelled it, the caller's cancellation, or the
+// provider's failure
+func streamError(ctx context.Context, spin *spinner.Spinner, err error) error {
+	spin.Stop()
+	if timeout := timeoutCause(ctx); timeout != nil {
+		return timeout
 	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("LLM query failed: %w", err)
+}
 
-	// Use non-streaming query for verbose/debug modes
-	return provider.Query(ctx, req)
+// timeoutCause returns the time limit that cancelled ctx, or nil if it was
+// not cancelled by one
+func timeoutCause(ctx context.Context) error {
+	var timeout *llm.TimeoutError
+	if errors.As(context.Cause(ctx), &timeout) {
+		return timeout
+	}
+	return nil
 }
 
 func executeStreamingQuery(ctx context.Context, provider llm.Provider, req llm.QueryRequest, opts QueryOptions) (*llm.QueryResponse, error) {
+	// Stop the stream if the consumer returns early, so its goroutine exits
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Give up if the model takes too long to start answering (e.g. a hung model load)
+	limit := opts.Profile.GetFirstTokenTimeout()
+	firstToken := time.AfterFunc(limit, func() {
+		cancel(&llm.TimeoutError{Model: req.Model, Limit: limit, Wait: "first token"})
+	})
+	defer firstToken.Stop()
+
 	spin := spinner.New(fmt.Sprintf("Sending query to %s...", opts.Profile.Model))
-	spin.Start()
+	if opts.ShowProgress {
+		spin.Start()
+	}
 
 	chunkCh, errCh := provider.StreamQuery(ctx, req)
 
@@ -45,12 +103,27 @@ streamLoop:
 		select {
 		case chunk, ok := <-chunkCh:
 			if !ok {
+				// The stream ended early: pick up the error sent alongside
+				if errCh != nil {
+					if err, ok := <-errCh; ok && err != nil {
+						return nil, streamError(ctx, spin, err)
+					}
+				}
+				if ctx.Err() != nil {
+					return nil, streamError(ctx, spin, ctx.Err())
+				}
 				break streamLoop
 			}
 
+			firstToken.Stop()
 			if firstChunk && !chunk.IsComplete {
 				firstChunk = false
-				spin.Update(fmt.Sprintf("Getting command from %s...", opts.Profile.Model))
+				if opts.OnChunk != nil {
+					// The response is about to be shown as it arrives
+					spin.Stop()
+				} else {
+					spin.Update(fmt.Sprintf("Getting command from %s...", opts.Profile.Model))
+				}
 			}
 
 			if chunk.IsComplete {
@@ -60,10 +133,19 @@ streamLoop:
 			}
 
 			content.WriteString(chunk.Content)
+			if opts.OnChunk != nil && chunk.Content != "" {
+				opts.OnChunk(chunk.Content)
+			}
+
+		case err, ok := <-errCh:
+			if !ok || err == nil {
+				errCh = nil // Closed without error; keep reading chunks
+				continue
+			}
+			return nil, streamError(ctx, spin, err)
 
-		case err := <-errCh:
-			spin.Stop()
-			return nil, fmt.Errorf("LLM query failed: %w", err)
+		case <-ctx.Done():
+			return nil, streamError(ctx, spin, ctx.Err())
 		}
 	}
 
@@ -1,12 +1,16 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/alecf/heyman/internal/cache"
 	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/examples"
 	"github.com/alecf/heyman/internal/llm"
 	"github.com/alecf/heyman/internal/manpage"
 	"github.com/alecf/heyman/internal/output"
@@ -15,6 +19,7 @@ import (
 	"github.com/alecf/heyman/internal/prompt"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 var (
@@ -27,7 +32,46 @@ var (
 	quiet   bool
 )
 
+// manPages fetches man pages for the life of the process, so commands that
+// answer many questions (batch, serve, mcp) run man once per page. It keeps
+// only recently used pages and retries failures, so a long-running server
+// neither grows without bound nor keeps serving a transient error.
+var manPages = manpage.NewFetcher()
+
+// fetchManPage retrieves a man page; tests replace it to avoid depending on installed pages
+var fetchManPage = func(command, section string) (string, error) {
+	return manPages.Fetch(command, section)
+}
+
+// detectPlatform works out which implementation of a command is installed,
+// remembering what --version probes find in the cache directory; tests
+// replace it so answers don't depend on the host
+var detectPlatform = func(command, manPage string, probeVersion bool) manpage.Platform {
+	// Probed variants have their own expiry, so the answer age limit is unused
+	return manPages.DetectPlatform(command, manPage, probeVersion, cache.New(0))
+}
+
+// stdoutIsTerminal reports whether answers are printed to a terminal, where
+// explanations can be shown as they stream; tests replace it
+var stdoutIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
 func Execute(version, commit, date string) error {
+	// Ctrl-C cancels in-flight requests so commands stop cleanly; a second one exits at once
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	err := newRootCmd(version, commit, date).ExecuteContext(ctx)
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("interrupted")
+	}
+	return err
+}
+
+// newRootCmd builds the root command with all flags and subcommands
+func newRootCmd(version, commit, date string) *cobra.Command {
 	rootCmd := &cobra.Command{
 		Use:   "heyman [flags] <command> <question>",
 		Short: "LLM-powered man page Q&A",
@@ -37,8 +81,14 @@ queries about command-line tools.
 Example:
   heyman lsof how do I list the ports that a pid is listening on`,
 		Version: fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, date),
-		Args:    cobra.MinimumNArgs(2),
-		RunE:    run,
+		Args: func(cmd *cobra.Command, args []string) error {
+			// "heyman !N" shows an answer from the history
+			if len(args) == 1 && isHistoryRef(args[0]) {
+				return nil
+			}
+			return cobra.MinimumNArgs(2)(cmd, args)
+		},
+		RunE: run,
 	}
 
 	// Global flags
@@ -48,21 +98,33 @@ Example:
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "show operation details")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "suppress progress messages")
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "show full request/response details")
-	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "show prompt without making API call")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "show the request that would be sent without querying the model")
 
 	// Output mode flags
-	rootCmd.Flags().BoolP("explain", "e", false, "include explanation (streaming)")
+	rootCmd.Flags().BoolP("explain", "e", false, "include explanation, streamed as it arrives on a terminal")
 	rootCmd.Flags().BoolP("json", "j", false, "JSON output with metadata")
 	rootCmd.Flags().BoolP("tokens", "t", false, "show token usage and costs")
 	rootCmd.Flags().BoolP("copy", "c", false, "copy command to clipboard")
+	rootCmd.Flags().Int("alternatives", 1, "show up to N alternative commands with trade-offs")
 
 	// Management commands
 	rootCmd.AddCommand(setupCmd())
 	rootCmd.AddCommand(setProfileCmd())
 	rootCmd.AddCommand(listProfilesCmd())
+	rootCmd.AddCommand(profileCmd())
 	rootCmd.AddCommand(testConfigCmd())
+	rootCmd.AddCommand(warmCmd())
 	rootCmd.AddCommand(cacheStatsCmd())
 	rootCmd.AddCommand(clearCacheCmd())
+	rootCmd.AddCommand(evalCmd())
+	rootCmd.AddCommand(compareCmd())
+	rootCmd.AddCommand(promptCmd())
+	rootCmd.AddCommand(feedbackCmd())
+	rootCmd.AddCommand(historyCmd())
+	rootCmd.AddCommand(serveCmd())
+	rootCmd.AddCommand(mcpCmd(version))
+	rootCmd.AddCommand(batchCmd())
+	rootCmd.AddCommand(cheatsheetCmd())
 
 	// Bind flags to viper
 	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
@@ -75,10 +137,14 @@ Example:
 	viper.AutomaticEnv()
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 
-	return rootCmd.Execute()
+	return rootCmd
 }
 
 func run(cmd *cobra.Command, args []string) error {
+	if len(args) == 1 && isHistoryRef(args[0]) {
+		return showHistoryRef(cmd, args[0])
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -95,6 +161,11 @@ func run(cmd *cobra.Command, args []string) error {
 	}
 	question := strings.Join(questionParts, " ")
 
+	alternativesFlag, _ := cmd.Flags().GetInt("alternatives")
+	if alternativesFlag < 1 || alternativesFlag > maxAlternatives {
+		return fmt.Errorf("--alternatives must be between 1 and %d", maxAlternatives)
+	}
+
 	// Get active profile
 	activeProfile, err := cfg.GetActiveProfile()
 	if err != nil {
@@ -110,9 +181,20 @@ func run(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Using profile: %s (%s %s)\n", activeProfile.Name, activeProfile.Provider, activeProfile.Model)
 	}
 
+	fallbacks, err := cfg.FallbackChain(activeProfile)
+	if err != nil {
+		return err
+	}
+	if verbose && len(fallbacks) > 0 {
+		names := make([]string, len(fallbacks))
+		for i, fallback := range fallbacks {
+			names[i] = fallback.Name
+		}
+		fmt.Printf("Fallback profiles: %s\n", strings.Join(names, ", "))
+	}
+
 	// Fetch man page
-	fetcher := manpage.NewFetcher()
-	manPageContent, err := fetcher.Fetch(command, section)
+	manPageContent, err := fetchManPage(command, section)
 	if err != nil {
 		return err
 	}
@@ -121,54 +203,79 @@ func run(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Man page size: %d bytes\n", len(manPageContent))
 	}
 
-	// Create provider with context window detection
-	providerConfig, err := CreateProvider(cmd.Context(), cfg, activeProfile, verbose)
-	if err != nil {
-		return err
-	}
+	ctx := cmd.Context()
 
-	// Build prompt
+	// Query LLM (with caching and fallback profiles), then parse and validate
 	explainFlag, _ := cmd.Flags().GetBool("explain")
-	promptBuilder := prompt.NewBuilder(command, manPageContent, question, explainFlag)
-	userPrompt := promptBuilder.UserPrompt()
-
-	// Count tokens and warn if exceeds context window
-	actualTokens := countTokens(userPrompt, verbose)
-	if actualTokens > providerConfig.ContextWindow {
-		fmt.Fprintf(os.Stderr, "⚠️  Warning: Prompt (%d tokens) exceeds %d token context window.\n", actualTokens, providerConfig.ContextWindow)
-		fmt.Fprintf(os.Stderr, "    Try a more specific section: heyman <section> %s <question>\n\n", command)
+	jsonFlag, _ := cmd.Flags().GetBool("json")
+	pipe := newPipeline(cfg, explainFlag)
+	pipe.alternatives = alternativesFlag
+	pipe.offerPull = true
+
+	if dryRun {
+		// Describe the primary profile's provider without contacting it
+		providerConfig, err := createProvider(ctx, cfg, activeProfile, ProviderOptions{Verbose: verbose, DryRun: true})
+		if err != nil {
+			return err
+		}
+		return printDryRun(pipe.dryRun(ctx, providerConfig, fallbacks, command, section, manPageContent, question), jsonFlag)
 	}
 
-	// Debug output
-	if debug {
-		fmt.Fprintf(os.Stderr, "\n=== DEBUG: System Prompt ===\n%s\n", promptBuilder.SystemPrompt())
-		fmt.Fprintf(os.Stderr, "\n=== DEBUG: User Prompt (first 500 chars) ===\n%s\n", truncate(userPrompt, 500))
-		fmt.Fprintf(os.Stderr, "\n=== DEBUG: User Prompt length: %d chars ===\n\n", len(userPrompt))
+	// Show explanations as they arrive when a person is watching
+	var stream *explainStream
+	if explainFlag && !jsonFlag && alternativesFlag == 1 && !verbose && !debug && stdoutIsTerminal() {
+		stream = newExplainStream(os.Stdout, command)
+		pipe.onChunk = stream.write
+		pipe.onFallback = stream.restart
 	}
 
-	// Query LLM (with caching)
-	resp, err := queryWithCache(cmd, cfg, providerConfig, promptBuilder, activeProfile, command, question)
+	result, err := pipe.answer(ctx, activeProfile, fallbacks, command, section, manPageContent, question)
+	streamed := false
+	if stream != nil {
+		var final string
+		if err == nil {
+			final = result.Parsed.Command
+		}
+		streamed = stream.finish(final)
+	}
 	if err != nil {
 		return err
 	}
+	if stream != nil && stream.command != "" && !streamed {
+		fmt.Fprintln(os.Stderr, "\n⚠️  The streamed answer was replaced by a validated one:")
+	}
 
-	// Parse and validate response (with retry)
-	parsed, err := parseAndValidate(cmd, providerConfig.Provider, promptBuilder, resp, command, explainFlag, cfg, activeProfile, question)
+	// Output result
+	var copied string
+	if result.Alternatives != nil {
+		copied, err = outputAlternatives(cmd, result.Alternatives, result.Response, result.Provider.Profile)
+	} else {
+		copied, err = outputResult(cmd, result.Parsed, result.Response, result.Provider.Profile, cfg, streamed)
+	}
 	if err != nil {
 		return err
 	}
 
-	// Output result
-	return outputResult(cmd, parsed, resp, activeProfile, cfg)
+	// A copied command is an accepted answer: keep it as a few-shot example
+	if copied != "" {
+		recordAccepted(command, question, copied, result.Platform.Key(), examples.SourceCopy)
+	}
+
+	rememberLast(cfg, result, section)
+	recordHistory(result, command, section, question)
+	return nil
 }
 
-func queryWithCache(cmd *cobra.Command, cfg *config.Config, providerConfig *ProviderConfig, promptBuilder *prompt.Builder, activeProfile *config.Profile, command, question string) (*llm.QueryResponse, error) {
-	cacheManager := cache.New(cfg.CacheDays)
+// queryWithCache returns the cached response for the question, or queries the provider and caches the result
+func (p *pipeline) queryWithCache(ctx context.Context, providerConfig *ProviderConfig, promptBuilder *prompt.Builder, command, question string, platform manpage.Platform) (*llm.QueryResponse, error) {
+	activeProfile := providerConfig.Profile
+	promptBuilder = promptBuilder.WithStructuredOutput(providerConfig.StructuredOutput).WithTemplates(providerConfig.Templates)
+	cacheManager := cache.New(p.cfg.CacheDays)
 
 	// Check cache first
-	if !noCache {
-		if cachedResp, found := cacheManager.Get(command, question, activeProfile.Model); found {
-			if verbose {
+	if !p.noCache {
+		if cachedResp, found := cacheManager.Get(p.cacheKey(providerConfig, command, promptBuilder.Section(), question, platform)); found {
+			if p.verbose {
 				fmt.Println("Found in cache")
 			}
 			return cachedResp, nil
@@ -177,29 +284,30 @@ func queryWithCache(cmd *cobra.Command, cfg *config.Config, providerConfig *Prov
 
 	// Prepare request
 	req := llm.QueryRequest{
-		Model:         activeProfile.Model,
-		SystemPrompt:  promptBuilder.SystemPrompt(),
-		UserPrompt:    promptBuilder.UserPrompt(),
-		MaxTokens:     2000,
-		Temperature:   0.1,
-		ContextWindow: providerConfig.ContextWindow,
+		Model:          activeProfile.Model,
+		SystemPrompt:   promptBuilder.SystemPrompt(),
+		UserPrompt:     promptBuilder.UserPrompt(),
+		MaxTokens:      p.maxResponseTokens(),
+		Temperature:    0.1,
+		ContextWindow:  providerConfig.ContextWindow,
+		ResponseSchema: responseSchema(promptBuilder),
 	}
 
 	// Execute query
-	showProgress := !quiet && !verbose && !debug
-	resp, err := ExecuteQuery(cmd.Context(), providerConfig.Provider, req, QueryOptions{
-		ShowProgress: showProgress,
-		Verbose:      verbose,
-		Debug:        debug,
+	resp, err := ExecuteQuery(ctx, providerConfig.Provider, req, QueryOptions{
+		ShowProgress: p.showProgress,
+		Verbose:      p.verbose,
+		Debug:        p.debug,
 		Profile:      activeProfile,
+		OnChunk:      p.onChunk,
 	})
 	if err != nil {
 		return nil, err
 	}
 
 	// Save to cache
-	if err := cacheManager.Set(command, question, activeProfile.Model, resp); err != nil {
-		if verbose {
+	if err := cacheManager.Set(p.cacheKey(providerConfig, command, promptBuilder.Section(), question, platform), resp); err != nil {
+		if p.verbose {
 			fmt.Printf("Warning: failed to cache response: %v\n", err)
 		}
 	}
@@ -207,49 +315,57 @@ func queryWithCache(cmd *cobra.Command, cfg *config.Config, providerConfig *Prov
 	return resp, nil
 }
 
-func parseAndValidate(cmd *cobra.Command, provider llm.Provider, promptBuilder *prompt.Builder, resp *llm.QueryResponse, command string, explainFlag bool, cfg *config.Config, activeProfile *config.Profile, question string) (parser.ParsedResponse, error) {
-	responseParser := parser.New(command, explainFlag)
-	parsed := responseParser.Parse(resp.Content)
+// parseAndValidate parses the response, retrying once with a stricter prompt if it contains no valid command.
+// It returns the valid commands, best first.
+func (p *pipeline) parseAndValidate(ctx context.Context, providerConfig *ProviderConfig, promptBuilder *prompt.Builder, resp *llm.QueryResponse, command, question string, platform manpage.Platform) ([]parser.ParsedResponse, error) {
+	activeProfile := providerConfig.Profile
+	promptBuilder = promptBuilder.WithStructuredOutput(providerConfig.StructuredOutput).WithTemplates(providerConfig.Templates)
+	responseParser := parser.New(command, p.explain).WithManPage(promptBuilder.ManPage())
+	parsed, parseErr := p.parse(responseParser, resp.Content)
 
 	// Retry if invalid (and not from cache)
-	if !parsed.Valid && !resp.Cached {
-		if verbose {
-			fmt.Printf("Validation failed: %v, retrying with strict prompt\n", parsed.Error)
+	if parseErr != nil && !resp.Cached {
+		if p.verbose {
+			fmt.Printf("Validation failed: %v, retrying with strict prompt\n", parseErr)
 		}
 
 		req := llm.QueryRequest{
-			Model:        activeProfile.Model,
-			SystemPrompt: promptBuilder.SystemPrompt(),
-			UserPrompt:   promptBuilder.StrictRetryPrompt(),
-			MaxTokens:    2000,
-			Temperature:  0.1,
+			Model:          activeProfile.Model,
+			SystemPrompt:   promptBuilder.SystemPrompt(),
+			UserPrompt:     promptBuilder.StrictRetryPrompt(),
+			MaxTokens:      p.maxResponseTokens(),
+			Temperature:    0.1,
+			ResponseSchema: responseSchema(promptBuilder),
 		}
 
-		retryResp, err := provider.Query(cmd.Context(), req)
+		retryResp, err := ExecuteQuery(ctx, providerConfig.Provider, req, QueryOptions{Profile: activeProfile})
 		if err != nil {
-			return parser.ParsedResponse{}, fmt.Errorf("LLM retry failed: %w", err)
+			return nil, fmt.Errorf("LLM retry failed: %w", err)
 		}
 
-		parsed = responseParser.Parse(retryResp.Content)
-		if !parsed.Valid {
-			return parser.ParsedResponse{}, fmt.Errorf("unable to generate valid command: %v", parsed.Error)
+		parsed, parseErr = p.parse(responseParser, retryResp.Content)
+		if parseErr != nil {
+			return nil, fmt.Errorf("unable to generate valid command: %v", parseErr)
 		}
 
 		// Cache successful retry
-		cacheManager := cache.New(cfg.CacheDays)
-		if err := cacheManager.Set(command, question, activeProfile.Model, retryResp); err != nil {
-			if verbose {
+		cacheManager := cache.New(p.cfg.CacheDays)
+		if err := cacheManager.Set(p.cacheKey(providerConfig, command, promptBuilder.Section(), question, platform), retryResp); err != nil {
+			if p.verbose {
 				fmt.Printf("Warning: failed to cache retry response: %v\n", err)
 			}
 		}
-	} else if !parsed.Valid && resp.Cached {
-		return parser.ParsedResponse{}, fmt.Errorf("cached response invalid: %v", parsed.Error)
+	} else if parseErr != nil && resp.Cached {
+		return nil, fmt.Errorf("cached response invalid: %v", parseErr)
 	}
 
 	return parsed, nil
 }
 
-func outputResult(cmd *cobra.Command, parsed parser.ParsedResponse, resp *llm.QueryResponse, activeProfile *config.Profile, cfg *config.Config) error {
+// outputResult prints the answer in the requested format and returns the
+// command if it was copied to the clipboard. If streamed, the command and
+// explanation were already printed as they arrived.
+func outputResult(cmd *cobra.Command, parsed parser.ParsedResponse, resp *llm.QueryResponse, activeProfile *config.Profile, cfg *config.Config, streamed bool) (copied string, err error) {
 	jsonFlag, _ := cmd.Flags().GetBool("json")
 	tokensFlag, _ := cmd.Flags().GetBool("tokens")
 	copyFlag, _ := cmd.Flags().GetBool("copy")
@@ -269,15 +385,23 @@ func outputResult(cmd *cobra.Command, parsed parser.ParsedResponse, resp *llm.Qu
 	if jsonFlag {
 		jsonOutput, err := output.FormatJSON(parsed, resp, costPtr)
 		if err != nil {
-			return fmt.Errorf("failed to format JSON: %w", err)
+			return "", fmt.Errorf("failed to format JSON: %w", err)
 		}
 		fmt.Println(jsonOutput)
 	} else {
 		// Plain text output
-		fmt.Println(parsed.Command)
-		if explainFlag && parsed.Explanation != "" {
-			fmt.Println()
-			fmt.Println(parsed.Explanation)
+		if !streamed {
+			fmt.Println(parsed.Command)
+			if explainFlag && parsed.Explanation != "" {
+				fmt.Println()
+				fmt.Println(parsed.Explanation)
+			}
+		}
+		if explainFlag {
+			if sources := output.FormatSources(parsed.FlagsUsed); sources != "" {
+				fmt.Println()
+				fmt.Print(sources)
+			}
 		}
 
 		// Show token usage if requested
@@ -293,12 +417,13 @@ func outputResult(cmd *cobra.Command, parsed parser.ParsedResponse, resp *llm.Qu
 	// Copy to clipboard if requested
 	if copyFlag {
 		if err := output.CopyToClipboard(parsed.Command); err != nil {
-			return fmt.Errorf("failed to copy to clipboard: %w", err)
+			return "", fmt.Errorf("failed to copy to clipboard: %w", err)
 		}
 		if !jsonFlag {
 			fmt.Println("\n✓ Copied to clipboard")
 		}
+		copied = parsed.Command
 	}
 
-	return nil
+	return copied, nil
 }
@@ -0,0 +1,852 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrg/xdg"
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/llm"
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/alecf/heyman/internal/parser"
+)
+
+const testManPage = `LS(1)                            User Commands                           LS(1)
+
+NAME
+       ls - list directory contents
+
+OPTIONS
+       -l     use a long listing format
+
+       -S     sort by file size, largest first
+
+       -h, --human-readable
+              with -l and -s, print sizes like 1K 234M 2G etc.`
+
+// testEnv isolates config and cache in a temp directory and replaces the
+// provider factory and man page lookup with fakes
+type testEnv struct {
+	providers map[string]*llm.FakeProvider
+}
+
+func newTestEnv(t *testing.T, cfg *config.Config) *testEnv {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("HEYMAN_CACHE_DIR", filepath.Join(dir, "cache"))
+	t.Setenv("HEYMAN_DATA_DIR", filepath.Join(dir, "data"))
+	t.Setenv("HEYMAN_PROFILE", "")
+	xdg.Reload()
+	t.Cleanup(xdg.Reload)
+
+	if err := config.Save(cfg); err != nil {
+		t.Fatalf("failed to save test config: %v", err)
+	}
+
+	env := &testEnv{providers: make(map[string]*llm.FakeProvider)}
+	for name := range cfg.Profiles {
+		env.providers[name] = llm.NewFakeProvider()
+	}
+
+	origFetch, origDetect, origCreate := fetchManPage, detectPlatform, createProvider
+	t.Cleanup(func() { fetchManPage, detectPlatform, createProvider = origFetch, origDetect, origCreate })
+
+	fetchManPage = func(command, section string) (string, error) {
+		return testManPage, nil
+	}
+	detectPlatform = func(command, manPage string, probeVersion bool) manpage.Platform {
+		return manpage.Platform{OS: "linux", Variant: manpage.VariantGNU}
+	}
+	createProvider = func(ctx context.Context, cfg *config.Config, profile *config.Profile, opts ProviderOptions) (*ProviderConfig, error) {
+		return &ProviderConfig{
+			Provider:      env.providers[profile.Name],
+			ContextWindow: profile.GetContextWindow(),
+			Profile:       profile,
+		}, nil
+	}
+
+	return env
+}
+
+// runHeyman executes the root command and returns what it printed to stdout
+func runHeyman(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	origStdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = origStdout }()
+
+	outCh := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		outCh <- string(data)
+	}()
+
+	cmd := newRootCmd("test", "none", "unknown")
+	cmd.SetArgs(args)
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	runErr := cmd.ExecuteContext(context.Background())
+
+	w.Close()
+	return <-outCh, runErr
+}
+
+func singleProfileConfig() *config.Config {
+	return &config.Config{
+		DefaultProfile: "fake",
+		CacheDays:      30,
+		Profiles: map[string]config.Profile{
+			"fake": {Provider: "fake", Model: "fake-model"},
+		},
+	}
+}
+
+func TestRunAnswersFromProviderThenCache(t *testing.T) {
+	env := newTestEnv(t, singleProfileConfig())
+	fake := env.providers["fake"]
+	fake.Script(llm.FakeResponse{Chunks: []string{"ls ", "-lhS"}, TokensInput: 100, TokensOutput: 4})
+
+	out, err := runHeyman(t, "ls", "how", "do", "I", "list", "files", "by", "size")
+	if err != nil {
+		t.Fatalf("run error = %v", err)
+	}
+	if strings.TrimSpace(out) != "ls -lhS" {
+		t.Errorf("output = %q, want %q", out, "ls -lhS")
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("provider received %d requests, want 1", len(requests))
+	}
+	if !strings.Contains(requests[0].UserPrompt, "sort by file size") || !strings.Contains(requests[0].UserPrompt, "how do I list files by size") {
+		t.Errorf("user prompt missing man page or question:\n%s", requests[0].UserPrompt)
+	}
+
+	// The second identical query is served from the cache
+	out, err = runHeyman(t, "--json", "ls", "how", "do", "I", "list", "files", "by", "size")
+	if err != nil {
+		t.Fatalf("cached run error = %v", err)
+	}
+	var result struct {
+		Command  string `json:"command"`
+		Metadata struct {
+			Cached bool `json:"cached"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	if result.Command != "ls -lhS" || !result.Metadata.Cached {
+		t.Errorf("cached result = %+v, want cached ls -lhS", result)
+	}
+	if got := len(fake.Requests()); got != 1 {
+		t.Errorf("provider received %d requests after cache hit, want 1", got)
+	}
+}
+
+func TestRunRetriesInvalidResponseWithStrictPrompt(t *testing.T) {
+	env := newTestEnv(t, singleProfileConfig())
+	fake := env.providers["fake"]
+	fake.Script(
+		llm.FakeResponse{Content: "Sure! You can sort by size with the -S flag."},
+		llm.FakeResponse{Content: "ls -S"},
+	)
+
+	out, err := runHeyman(t, "ls", "sort", "by", "size")
+	if err != nil {
+		t.Fatalf("run error = %v", err)
+	}
+	if strings.TrimSpace(out) != "ls -S" {
+		t.Errorf("output = %q, want %q", out, "ls -S")
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("provider received %d requests, want 2", len(requests))
+	}
+	if !strings.Contains(requests[1].UserPrompt, "starting with 'ls'") {
+		t.Errorf("retry did not use the strict prompt: %q", requests[1].UserPrompt)
+	}
+}
+
+func TestRunReportsManPageRefusal(t *testing.T) {
+	env := newTestEnv(t, singleProfileConfig())
+	refusal := llm.FakeResponse{Content: "I cannot find this information in the man page"}
+	env.providers["fake"].Script(refusal, refusal)
+
+	_, err := runHeyman(t, "ls", "how", "do", "I", "make", "coffee")
+	if err == nil || !strings.Contains(err.Error(), "not found in man page") {
+		t.Fatalf("run error = %v, want man page refusal", err)
+	}
+}
+
+func TestRunFallsBackOnRateLimit(t *testing.T) {
+	cfg := &config.Config{
+		DefaultProfile: "primary",
+		CacheDays:      30,
+		Profiles: map[string]config.Profile{
+			"primary": {Provider: "fake", Model: "primary-model", Fallback: []string{"backup"}},
+			"backup":  {Provider: "fake", Model: "backup-model"},
+		},
+	}
+	env := newTestEnv(t, cfg)
+	env.providers["primary"].Script(llm.FakeResponse{Err: &llm.Error{Provider: "fake", Kind: llm.ErrRateLimited, StatusCode: 429}})
+	env.providers["backup"].Script(llm.FakeResponse{Content: "ls -lhS"})
+
+	out, err := runHeyman(t, "--json", "ls", "list", "files", "by", "size")
+	if err != nil {
+		t.Fatalf("run error = %v", err)
+	}
+
+	var result struct {
+		Command  string `json:"command"`
+		Metadata struct {
+			Profile string `json:"profile"`
+			Model   string `json:"model"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	if result.Command != "ls -lhS" || result.Metadata.Profile != "backup" || result.Metadata.Model != "backup-model" {
+		t.Errorf("result = %+v, want ls -lhS answered by backup", result)
+	}
+}
+
+func TestRunFallsBackWhenFirstTokenTimesOut(t *testing.T) {
+	cfg := &config.Config{
+		DefaultProfile: "primary",
+		CacheDays:      30,
+		Profiles: map[string]config.Profile{
+			"primary": {Provider: "fake", Model: "primary-model", Fallback: []string{"backup"}, FirstTokenTimeout: config.Duration(50 * time.Millisecond)},
+			"backup":  {Provider: "fake", Model: "backup-model"},
+		},
+	}
+	env := newTestEnv(t, cfg)
+	env.providers["primary"].Script(llm.FakeResponse{Content: "ls -S", Delay: time.Minute})
+	env.providers["backup"].Script(llm.FakeResponse{Content: "ls -lhS"})
+
+	start := time.Now()
+	out, err := runHeyman(t, "ls", "list", "files", "by", "size")
+	if err != nil {
+		t.Fatalf("run error = %v", err)
+	}
+	if strings.TrimSpace(out) != "ls -lhS" {
+		t.Errorf("output = %q, want the backup's answer", out)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("run took %s, want the primary abandoned after its first token timeout", elapsed)
+	}
+}
+
+func TestRunFallsBackWhenPrimaryProviderUnavailable(t *testing.T) {
+	cfg := &config.Config{
+		DefaultProfile: "primary",
+		CacheDays:      30,
+		Profiles: map[string]config.Profile{
+			"primary": {Provider: "ollama", Model: "missing-model", Fallback: []string{"backup"}},
+			"backup":  {Provider: "fake", Model: "backup-model"},
+		},
+	}
+	env := newTestEnv(t, cfg)
+	env.providers["backup"].Script(llm.FakeResponse{Content: "ls -lhS"})
+	create := createProvider
+	offeredPull := make(map[string]bool)
+	createProvider = func(ctx context.Context, cfg *config.Config, profile *config.Profile, opts ProviderOptions) (*ProviderConfig, error) {
+		offeredPull[profile.Name] = opts.OfferPull
+		if profile.Name == "primary" {
+			return nil, modelNotInstalled(profile.Model)
+		}
+		return create(ctx, cfg, profile, opts)
+	}
+
+	out, err := runHeyman(t, "ls", "list", "files", "by", "size")
+	if err != nil {
+		t.Fatalf("run error = %v, want the backup to answer", err)
+	}
+	if strings.TrimSpace(out) != "ls -lhS" {
+		t.Errorf("output = %q, want the backup's answer", out)
+	}
+	if !offeredPull["primary"] || offeredPull["backup"] {
+		t.Errorf("offered pull = %v, want only the primary profile", offeredPull)
+	}
+}
+
+func TestRunMarksStreamCutOffBeforeFallingBack(t *testing.T) {
+	cfg := &config.Config{
+		DefaultProfile: "primary",
+		CacheDays:      30,
+		Profiles: map[string]config.Profile{
+			"primary": {Provider: "fake", Model: "primary-model", Fallback: []string{"backup"}},
+			"backup":  {Provider: "fake", Model: "backup-model"},
+		},
+	}
+	env := newTestEnv(t, cfg)
+	origTerminal := stdoutIsTerminal
+	stdoutIsTerminal = func() bool { return true }
+	t.Cleanup(func() { stdoutIsTerminal = origTerminal })
+
+	env.providers["primary"].Script(llm.FakeResponse{
+		Chunks: []string{"ls -S\n\n", "Sorts by"},
+		Err:    &llm.Error{Provider: "fake", Kind: llm.ErrUnavailable},
+	})
+	env.providers["backup"].Script(llm.FakeResponse{Chunks: []string{"ls -lhS\n\n", "Lists files, largest first.\n"}})
+
+	out, err := runHeyman(t, "--explain", "ls", "list", "files", "by", "size")
+	if err != nil {
+		t.Fatalf("run error = %v", err)
+	}
+	cutOff := strings.Index(out, "cut off. Asking backup instead")
+	if cutOff < 0 {
+		t.Fatalf("output doesn't mark the primary's partial answer:\n%s", out)
+	}
+	if before, after := out[:cutOff], out[cutOff:]; !strings.Contains(before, "Sorts by") || !strings.Contains(after, "ls -lhS") {
+		t.Errorf("want the partial answer, then the marker, then the backup's answer:\n%s", out)
+	}
+}
+
+func TestRunDoesNotFallBackOnAuthError(t *testing.T) {
+	cfg := &config.Config{
+		DefaultProfile: "primary",
+		CacheDays:      30,
+		Profiles: map[string]config.Profile{
+			"primary": {Provider: "fake", Model: "primary-model", Fallback: []string{"backup"}},
+			"backup":  {Provider: "fake", Model: "backup-model"},
+		},
+	}
+	env := newTestEnv(t, cfg)
+	env.providers["primary"].Script(llm.FakeResponse{Err: &llm.Error{Provider: "fake", Kind: llm.ErrAuth, StatusCode: 401}})
+
+	_, err := runHeyman(t, "ls", "list", "files")
+	if err == nil {
+		t.Fatal("run succeeded, want auth error")
+	}
+	if got := len(env.providers["backup"].Requests()); got != 0 {
+		t.Errorf("backup received %d requests, want 0", got)
+	}
+}
+
+func TestRunDryRunReportsWithoutQuerying(t *testing.T) {
+	env := newTestEnv(t, singleProfileConfig())
+	fake := env.providers["fake"]
+	create := createProvider
+	createProvider = func(ctx context.Context, cfg *config.Config, profile *config.Profile, opts ProviderOptions) (*ProviderConfig, error) {
+		if !opts.DryRun {
+			t.Error("provider created without DryRun, which may contact the provider or offer to pull a model")
+		}
+		return create(ctx, cfg, profile, opts)
+	}
+
+	out, err := runHeyman(t, "--dry-run", "--json", "ls", "how", "do", "I", "list", "files", "by", "size")
+	if err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	var report dryRunReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	if report.Profile != "fake" || report.Model != "fake-model" {
+		t.Errorf("profile = %s (%s), want fake (fake-model)", report.Profile, report.Model)
+	}
+	if !strings.Contains(report.UserPrompt, "sort by file size") || !strings.Contains(report.UserPrompt, "how do I list files by size") {
+		t.Errorf("user prompt missing man page or question:\n%s", report.UserPrompt)
+	}
+	if report.Tokens.Prompt == 0 || report.Tokens.ContextWindow != 8192 || !report.Tokens.Fits {
+		t.Errorf("tokens = %+v, want a prompt that fits the 8192-token window", report.Tokens)
+	}
+	if report.Cache.Hit {
+		t.Error("cache hit reported before anything was cached")
+	}
+	if got := len(fake.Requests()); got != 0 {
+		t.Errorf("provider received %d requests during a dry run, want 0", got)
+	}
+
+	// Once the answer is cached, a dry run reports the hit
+	createProvider = create
+	fake.Script(llm.FakeResponse{Chunks: []string{"ls -lhS"}})
+	if _, err := runHeyman(t, "ls", "how", "do", "I", "list", "files", "by", "size"); err != nil {
+		t.Fatalf("run error = %v", err)
+	}
+	out, err = runHeyman(t, "--dry-run", "--json", "ls", "how", "do", "I", "list", "files", "by", "size")
+	if err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	report = dryRunReport{}
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	if !report.Cache.Hit {
+		t.Error("dry run after caching the answer didn't report a cache hit")
+	}
+}
+
+func TestProfileCommandsUpdateConfig(t *testing.T) {
+	newTestEnv(t, &config.Config{CacheDays: 30})
+
+	if _, err := runHeyman(t, "profile", "add", "--provider", "openai", "--model", "gpt-4o", "--name", "cloud"); err != nil {
+		t.Fatalf("profile add error = %v", err)
+	}
+	if _, err := runHeyman(t, "profile", "add", "--provider", "ollama", "--model", "llama3.2", "--name", "local", "--fallback", "cloud"); err != nil {
+		t.Fatalf("profile add error = %v", err)
+	}
+	if _, err := runHeyman(t, "profile", "add", "--provider", "openai", "--model", "gpt-4o", "--name", "cloud"); err == nil {
+		t.Error("adding an existing profile succeeded, want an error")
+	}
+	if _, err := runHeyman(t, "profile", "add", "--provider", "nope", "--model", "m"); err == nil {
+		t.Error("adding a profile with an unsupported provider succeeded, want an error")
+	}
+
+	if _, err := runHeyman(t, "profile", "rename", "cloud", "backup"); err != nil {
+		t.Fatalf("profile rename error = %v", err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DefaultProfile != "backup" {
+		t.Errorf("default profile = %q, want the first profile added, renamed to backup", cfg.DefaultProfile)
+	}
+	if fallback := cfg.Profiles["local"].Fallback; len(fallback) != 1 || fallback[0] != "backup" {
+		t.Errorf("local falls back to %v, want [backup]", fallback)
+	}
+
+	if _, err := runHeyman(t, "profile", "remove", "backup"); err != nil {
+		t.Fatalf("profile remove error = %v", err)
+	}
+	cfg, err = config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Profiles["backup"]; ok || cfg.DefaultProfile != "" {
+		t.Errorf("after removal profiles = %v, default = %q, want backup gone and no default", cfg.Profiles, cfg.DefaultProfile)
+	}
+	if fallback := cfg.Profiles["local"].Fallback; len(fallback) != 0 {
+		t.Errorf("local still falls back to %v", fallback)
+	}
+}
+
+func TestServeRejectsUnsafeRequests(t *testing.T) {
+	env := newTestEnv(t, singleProfileConfig())
+	var detected []string
+	detectPlatform = func(command, manPage string, probeVersion bool) manpage.Platform {
+		if probeVersion {
+			detected = append(detected, command)
+		}
+		return manpage.Platform{OS: "linux"}
+	}
+	handler := newServer(singleProfileConfig(), 1).routes()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"text/plain body", "text/plain", `{"command": "ls", "question": "list files"}`, http.StatusUnsupportedMediaType},
+		{"option as command", "application/json", `{"command": "--pager=sh", "question": "list files"}`, http.StatusBadRequest},
+		{"option as section", "application/json", `{"command": "ls", "section": "--pager=sh", "question": "list files"}`, http.StatusBadRequest},
+		{"path as command", "application/json", `{"command": "/tmp/x", "question": "list files"}`, http.StatusBadRequest},
+		{"plain command", "application/json; charset=utf-8", `{"command": "ls", "section": "1", "question": "list files"}`, http.StatusOK},
+	}
+	env.providers["fake"].Script(llm.FakeResponse{Chunks: []string{"ls -l"}})
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.status, rec.Body)
+		}
+	}
+	if got := len(env.providers["fake"].Requests()); got != 1 {
+		t.Errorf("provider received %d requests, want 1 for the plain command", got)
+	}
+	if len(detected) > 0 {
+		t.Errorf("ran --version for request commands %v", detected)
+	}
+}
+
+func TestMCPToolsRejectUnsafeArguments(t *testing.T) {
+	env := newTestEnv(t, singleProfileConfig())
+	fetched := 0
+	fetchManPage = func(command, section string) (string, error) {
+		fetched++
+		return testManPage, nil
+	}
+
+	for _, tool := range mcpTools(newServer(singleProfileConfig(), 1)) {
+		for _, arguments := range []string{
+			`{"command": "--pager=sh", "query": "x", "question": "x"}`,
+			`{"command": "ls", "section": "-Hsh", "query": "x", "question": "x"}`,
+			`{"command": "../bin/ls", "query": "x", "question": "x"}`,
+		} {
+			if _, err := tool.Handler(context.Background(), json.RawMessage(arguments)); err == nil {
+				t.Errorf("%s accepted %s", tool.Name, arguments)
+			}
+		}
+	}
+	if fetched != 0 || len(env.providers["fake"].Requests()) != 0 {
+		t.Errorf("unsafe arguments fetched %d man pages and made %d queries, want none", fetched, len(env.providers["fake"].Requests()))
+	}
+}
+
+func TestWarmNamesSkippedProfiles(t *testing.T) {
+	newTestEnv(t, singleProfileConfig())
+
+	out, err := runHeyman(t, "warm", "fake")
+	if err != nil {
+		t.Fatalf("warm error = %v", err)
+	}
+	if !strings.Contains(out, "Skipping fake:") || !strings.Contains(out, "skipped 1 non-Ollama profiles") {
+		t.Errorf("warm output = %q, want the skipped profile named and counted", out)
+	}
+	if _, err := runHeyman(t, "warm", "missing"); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("warm of an unknown profile error = %v, want it named", err)
+	}
+}
+
+func TestResponseTokensScaleWithAnswersRequested(t *testing.T) {
+	env := newTestEnv(t, singleProfileConfig())
+	fake := env.providers["fake"]
+	fake.Script(llm.FakeResponse{Chunks: []string{"1. ls -l\n2. ls -la\n3. ls -lh"}})
+
+	runHeyman(t, "--alternatives", "3", "ls", "list", "files")
+	requests := fake.Requests()
+	if len(requests) == 0 || requests[0].MaxTokens != answerTokens+2*extraAnswerTokens {
+		t.Fatalf("requests = %+v, want MaxTokens %d for three alternatives", requests, answerTokens+2*extraAnswerTokens)
+	}
+
+	out, err := runHeyman(t, "--dry-run", "--json", "--alternatives", "3", "ls", "list", "files")
+	if err != nil {
+		t.Fatalf("dry run error = %v", err)
+	}
+	var report dryRunReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", out, err)
+	}
+	if report.Tokens.MaxResponse != requests[0].MaxTokens {
+		t.Errorf("dry run max response = %d, want the %d a query requests", report.Tokens.MaxResponse, requests[0].MaxTokens)
+	}
+
+	if got := (&pipeline{cheatsheet: 20}).maxResponseTokens(); got != answerTokens+19*extraAnswerTokens {
+		t.Errorf("cheat sheet of 20 max tokens = %d, want %d", got, answerTokens+19*extraAnswerTokens)
+	}
+}
+
+func TestOllamaTokenizeRemembersMissingEndpoint(t *testing.T) {
+	newTestEnv(t, singleProfileConfig())
+	tokenizeCalls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/tokenize" {
+			tokenizeCalls++
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	t.Setenv("OLLAMA_HOST", srv.URL)
+
+	cfg := singleProfileConfig()
+	for range 2 {
+		provider, err := llm.NewOllamaProvider(time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokenize := ollamaTokenize(cfg, provider, "llama3.2")
+		if _, err := tokenize(context.Background(), "text"); err == nil {
+			t.Fatal("tokenize succeeded against a server without the endpoint")
+		}
+	}
+	if tokenizeCalls != 1 {
+		t.Errorf("server asked to tokenize %d times, want once and then remembered", tokenizeCalls)
+	}
+}
+
+func TestHistoryListsAndRecallsAnswers(t *testing.T) {
+	env := newTestEnv(t, singleProfileConfig())
+	env.providers["fake"].Script(
+		llm.FakeResponse{Content: "ls -lhS"},
+		llm.FakeResponse{Content: "ls -a"},
+	)
+	for _, question := range [][]string{{"list", "files", "by", "size"}, {"show", "hidden", "files"}} {
+		if _, err := runHeyman(t, append([]string{"ls"}, question...)...); err != nil {
+			t.Fatalf("run error = %v", err)
+		}
+	}
+
+	out, err := runHeyman(t, "history", "--limit", "1")
+	if err != nil {
+		t.Fatalf("history error = %v", err)
+	}
+	if !strings.Contains(out, "ls -a") || strings.Contains(out, "ls -lhS") {
+		t.Errorf("history --limit 1 = %q, want only the last answer", out)
+	}
+
+	out, err = runHeyman(t, "history", "hiden")
+	if err != nil {
+		t.Fatalf("history search error = %v", err)
+	}
+	if !strings.Contains(out, "ls -a") || strings.Contains(out, "ls -lhS") {
+		t.Errorf("history hiden = %q, want the fuzzy match only", out)
+	}
+
+	if _, err := runHeyman(t, "history", "-n", "-1"); err == nil || !strings.Contains(err.Error(), "negative") {
+		t.Errorf("history -n -1 error = %v, want a negative limit rejected", err)
+	}
+
+	out, err = runHeyman(t, "!1")
+	if err != nil {
+		t.Fatalf("!1 error = %v", err)
+	}
+	if strings.TrimSpace(out) != "ls -lhS" {
+		t.Errorf("!1 = %q, want the first answer", out)
+	}
+	if got := len(env.providers["fake"].Requests()); got != 2 {
+		t.Errorf("provider received %d requests, want 2 (recalling doesn't query)", got)
+	}
+}
+
+func TestFeedbackRequeryAsksTheWayTheAnswerWasAsked(t *testing.T) {
+	env := newTestEnv(t, &config.Config{
+		DefaultProfile: "fast",
+		CacheDays:      30,
+		Profiles: map[string]config.Profile{
+			"fast": {Provider: "fake", Model: "small-model"},
+			"big":  {Provider: "fake", Model: "big-model"},
+		},
+	})
+	var sections []string
+	fetchManPage = func(command, section string) (string, error) {
+		sections = append(sections, section)
+		return testManPage, nil
+	}
+	big := env.providers["big"]
+	big.Script(
+		llm.FakeResponse{Content: "1. ls -l\n2. ls -la"},
+		llm.FakeResponse{Content: "1. ls -lS\n2. ls -lSr"},
+	)
+
+	question := []string{"--alternatives", "2", "1", "ls", "list", "files", "by", "size"}
+	if _, err := runHeyman(t, append([]string{"--profile", "big"}, question...)...); err != nil {
+		t.Fatalf("run error = %v", err)
+	}
+	out, err := runHeyman(t, "feedback", "bad", "--requery", "--reason", "not sorted")
+	if err != nil {
+		t.Fatalf("feedback error = %v", err)
+	}
+	if !strings.Contains(out, "ls -lS") || !strings.Contains(out, "ls -lSr") {
+		t.Errorf("requery output = %q, want both new alternatives", out)
+	}
+
+	requests := big.Requests()
+	if len(requests) != 2 || len(env.providers["fast"].Requests()) != 0 {
+		t.Fatalf("big received %d requests and fast %d, want the requery asked with big's model", len(requests), len(env.providers["fast"].Requests()))
+	}
+	if !strings.Contains(requests[1].UserPrompt, "ls -l") || !strings.Contains(requests[1].UserPrompt, "not sorted") {
+		t.Errorf("requery prompt lacks the rejected answer and reason:\n%s", requests[1].UserPrompt)
+	}
+	if requests[1].MaxTokens != requests[0].MaxTokens {
+		t.Errorf("requery max tokens = %d, want %d (two alternatives)", requests[1].MaxTokens, requests[0].MaxTokens)
+	}
+	if len(sections) != 2 || sections[1] != "1" {
+		t.Errorf("man page sections fetched = %q, want section 1 again", sections)
+	}
+
+	// The corrected answer replaced the rejected one in the cache
+	out, err = runHeyman(t, append([]string{"--profile", "big"}, question...)...)
+	if err != nil {
+		t.Fatalf("cached run error = %v", err)
+	}
+	if !strings.Contains(out, "ls -lSr") || len(big.Requests()) != 2 {
+		t.Errorf("repeated question = %q after %d requests, want the corrected answer from the cache", out, len(big.Requests()))
+	}
+}
+
+func TestCompareMarksAgreeingProfiles(t *testing.T) {
+	threeProfiles := func() *config.Config {
+		return &config.Config{
+			DefaultProfile: "a",
+			CacheDays:      30,
+			Profiles: map[string]config.Profile{
+				"a": {Provider: "fake", Model: "model-a"},
+				"b": {Provider: "fake", Model: "model-b"},
+				"c": {Provider: "fake", Model: "model-c"},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		answers map[string]llm.FakeResponse
+		groups  map[string]string // Profile to its AGREE column
+		summary []string
+	}{
+		{
+			name: "two of three",
+			answers: map[string]llm.FakeResponse{
+				"a": {Content: "ls -lS"},
+				"b": {Content: "ls -a"},
+				"c": {Content: "ls   -lS"},
+			},
+			groups:  map[string]string{"a": "A", "b": "-", "c": "A"},
+			summary: []string{"A: 2 of 3 profiles agree on: ls -lS"},
+		},
+		{
+			name: "all",
+			answers: map[string]llm.FakeResponse{
+				"a": {Content: "ls -lS"},
+				"b": {Content: "```\nls -lS\n```"},
+				"c": {Content: "ls -lS"},
+			},
+			groups:  map[string]string{"a": "A", "b": "A", "c": "A"},
+			summary: []string{"✓ All profiles agree"},
+		},
+		{
+			name: "none, with an error",
+			answers: map[string]llm.FakeResponse{
+				"a": {Content: "ls -lS"},
+				"b": {Err: &llm.Error{Provider: "fake", Kind: llm.ErrAuth, StatusCode: 401}},
+				"c": {Content: "ls -lSr"},
+			},
+			groups:  map[string]string{"a": "-", "b": "-", "c": "-"},
+			summary: []string{"No two profiles gave the same command"},
+		},
+	}
+
+	for _, tt := range tests {
+		env := newTestEnv(t, threeProfiles())
+		for name, answer := range tt.answers {
+			env.providers[name].Script(answer)
+		}
+
+		out, err := runHeyman(t, "compare", "-p", "a", "-p", "b", "-p", "c", "ls", "list", "files", "by", "size")
+		if err != nil {
+			t.Fatalf("%s: compare error = %v", tt.name, err)
+		}
+		for name, group := range tt.groups {
+			found := false
+			for _, line := range strings.Split(out, "\n") {
+				fields := strings.Fields(line)
+				if len(fields) > 2 && fields[1] == name {
+					found = fields[0] == group
+				}
+			}
+			if !found {
+				t.Errorf("%s: profile %s not marked %s:\n%s", tt.name, name, group, out)
+			}
+		}
+		for _, want := range tt.summary {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: output missing %q:\n%s", tt.name, want, out)
+			}
+		}
+	}
+}
+
+func TestCompareSummaryNamesEachGroup(t *testing.T) {
+	result := func(name, command, group string) *comparison {
+		return &comparison{
+			Profile: &config.Profile{Name: name, Model: name + "-model"},
+			Answer:  &answer{Parsed: parser.ParsedResponse{Command: command, Valid: true}, Response: &llm.QueryResponse{}},
+			Group:   group,
+		}
+	}
+	results := []*comparison{
+		result("a", "ls -lS", "A"),
+		result("b", "ls -a", "B"),
+		result("c", "ls -lS", "A"),
+		result("d", "ls -a", "B"),
+		result("e", "ls -l", ""),
+	}
+
+	var out strings.Builder
+	if err := writeComparison(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	summary := out.String()[strings.LastIndex(out.String(), "\n\n")+2:]
+	want := "A: 2 of 5 profiles agree on: ls -lS\nB: 2 of 5 profiles agree on: ls -a\n"
+	if summary != want {
+		t.Errorf("summary = %q, want %q", summary, want)
+	}
+
+	if got := agreementKey("  ls \t-lS  "); got != "ls -lS" {
+		t.Errorf("agreementKey = %q, want whitespace collapsed", got)
+	}
+}
+
+func TestCheatsheetDropsInvalidEntriesAndCapsCount(t *testing.T) {
+	response := "Task: Sort by size\nls -lS\n\n" +
+		"Task: Wipe home\nls; rm -rf ~\n\n" +
+		"Task: Show hidden files\nls -a\n\n" +
+		"Task: One per line\nls -1"
+
+	entries, err := (&pipeline{cheatsheet: 2}).parse(parser.New("ls", false), response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Task+": "+entry.Command)
+	}
+	if want := []string{"Sort by size: ls -lS", "Show hidden files: ls -a"}; !slices.Equal(got, want) {
+		t.Errorf("entries = %q, want %q", got, want)
+	}
+
+	if _, err := (&pipeline{cheatsheet: 2}).parse(parser.New("ls", false), "Task: Wipe home\nls; rm -rf ~"); err == nil {
+		t.Error("cheat sheet of only invalid entries parsed without error")
+	}
+}
+
+func TestDryRunCountsResponseAgainstContextWindow(t *testing.T) {
+	newTestEnv(t, singleProfileConfig())
+	dryRun := func(args ...string) string {
+		t.Helper()
+		out, err := runHeyman(t, append([]string{"--dry-run"}, append(args, "ls", "list", "files", "by", "size")...)...)
+		if err != nil {
+			t.Fatalf("dry run error = %v", err)
+		}
+		return out
+	}
+
+	var report dryRunReport
+	if err := json.Unmarshal([]byte(dryRun("--json")), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	// A window the prompt fits in, but not with the response
+	create := createProvider
+	createProvider = func(ctx context.Context, cfg *config.Config, profile *config.Profile, opts ProviderOptions) (*ProviderConfig, error) {
+		providerConfig, err := create(ctx, cfg, profile, opts)
+		if providerConfig != nil {
+			providerConfig.ContextWindow = report.Tokens.Prompt + 100
+		}
+		return providerConfig, err
+	}
+
+	report = dryRunReport{}
+	if err := json.Unmarshal([]byte(dryRun("--json")), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Tokens.Fits {
+		t.Errorf("tokens = %+v, want no fit with %d tokens left for a %d-token response", report.Tokens, 100, report.Tokens.MaxResponse)
+	}
+	if out := dryRun(); !strings.Contains(out, fmt.Sprintf("room for only 100 of the %d response tokens", report.Tokens.MaxResponse)) {
+		t.Errorf("dry run output doesn't warn about the response:\n%s", out)
+	}
+}
@@ -0,0 +1,314 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alecf/heyman/internal/config"
+	"github.com/alecf/heyman/internal/llm"
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/alecf/heyman/internal/output"
+	"github.com/alecf/heyman/internal/pricing"
+	"github.com/spf13/cobra"
+)
+
+func serveCmd() *cobra.Command {
+	var addr string
+	var maxConcurrent int
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve answers over a local HTTP API",
+		Long: `Answer questions over HTTP, for tools that want heyman's answers
+programmatically. Answers use the same cache, profiles and fallbacks as the
+command line.
+
+  POST /query    {"command": "tar", "section": "", "question": "extract a .tar.gz",
+                  "explain": false, "profile": ""}
+                 returns the same JSON as heyman --json. An empty profile uses
+                 the default. With "explain": true and Accept: text/event-stream
+                 the response streams as server-sent events: "chunk" events with
+                 the raw model output, then a "result" (or "error") event.
+                 A "fallback" event means the profile answering failed and
+                 the chunks that follow come from the profile it names.
+                 The body must be sent as Content-Type: application/json.
+  GET  /healthz  returns {"status": "ok"}
+
+At most --max-concurrent queries run at once; the rest wait their turn.
+
+Example:
+  heyman serve --addr 127.0.0.1:8088
+  curl -H 'Content-Type: application/json' \
+    -d '{"command": "tar", "question": "extract a .tar.gz"}' localhost:8088/query`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if maxConcurrent < 1 {
+				return fmt.Errorf("--max-concurrent must be at least 1")
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return fmt.Errorf("failed to load config: %w", err)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			srv := &http.Server{
+				Addr:              addr,
+				Handler:           newServer(cfg, maxConcurrent).routes(),
+				ReadHeaderTimeout: 10 * time.Second,
+				BaseContext:       func(_ net.Listener) context.Context { return ctx },
+			}
+
+			errCh := make(chan error, 1)
+			go func() {
+				errCh <- srv.ListenAndServe()
+			}()
+			fmt.Printf("Listening on http://%s\n", addr)
+
+			select {
+			case err := <-errCh:
+				return err
+			case <-ctx.Done():
+			}
+
+			// Let queries in flight finish
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			return srv.Shutdown(shutdownCtx)
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:8088", "address to listen on")
+	cmd.Flags().IntVar(&maxConcurrent, "max-concurrent", 4, "maximum number of queries to answer at once")
+
+	return cmd
+}
+
+// queryRequest is the body of POST /query
+type queryRequest struct {
+	Command  string `json:"command"`
+	Section  string `json:"section,omitempty"`
+	Question string `json:"question"`
+	Explain  bool   `json:"explain,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+}
+
+// server answers queries over HTTP, sharing providers and detected platforms
+// between requests
+type server struct {
+	cfg       *config.Config
+	slots     chan struct{} // Semaphore limiting concurrent queries
+	platforms *sync.Map     // Shared by every request's pipeline
+	providers *sync.Map     // Shared by every request's pipeline
+}
+
+// newServer creates a server that answers at most maxConcurrent queries at once
+func newServer(cfg *config.Config, maxConcurrent int) *server {
+	return &server{
+		cfg:       cfg,
+		slots:     make(chan struct{}, maxConcurrent),
+		platforms: new(sync.Map),
+		providers: new(sync.Map),
+	}
+}
+
+// routes returns the server's HTTP handler
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /query", s.handleQuery)
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return mux
+}
+
+// handleQuery answers a question, streaming explanations as server-sent
+// events if the client accepts them
+func (s *server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	// Browsers send text/plain cross-origin POSTs without asking; JSON needs a preflight
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("Content-Type must be application/json"))
+		return
+	}
+
+	var req queryRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	req.Command = strings.TrimSpace(req.Command)
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Command == "" || req.Question == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("command and question are required"))
+		return
+	}
+	if err := manpage.Validate(req.Command, req.Section); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Wait for a free slot, unless the client gives up first
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-r.Context().Done():
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("gave up waiting for a free slot"))
+		return
+	}
+
+	stream := req.Explain && strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	var events *eventStream
+	if stream {
+		var err error
+		if events, err = newEventStream(w); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	body, status, err := s.answer(r.Context(), req, events)
+	switch {
+	case stream && err != nil:
+		events.send("error", errorBody(err))
+	case stream:
+		events.send("result", body)
+	case err != nil:
+		writeError(w, status, err)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, body)
+	}
+}
+
+// answer runs the query pipeline for a request and returns the JSON output,
+// or the HTTP status and error to report. Chunks of the response are sent to
+// events as they stream, if it is non-nil.
+func (s *server) answer(ctx context.Context, req queryRequest, events *eventStream) (body string, status int, err error) {
+	var profile *config.Profile
+	if req.Profile != "" {
+		profile, err = s.cfg.GetProfile(req.Profile)
+	} else {
+		profile, err = s.cfg.GetActiveProfile()
+	}
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+	fallbacks, err := s.cfg.FallbackChain(profile)
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+
+	manPageContent, err := fetchManPage(req.Command, req.Section)
+	if err != nil {
+		return "", http.StatusNotFound, err
+	}
+
+	pipe := &pipeline{
+		cfg:       s.cfg,
+		explain:   req.Explain,
+		noCache:   noCache,
+		platforms: s.platforms,
+		providers: s.providers,
+	}
+	if events != nil {
+		pipe.onChunk = func(content string) {
+			events.send("chunk", map[string]string{"content": content})
+		}
+		pipe.onFallback = func(profile string) {
+			events.send("fallback", map[string]string{"profile": profile})
+		}
+	}
+
+	result, err := pipe.answer(ctx, profile, fallbacks, req.Command, req.Section, manPageContent, req.Question)
+	if err != nil {
+		if errors.Is(err, llm.ErrRateLimited) {
+			return "", http.StatusTooManyRequests, err
+		}
+		return "", http.StatusBadGateway, err
+	}
+
+	var costPtr *float64
+	if modelPricing := pricing.GetDatabase().GetPricing(result.Provider.Profile.Model); modelPricing != nil {
+		cost := modelPricing.CalculateCost(result.Response.TokensInput, result.Response.TokensOutput)
+		costPtr = &cost
+	}
+	body, err = output.FormatJSON(result.Parsed, result.Response, costPtr)
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("failed to format JSON: %w", err)
+	}
+	return body, http.StatusOK, nil
+}
+
+// eventStream writes server-sent events
+type eventStream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newEventStream starts a server-sent event response
+func newEventStream(w http.ResponseWriter) (*eventStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("streaming is not supported")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &eventStream{w: w, flusher: flusher}, nil
+}
+
+// send writes an event. data is sent as is if it is a string, and as JSON
+// otherwise; multi-line data is split across data: lines.
+func (e *eventStream) send(event string, data any) {
+	text, ok := data.(string)
+	if !ok {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return
+		}
+		text = string(encoded)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fmt.Fprintf(e.w, "event: %s\n", event)
+	for line := range strings.SplitSeq(text, "\n") {
+		fmt.Fprintf(e.w, "data: %s\n", line)
+	}
+	fmt.Fprint(e.w, "\n")
+	e.flusher.Flush()
+}
+
+// errorBody is the JSON body reported for an error
+func errorBody(err error) map[string]string {
+	return map[string]string{"error": err.Error()}
+}
+
+// writeError writes an error as a JSON response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorBody(err))
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -1,28 +1,23 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 
-	tiktoken "github.com/pkoukk/tiktoken-go"
+	"github.com/alecf/heyman/internal/pricing"
+	"github.com/alecf/heyman/internal/tokens"
 )
 
-// countTokens estimates token count using tiktoken, falling back to character count
-func countTokens(text string, verbose bool) int {
-	// Try tiktoken (accurate for OpenAI, decent estimate for Mistral/Llama)
-	tke, err := tiktoken.GetEncoding("cl100k_base")
-	if err == nil {
-		tokens := tke.Encode(text, nil, nil)
-		count := len(tokens)
-		if verbose {
-			fmt.Printf("Prompt tokens: ~%d (tiktoken estimate)\n", count)
-		}
-		return count
-	}
-
-	// Fallback: character count
-	count := len(text) / 4
+// countTokens counts the tokens in text with a model's tokenizer, reporting
+// the count and how it was made in verbose mode
+func countTokens(ctx context.Context, tokenizer tokens.Tokenizer, text string, verbose bool) int {
+	count := tokenizer.Count(ctx, text)
 	if verbose {
-		fmt.Printf("Prompt tokens: ~%d (character estimate)\n", count)
+		approx := "~"
+		if tokenizer.Exact() {
+			approx = ""
+		}
+		fmt.Printf("Prompt tokens: %s%d (%s)\n", approx, count, tokenizer.Name())
 	}
 	return count
 }
@@ -34,3 +29,13 @@ func truncate(s string, maxLen int) string {
 	}
 	return s[:maxLen] + "..."
 }
+
+// estimateCost returns the estimated cost of a query, or nil if the model has no known pricing
+func estimateCost(model string, inputTokens, outputTokens int) *float64 {
+	modelPricing := pricing.GetDatabase().GetPricing(model)
+	if modelPricing == nil {
+		return nil
+	}
+	cost := modelPricing.CalculateCost(inputTokens, outputTokens)
+	return &cost
+}
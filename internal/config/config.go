@@ -1,9 +1,14 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/adrg/xdg"
 	"github.com/pelletier/go-toml/v2"
@@ -17,12 +22,24 @@ type Config struct {
 	Profiles       map[string]Profile `toml:"profiles"`
 }
 
+// Providers are the providers heyman can query
+var Providers = []string{"openai", "ollama"}
+
 // Profile represents an LLM provider configuration
 type Profile struct {
 	Name          string         `toml:"-"` // Set from map key
 	Provider      string         `toml:"provider"` // "openai", "anthropic", "ollama"
 	Model         string         `toml:"model"`
 	ContextWindow int            `toml:"context_window,omitempty"` // Max context window in tokens (defaults to 8192)
+	MaxContextWindow int         `toml:"max_context_window,omitempty"` // Cap on auto-detected Ollama context windows (defaults to 8192)
+	Fallback      []string       `toml:"fallback,omitempty"`       // Profiles to try, in order, when this one fails
+	StructuredOutput *bool       `toml:"structured_output,omitempty"` // Request JSON-schema output (defaults to provider/model support)
+	Prompts       map[string]string `toml:"prompts,omitempty"`        // Prompt template overrides: template name -> file (relative to the prompts dir)
+	Examples      *int           `toml:"examples,omitempty"`        // Accepted answers to include as few-shot examples (defaults to 3, 0 disables)
+	ConnectTimeout    Duration   `toml:"connect_timeout,omitempty"`     // Time allowed to connect to the provider (defaults to 10s)
+	FirstTokenTimeout Duration   `toml:"first_token_timeout,omitempty"` // Time allowed before the first streamed token, including model loading (defaults to 2m)
+	Timeout           Duration   `toml:"timeout,omitempty"`             // Time allowed for the whole request (defaults to 5m)
+	KeepAlive         *Duration  `toml:"keep_alive,omitempty"`          // How long Ollama keeps the model loaded after a query (server default 5m; negative keeps it loaded)
 	Options       map[string]any `toml:"options,omitempty"`
 }
 
@@ -35,7 +52,7 @@ func Load() (*Config, error) {
 	}
 
 	// Get config file path
-	configPath := getConfigPath()
+	configPath := GetConfigPath()
 
 	// Check if config file exists
 	if _, err := os.Stat(configPath); err == nil {
@@ -66,7 +83,7 @@ func Load() (*Config, error) {
 
 // Save writes the configuration to the config file
 func Save(cfg *Config) error {
-	configPath := getConfigPath()
+	configPath := GetConfigPath()
 
 	// Ensure config directory exists (0700 for security)
 	configDir := filepath.Dir(configPath)
@@ -101,15 +118,43 @@ func (c *Config) GetActiveProfile() (*Profile, error) {
 		return nil, fmt.Errorf("no profile specified and no default profile set")
 	}
 
-	profile, ok := c.Profiles[profileName]
+	return c.GetProfile(profileName)
+}
+
+// GetProfile returns the named profile
+func (c *Config) GetProfile(name string) (*Profile, error) {
+	profile, ok := c.Profiles[name]
 	if !ok {
-		return nil, fmt.Errorf("profile %q not found", profileName)
+		return nil, fmt.Errorf("profile %q not found", name)
 	}
 
-	profile.Name = profileName
+	profile.Name = name
 	return &profile, nil
 }
 
+// FallbackChain returns the profiles to try after the given one, in order.
+// Unknown profiles are an error; duplicates and self-references are skipped.
+func (c *Config) FallbackChain(profile *Profile) ([]*Profile, error) {
+	seen := map[string]bool{profile.Name: true}
+	var chain []*Profile
+
+	for _, name := range profile.Fallback {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		fallback, ok := c.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("fallback profile %q (from %q) not found", name, profile.Name)
+		}
+		fallback.Name = name
+		chain = append(chain, &fallback)
+	}
+
+	return chain, nil
+}
+
 // AddProfile adds or updates a profile
 func (c *Config) AddProfile(name string, profile Profile) {
 	if c.Profiles == nil {
@@ -119,6 +164,120 @@ func (c *Config) AddProfile(name string, profile Profile) {
 	c.Profiles[name] = profile
 }
 
+// RemoveProfile deletes a profile, dropping it from other profiles' fallbacks
+// and clearing the default profile if it was the default
+func (c *Config) RemoveProfile(name string) error {
+	if _, ok := c.Profiles[name]; !ok {
+		return fmt.Errorf("profile %q not found", name)
+	}
+	delete(c.Profiles, name)
+
+	for other, profile := range c.Profiles {
+		if slices.Contains(profile.Fallback, name) {
+			profile.Fallback = slices.DeleteFunc(slices.Clone(profile.Fallback), func(fallback string) bool { return fallback == name })
+			c.Profiles[other] = profile
+		}
+	}
+	if c.DefaultProfile == name {
+		c.DefaultProfile = ""
+	}
+	return nil
+}
+
+// RenameProfile renames a profile, updating the default profile and other
+// profiles' fallbacks that refer to it
+func (c *Config) RenameProfile(oldName, newName string) error {
+	profile, ok := c.Profiles[oldName]
+	if !ok {
+		return fmt.Errorf("profile %q not found", oldName)
+	}
+	if _, exists := c.Profiles[newName]; exists {
+		return fmt.Errorf("profile %q already exists", newName)
+	}
+	delete(c.Profiles, oldName)
+	c.AddProfile(newName, profile)
+
+	for other, profile := range c.Profiles {
+		if i := slices.Index(profile.Fallback, oldName); i >= 0 {
+			profile.Fallback = slices.Clone(profile.Fallback)
+			profile.Fallback[i] = newName
+			c.Profiles[other] = profile
+		}
+	}
+	if c.DefaultProfile == oldName {
+		c.DefaultProfile = newName
+	}
+	return nil
+}
+
+// ValidateProfile checks a profile's settings, and that its fallbacks are
+// profiles in the config
+func (c *Config) ValidateProfile(profile Profile) error {
+	if err := profile.Validate(); err != nil {
+		return err
+	}
+	for _, name := range profile.Fallback {
+		if _, ok := c.Profiles[name]; !ok && name != profile.Name {
+			return fmt.Errorf("fallback profile %q not found", name)
+		}
+	}
+	return nil
+}
+
+// Validate checks that a profile names a supported provider and a model, and
+// that its limits make sense
+func (p *Profile) Validate() error {
+	if p.Provider == "" {
+		return fmt.Errorf("provider is required")
+	}
+	if !slices.Contains(Providers, p.Provider) {
+		return fmt.Errorf("unsupported provider %q (expected one of: %s)", p.Provider, strings.Join(Providers, ", "))
+	}
+	if p.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if p.ContextWindow < 0 || p.MaxContextWindow < 0 {
+		return fmt.Errorf("context windows can't be negative")
+	}
+	if p.Examples != nil && *p.Examples < 0 {
+		return fmt.Errorf("examples can't be negative")
+	}
+	if p.ConnectTimeout < 0 || p.FirstTokenTimeout < 0 || p.Timeout < 0 {
+		return fmt.Errorf("timeouts can't be negative")
+	}
+	return nil
+}
+
+// MarshalProfile writes a single profile's settings as TOML, as they appear
+// under [profiles.<name>] in the config file
+func MarshalProfile(profile Profile) ([]byte, error) {
+	data, err := toml.Marshal(profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal profile: %w", err)
+	}
+	return data, nil
+}
+
+// UnmarshalProfile reads a single profile's settings written by
+// MarshalProfile. Unknown settings are an error, so typos aren't silently
+// ignored.
+func UnmarshalProfile(data []byte) (Profile, error) {
+	var profile Profile
+	err := toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(&profile)
+
+	var strictErr *toml.StrictMissingError
+	var decodeErr *toml.DecodeError
+	switch {
+	case errors.As(err, &strictErr):
+		return Profile{}, fmt.Errorf("unknown settings:\n%s", strictErr.String())
+	case errors.As(err, &decodeErr):
+		return Profile{}, fmt.Errorf("invalid TOML:\n%s", decodeErr.String())
+	case err != nil:
+		return Profile{}, fmt.Errorf("invalid profile: %w", err)
+	}
+	return profile, nil
+}
+
 // GetContextWindow returns the context window for a profile, defaulting to 8192
 func (p *Profile) GetContextWindow() int {
 	if p.ContextWindow > 0 {
@@ -127,6 +286,66 @@ func (p *Profile) GetContextWindow() int {
 	return 8192 // Default context window
 }
 
+// GetConnectTimeout returns how long to wait for a connection to the provider
+func (p *Profile) GetConnectTimeout() time.Duration {
+	if p.ConnectTimeout > 0 {
+		return time.Duration(p.ConnectTimeout)
+	}
+	return 10 * time.Second
+}
+
+// GetFirstTokenTimeout returns how long to wait for the first token of a
+// streamed response, which includes loading a local model
+func (p *Profile) GetFirstTokenTimeout() time.Duration {
+	if p.FirstTokenTimeout > 0 {
+		return time.Duration(p.FirstTokenTimeout)
+	}
+	return 2 * time.Minute
+}
+
+// GetTimeout returns how long to wait for a whole request
+func (p *Profile) GetTimeout() time.Duration {
+	if p.Timeout > 0 {
+		return time.Duration(p.Timeout)
+	}
+	return 5 * time.Minute
+}
+
+// Duration is a time.Duration written as a string ("30s", "2m") in the config file
+type Duration time.Duration
+
+// MarshalText writes the duration in time.Duration's string form
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a duration such as "90s" or "1m30s"
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", text, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// GetMaxContextWindow returns the cap on a context window detected from the
+// model, which may advertise more than fits in memory
+func (p *Profile) GetMaxContextWindow() int {
+	if p.MaxContextWindow > 0 {
+		return p.MaxContextWindow
+	}
+	return 8192 // Default cap
+}
+
+// GetExamples returns how many accepted answers to include in prompts as examples
+func (p *Profile) GetExamples() int {
+	if p.Examples != nil {
+		return max(*p.Examples, 0)
+	}
+	return 3 // Default example count
+}
+
 // GetAPIKey returns the API key for the given provider
 // Checks environment variables first, then profile options
 func (c *Config) GetAPIKey(provider string) string {
@@ -154,8 +373,8 @@ func GetOllamaHost() string {
 	return "http://localhost:11434"
 }
 
-// getConfigPath returns the path to the config file
-func getConfigPath() string {
+// GetConfigPath returns the path to the config file
+func GetConfigPath() string {
 	configPath, err := xdg.ConfigFile("heyman/config.toml")
 	if err != nil {
 		// Fallback to home directory
@@ -165,6 +384,28 @@ func getConfigPath() string {
 	return configPath
 }
 
+// GetPromptsDir returns the directory holding prompt template overrides,
+// next to the config file
+func GetPromptsDir() string {
+	return filepath.Join(filepath.Dir(GetConfigPath()), "prompts")
+}
+
+// GetDataDir returns the directory for data heyman accumulates, such as
+// accepted answers
+func GetDataDir() string {
+	if dataDir := os.Getenv("HEYMAN_DATA_DIR"); dataDir != "" {
+		return dataDir
+	}
+
+	dataDir, err := xdg.DataFile("heyman")
+	if err != nil {
+		// Fallback to home directory
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, ".local", "share", "heyman")
+	}
+	return dataDir
+}
+
 // GetCacheDir returns the cache directory path
 func GetCacheDir() string {
 	if cacheDir := os.Getenv("HEYMAN_CACHE_DIR"); cacheDir != "" {
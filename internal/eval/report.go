@@ -0,0 +1,147 @@
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Result is the outcome of one case against one profile
+type Result struct {
+	Profile      string        `json:"profile"`
+	Case         string        `json:"case"`
+	Command      string        `json:"command,omitempty"`
+	Passed       bool          `json:"passed"`
+	Failures     []string      `json:"failures,omitempty"`
+	Error        string        `json:"error,omitempty"`
+	Latency      time.Duration `json:"latency_ns"`
+	TokensInput  int           `json:"tokens_input"`
+	TokensOutput int           `json:"tokens_output"`
+	Cost         *float64      `json:"cost,omitempty"` // nil when pricing is unknown
+	Cached       bool          `json:"cached"`
+}
+
+// ProfileSummary aggregates results for one profile
+type ProfileSummary struct {
+	Profile      string        `json:"profile"`
+	Model        string        `json:"model"`
+	Cases        int           `json:"cases"`
+	Passed       int           `json:"passed"`
+	Errors       int           `json:"errors"`
+	PassRate     float64       `json:"pass_rate"`
+	AvgLatency   time.Duration `json:"avg_latency_ns"`
+	TokensInput  int           `json:"tokens_input"`
+	TokensOutput int           `json:"tokens_output"`
+	Cost         *float64      `json:"cost,omitempty"` // nil when pricing is unknown for the model
+}
+
+// Report is the full output of an evaluation run
+type Report struct {
+	Suite     string           `json:"suite"`
+	StartedAt time.Time        `json:"started_at"`
+	Profiles  []ProfileSummary `json:"profiles"`
+	Results   []Result         `json:"results"`
+}
+
+// NewReport summarizes results per profile. models maps profile name to model ID.
+func NewReport(suite string, startedAt time.Time, models map[string]string, results []Result) *Report {
+	byProfile := make(map[string]*ProfileSummary)
+	var order []string
+
+	for _, r := range results {
+		summary, ok := byProfile[r.Profile]
+		if !ok {
+			summary = &ProfileSummary{Profile: r.Profile, Model: models[r.Profile]}
+			byProfile[r.Profile] = summary
+			order = append(order, r.Profile)
+		}
+
+		summary.Cases++
+		if r.Passed {
+			summary.Passed++
+		}
+		if r.Error != "" {
+			summary.Errors++
+		}
+		summary.AvgLatency += r.Latency
+		summary.TokensInput += r.TokensInput
+		summary.TokensOutput += r.TokensOutput
+		if r.Cost != nil {
+			total := *r.Cost
+			if summary.Cost != nil {
+				total += *summary.Cost
+			}
+			summary.Cost = &total
+		}
+	}
+
+	sort.Strings(order)
+	report := &Report{Suite: suite, StartedAt: startedAt, Results: results}
+	for _, name := range order {
+		summary := byProfile[name]
+		if summary.Cases > 0 {
+			summary.PassRate = float64(summary.Passed) / float64(summary.Cases)
+			summary.AvgLatency /= time.Duration(summary.Cases)
+		}
+		report.Profiles = append(report.Profiles, *summary)
+	}
+
+	return report
+}
+
+// FormatJSON formats the report as indented JSON
+func (r *Report) FormatJSON() (string, error) {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal report: %w", err)
+	}
+	return string(data), nil
+}
+
+// WriteTable writes the per-profile summary, followed by failing cases
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tMODEL\tPASS\tRATE\tAVG LATENCY\tTOKENS IN\tTOKENS OUT\tCOST")
+	for _, s := range r.Profiles {
+		cost := "-"
+		if s.Cost != nil {
+			cost = fmt.Sprintf("$%.4f", *s.Cost)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d/%d\t%.0f%%\t%s\t%d\t%d\t%s\n",
+			s.Profile, s.Model, s.Passed, s.Cases, s.PassRate*100,
+			s.AvgLatency.Round(time.Millisecond), s.TokensInput, s.TokensOutput, cost)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	var failed []Result
+	for _, res := range r.Results {
+		if !res.Passed {
+			failed = append(failed, res)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Failures:")
+	for _, res := range failed {
+		reason := res.Error
+		if reason == "" {
+			reason = strings.Join(res.Failures, "; ")
+		}
+		fmt.Fprintf(w, "  [%s] %s\n", res.Profile, res.Case)
+		if res.Command != "" {
+			fmt.Fprintf(w, "      got:    %s\n", res.Command)
+		}
+		fmt.Fprintf(w, "      reason: %s\n", reason)
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package eval
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/alecf/heyman/internal/manpage"
+	"github.com/alecf/heyman/internal/parser"
+	"go.yaml.in/yaml/v3"
+)
+
+// Suite is a set of questions with known-good answers, loaded from YAML
+type Suite struct {
+	Name     string   `yaml:"name"`
+	Profiles []string `yaml:"profiles"` // Default profiles to evaluate
+	Cases    []Case   `yaml:"cases"`
+}
+
+// Case is a single question and the answers considered correct
+type Case struct {
+	Name     string `yaml:"name"`
+	Command  string `yaml:"command"`
+	Section  string `yaml:"section"`
+	Question string `yaml:"question"`
+	Expect   Expect `yaml:"expect"`
+}
+
+// Expect describes acceptable answers for a case
+type Expect struct {
+	Match        []string `yaml:"match"`         // Regexes; the command must match at least one
+	RequireFlags []string `yaml:"require_flags"` // Flags that must all appear
+	ForbidFlags  []string `yaml:"forbid_flags"`  // Flags that must not appear
+
+	patterns []*regexp.Regexp
+}
+
+// Load reads and validates a suite file
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suite: %w", err)
+	}
+
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse suite %s: %w", path, err)
+	}
+
+	if suite.Name == "" {
+		suite.Name = path
+	}
+	if len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("suite %s has no cases", path)
+	}
+
+	for i := range suite.Cases {
+		c := &suite.Cases[i]
+		if c.Command == "" || c.Question == "" {
+			return nil, fmt.Errorf("case %d: command and question are required", i+1)
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("%s: %s", c.Command, c.Question)
+		}
+
+		for _, pattern := range c.Expect.Match {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("case %q: invalid match pattern %q: %w", c.Name, pattern, err)
+			}
+			c.Expect.patterns = append(c.Expect.patterns, re)
+		}
+	}
+
+	return &suite, nil
+}
+
+// Check returns the reasons an answer to the case fails its expectation, or
+// nil if it passes. Flags count only when given to the case's command; manPage
+// tells documented single-dash options ("find -name") from clusters ("-lhS").
+func (c *Case) Check(answer, manPage string) []string {
+	var failures []string
+	e := &c.Expect
+
+	if len(e.patterns) > 0 {
+		matched := false
+		for _, re := range e.patterns {
+			if re.MatchString(answer) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			failures = append(failures, "matches none of the expected patterns")
+		}
+	}
+
+	flags := parser.New(c.Command, false).Flags(answer)
+	for _, flag := range e.RequireFlags {
+		if !hasFlag(flags, flag, manPage) {
+			failures = append(failures, fmt.Sprintf("missing required flag %s", flag))
+		}
+	}
+	for _, flag := range e.ForbidFlags {
+		if hasFlag(flags, flag, manPage) {
+			failures = append(failures, fmt.Sprintf("uses forbidden flag %s", flag))
+		}
+	}
+
+	return failures
+}
+
+// hasFlag reports whether a flag is among the command's flags (as the parser
+// extracts them, without "=value"). Single-letter short flags also match when
+// combined with others ("-S" matches "-lhS"), unless the man page documents
+// the combination as an option of its own (find's -name has no -n).
+func hasFlag(flags []string, flag, manPage string) bool {
+	flag, _, _ = strings.Cut(flag, "=")
+	isShort := len(flag) == 2 && flag[0] == '-' && flag[1] != '-'
+
+	for _, arg := range flags {
+		if arg == flag {
+			return true
+		}
+
+		isCluster := len(arg) > 2 && arg[0] == '-' && arg[1] != '-'
+		if isShort && isCluster && strings.ContainsRune(arg[1:], rune(flag[1])) {
+			if _, documented := manpage.FindFlag(manPage, arg); !documented {
+				return true
+			}
+		}
+	}
+	return false
+}
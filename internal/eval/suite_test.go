@@ -0,0 +1,162 @@
+package eval
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const findManPage = `FIND(1)                    General Commands Manual                   FIND(1)
+
+EXPRESSION
+       -name pattern
+              Base of file name matches shell pattern pattern.
+
+       -print True; print the full file name on the standard output.
+
+       -newer reference
+              File was modified more recently than reference.`
+
+// writeSuite writes a suite file and returns its path
+func writeSuite(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "suite.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeSuite(t, `
+profiles: [fast]
+cases:
+  - command: ls
+    question: sort by size
+    expect:
+      match: ['^ls ']
+      require_flags: [-S]
+  - name: hidden
+    command: ls
+    section: "1"
+    question: show hidden files
+`)
+	suite, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suite.Name != path || !slices.Equal(suite.Profiles, []string{"fast"}) || len(suite.Cases) != 2 {
+		t.Fatalf("suite = %+v", suite)
+	}
+	if suite.Cases[0].Name != "ls: sort by size" || suite.Cases[1].Name != "hidden" || suite.Cases[1].Section != "1" {
+		t.Errorf("cases = %+v, want a default name for the unnamed one", suite.Cases)
+	}
+	if len(suite.Cases[0].Expect.patterns) != 1 {
+		t.Errorf("match patterns not compiled: %+v", suite.Cases[0].Expect)
+	}
+
+	for name, content := range map[string]string{
+		"no cases":        "name: empty\n",
+		"no question":     "cases:\n  - command: ls\n",
+		"invalid pattern": "cases:\n  - command: ls\n    question: q\n    expect:\n      match: ['(']\n",
+		"invalid YAML":    "cases: [\n",
+	} {
+		if _, err := Load(writeSuite(t, content)); err == nil {
+			t.Errorf("Load(%s) succeeded", name)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load(missing file) succeeded")
+	}
+}
+
+// loadCase loads a suite with a single case
+func loadCase(t *testing.T, content string) *Case {
+	t.Helper()
+	suite, err := Load(writeSuite(t, "cases:\n"+content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &suite.Cases[0]
+}
+
+func TestCheck(t *testing.T) {
+	ls := loadCase(t, `
+  - command: ls
+    question: sort by size
+    expect:
+      match: ['^ls ']
+      require_flags: [-S, --color]
+      forbid_flags: [-R]
+`)
+	find := loadCase(t, `
+  - command: find
+    question: find go files
+    expect:
+      require_flags: [-n]
+      forbid_flags: [-r]
+`)
+
+	tests := []struct {
+		c       *Case
+		answer  string
+		manPage string
+		want    []string
+	}{
+		{ls, "ls -lhS --color=auto", "", nil},
+		{ls, "ls -l -S --color", "", nil},
+		{ls, "sudo ls -lS --color", "", []string{"matches none of the expected patterns"}},
+		{ls, "ls -lSR --color", "", []string{"uses forbidden flag -R"}},
+		{ls, "ls -l --color | sort -S 1M", "", []string{"missing required flag -S"}},
+		{ls, "ls -lS --color | grep -R x", "", nil},
+		{ls, "ls -lS -- --color", "", []string{"missing required flag --color"}},
+		{find, "find . -name '*.go' -print", findManPage, []string{"missing required flag -n"}},
+		{find, "find . -newer a -n", findManPage, nil},
+		{find, "find . -nr", findManPage, []string{"uses forbidden flag -r"}},
+		// Without a man page, single-dash words are read as clusters
+		{find, "find . -print -n", "", []string{"uses forbidden flag -r"}},
+	}
+	for _, tt := range tests {
+		if got := tt.c.Check(tt.answer, tt.manPage); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Check(%q) = %q, want %q", tt.c.Command, tt.answer, got, tt.want)
+		}
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	cost := func(v float64) *float64 { return &v }
+	results := []Result{
+		{Profile: "slow", Case: "a", Passed: true, Latency: 4 * time.Second, TokensInput: 100, TokensOutput: 10},
+		{Profile: "fast", Case: "a", Passed: true, Latency: time.Second, TokensInput: 100, TokensOutput: 10, Cost: cost(0.01)},
+		{Profile: "fast", Case: "b", Failures: []string{"missing required flag -S"}, Command: "ls -l", Latency: 3 * time.Second, TokensInput: 50, TokensOutput: 5, Cost: cost(0.02)},
+		{Profile: "slow", Case: "b", Error: "rate limited"},
+	}
+	report := NewReport("suite", time.Now(), map[string]string{"fast": "small", "slow": "big"}, results)
+
+	if len(report.Profiles) != 2 || report.Profiles[0].Profile != "fast" || report.Profiles[1].Profile != "slow" {
+		t.Fatalf("profiles = %+v, want fast then slow", report.Profiles)
+	}
+	fast, slow := report.Profiles[0], report.Profiles[1]
+	if fast.Model != "small" || fast.Cases != 2 || fast.Passed != 1 || fast.PassRate != 0.5 || fast.AvgLatency != 2*time.Second {
+		t.Errorf("fast = %+v", fast)
+	}
+	if fast.TokensInput != 150 || fast.TokensOutput != 15 || fast.Cost == nil || *fast.Cost < 0.0299 || *fast.Cost > 0.0301 {
+		t.Errorf("fast totals = %+v, want tokens and cost summed", fast)
+	}
+	if slow.Errors != 1 || slow.Cost != nil {
+		t.Errorf("slow = %+v, want one error and no cost (pricing unknown)", slow)
+	}
+
+	var table strings.Builder
+	if err := report.WriteTable(&table); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1/2", "$0.0300", "[fast] b", "got:    ls -l", "missing required flag -S", "[slow] b", "rate limited"} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("table missing %q:\n%s", want, table.String())
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package examples
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alecf/heyman/internal/config"
+)
+
+// Sources of accepted examples
+const (
+	SourceCopy       = "copy"       // Copied to the clipboard
+	SourceRating     = "rating"     // Rated good with heyman feedback
+	SourceCheatsheet = "cheatsheet" // Generated by heyman cheatsheet
+)
+
+// Example is a question and the command the user accepted as its answer
+type Example struct {
+	Command   string    `json:"command"` // Program the question was about, e.g. "tar"
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Platform  string    `json:"platform,omitempty"` // Platform key the answer was given for
+	Source    string    `json:"source"`
+	Accepted  int       `json:"accepted"` // Times the answer was accepted
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Library stores accepted examples in a JSON file in the data directory
+type Library struct {
+	path string
+}
+
+// Open returns the user's example library
+func Open() *Library {
+	return &Library{path: filepath.Join(config.GetDataDir(), "examples.json")}
+}
+
+// load reads every example; a missing library is empty
+func (l *Library) load() ([]Example, error) {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read examples: %w", err)
+	}
+
+	var examples []Example
+	if err := json.Unmarshal(data, &examples); err != nil {
+		return nil, fmt.Errorf("failed to parse examples: %w", err)
+	}
+	return examples, nil
+}
+
+// save writes every example
+func (l *Library) save(examples []Example) error {
+	// Ensure data directory exists (0700 for security)
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(examples, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal examples: %w", err)
+	}
+
+	if err := os.WriteFile(l.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write examples: %w", err)
+	}
+	return nil
+}
+
+// Add records an accepted answer. Accepting the same answer to the same
+// question again bumps its count rather than adding a duplicate.
+func (l *Library) Add(example Example) error {
+	examples, err := l.load()
+	if err != nil {
+		return err
+	}
+
+	example.UpdatedAt = time.Now()
+	for i, existing := range examples {
+		if existing.Command == example.Command && existing.Platform == example.Platform &&
+			normalize(existing.Question) == normalize(example.Question) && existing.Answer == example.Answer {
+			examples[i].Accepted++
+			examples[i].Source = example.Source
+			examples[i].UpdatedAt = example.UpdatedAt
+			return l.save(examples)
+		}
+	}
+
+	example.Accepted = 1
+	return l.save(append(examples, example))
+}
+
+// Remove deletes every example with the given answer to the question,
+// returning how many were removed
+func (l *Library) Remove(command, question, answer string) (int, error) {
+	examples, err := l.load()
+	if err != nil {
+		return 0, err
+	}
+
+	kept := slices.DeleteFunc(slices.Clone(examples), func(e Example) bool {
+		return e.Command == command && normalize(e.Question) == normalize(question) && e.Answer == answer
+	})
+	removed := len(examples) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, l.save(kept)
+}
+
+// Find returns up to limit examples for a command, most relevant to the
+// question first. Examples given for a different platform are skipped, since
+// their flags may not exist here.
+func (l *Library) Find(command, platform, question string, limit int) ([]Example, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	examples, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+
+	words := wordSet(question)
+	type scored struct {
+		Example
+		score float64
+	}
+	var candidates []scored
+	for _, example := range examples {
+		if example.Command != command || (example.Platform != "" && platform != "" && example.Platform != platform) {
+			continue
+		}
+		candidates = append(candidates, scored{example, similarity(words, wordSet(example.Question))})
+	}
+
+	slices.SortStableFunc(candidates, func(a, b scored) int {
+		switch {
+		case a.score != b.score:
+			if a.score > b.score {
+				return -1
+			}
+			return 1
+		case a.Accepted != b.Accepted:
+			return b.Accepted - a.Accepted
+		default:
+			return b.UpdatedAt.Compare(a.UpdatedAt)
+		}
+	})
+
+	result := make([]Example, 0, min(limit, len(candidates)))
+	for _, candidate := range candidates[:min(limit, len(candidates))] {
+		result = append(result, candidate.Example)
+	}
+	return result, nil
+}
+
+// stopWords are ignored when comparing questions
+var stopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "how": true, "do": true, "i": true, "to": true,
+	"in": true, "of": true, "for": true, "with": true, "and": true, "or": true,
+	"is": true, "can": true, "what": true, "my": true, "me": true, "on": true,
+}
+
+// wordSet returns the distinct significant words of a question
+func wordSet(question string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '.')
+	}) {
+		if !stopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// similarity is the Jaccard index of two word sets
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for word := range a {
+		if b[word] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// normalize lowercases a question and collapses whitespace
+func normalize(question string) string {
+	return strings.Join(strings.Fields(strings.ToLower(question)), " ")
+}
@@ -0,0 +1,90 @@
+package examples
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func testLibrary(t *testing.T, examples ...Example) *Library {
+	t.Helper()
+	t.Setenv("HEYMAN_DATA_DIR", t.TempDir())
+	l := Open()
+	for _, example := range examples {
+		if err := l.Add(example); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+func TestFindRanksBySimilarity(t *testing.T) {
+	l := testLibrary(t,
+		Example{Command: "tar", Question: "create a gzip archive of a directory", Answer: "tar -czf out.tgz dir"},
+		Example{Command: "tar", Question: "extract a tar.gz archive", Answer: "tar -xzf archive.tar.gz"},
+		Example{Command: "tar", Question: "list the files in an archive", Answer: "tar -tf archive.tar"},
+		Example{Command: "tar", Question: "extract one file", Answer: "tar -xf archive.tar file", Platform: "darwin/bsd"},
+		Example{Command: "ls", Question: "extract a tar.gz archive", Answer: "ls"},
+	)
+
+	tests := []struct {
+		name     string
+		platform string
+		question string
+		limit    int
+		want     []string // Answers, in order
+	}{
+		{"closest first", "linux/gnu", "how do I extract a .tar.gz archive", 2, []string{"tar -xzf archive.tar.gz", "tar -tf archive.tar"}},
+		{"other platforms skipped", "linux/gnu", "extract one file", 1, []string{"tar -xzf archive.tar.gz"}},
+		{"same platform kept", "darwin/bsd", "extract one file", 1, []string{"tar -xf archive.tar file"}},
+		{"unknown platform keeps all", "", "extract one file", 1, []string{"tar -xf archive.tar file"}},
+		{"limit caps results", "linux/gnu", "archive", 1, []string{"tar -tf archive.tar"}},
+		{"no limit", "linux/gnu", "extract", 0, nil},
+	}
+	for _, tt := range tests {
+		found, err := l.Find("tar", tt.platform, tt.question, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, example := range found {
+			got = append(got, example.Answer)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Find() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindBreaksTiesByAcceptance(t *testing.T) {
+	l := testLibrary(t,
+		Example{Command: "du", Question: "disk usage", Answer: "du -s"},
+		Example{Command: "du", Question: "disk usage", Answer: "du -sh"},
+		Example{Command: "du", Question: "Disk  usage", Answer: "du -sh"}, // Same question: accepted twice
+	)
+
+	found, err := l.Find("du", "", "unrelated words", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Answer != "du -sh" || found[0].Accepted != 2 {
+		t.Errorf("Find() = %+v, want du -sh (accepted twice) first", found)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"extract a tar.gz archive", "how do I extract the tar.gz archive", 1},
+		{"extract archive", "create archive", 1.0 / 3},
+		{"list files", "extract archive", 0},
+		{"how do I", "extract archive", 0}, // Only stop words
+	}
+	for _, tt := range tests {
+		if got := similarity(wordSet(tt.a), wordSet(tt.b)); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
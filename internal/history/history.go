@@ -0,0 +1,192 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alecf/heyman/internal/config"
+)
+
+// Entry is one answered query
+type Entry struct {
+	N           int       `json:"-"` // 1-based position in the history, set when loading
+	Time        time.Time `json:"time"`
+	Command     string    `json:"command"`
+	Section     string    `json:"section,omitempty"`
+	Question    string    `json:"question"`
+	Answer      string    `json:"answer"`
+	Explanation string    `json:"explanation,omitempty"`
+	Profile     string    `json:"profile"`
+	Provider    string    `json:"provider,omitempty"`
+	Model       string    `json:"model"`
+	Platform    string    `json:"platform,omitempty"` // Platform key, e.g. "darwin/bsd"
+	Cwd         string    `json:"cwd,omitempty"`
+	CacheKey    string    `json:"cache_key,omitempty"`
+	Cached      bool      `json:"cached"`
+}
+
+// History is an append-only JSON Lines log of queries in the data directory
+type History struct {
+	path string
+}
+
+// Open returns the user's query history
+func Open() *History {
+	return &History{path: filepath.Join(config.GetDataDir(), "history.jsonl")}
+}
+
+// Append adds an entry to the end of the history
+func (h *History) Append(entry Entry) error {
+	// Ensure data directory exists (0700 for security)
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Load returns every entry, oldest first. Unreadable lines are skipped but
+// still counted so entry numbers stay stable.
+func (h *History) Load() ([]Entry, error) {
+	f, err := os.Open(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	n := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		n++
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entry.N = n
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return entries, nil
+}
+
+// Get returns entry n (1-based). Negative n counts back from the end, as in
+// shell history: -1 is the last entry.
+func (h *History) Get(n int) (*Entry, error) {
+	entries, err := h.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("history is empty")
+	}
+
+	if n < 0 {
+		if i := len(entries) + n; i >= 0 {
+			return &entries[i], nil
+		}
+	} else {
+		for i := range entries {
+			if entries[i].N == n {
+				return &entries[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no history entry %d", n)
+}
+
+// Search returns the entries matching every term, best match first. Terms
+// match fuzzily: a term matches text containing its letters in order
+// ("tgz" matches "tar.gz"), though exact substrings rank higher.
+func (h *History) Search(terms []string) ([]Entry, error) {
+	entries, err := h.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		Entry
+		score int
+	}
+	var matches []scored
+	for _, entry := range entries {
+		text := strings.ToLower(entry.Command + " " + entry.Question + " " + entry.Answer)
+		total := 0
+		for _, term := range terms {
+			score := matchScore(text, strings.ToLower(term))
+			if score == 0 {
+				total = 0
+				break
+			}
+			total += score
+		}
+		if total > 0 {
+			matches = append(matches, scored{entry, total})
+		}
+	}
+
+	// Best score first, then most recent
+	slices.SortStableFunc(matches, func(a, b scored) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		return b.N - a.N
+	})
+
+	result := make([]Entry, len(matches))
+	for i, match := range matches {
+		result[i] = match.Entry
+	}
+	return result, nil
+}
+
+// matchScore scores how well a term matches text: 3 for a whole word, 2 for a
+// substring, 1 for a subsequence, 0 for no match
+func matchScore(text, term string) int {
+	if term == "" {
+		return 1
+	}
+	if idx := strings.Index(text, term); idx >= 0 {
+		if slices.Contains(strings.Fields(text), term) {
+			return 3
+		}
+		return 2
+	}
+
+	remaining := []rune(term)
+	for _, r := range text {
+		if r == remaining[0] {
+			remaining = remaining[1:]
+			if len(remaining) == 0 {
+				return 1
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package history
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func testHistory(t *testing.T, entries ...Entry) *History {
+	t.Helper()
+	t.Setenv("HEYMAN_DATA_DIR", t.TempDir())
+	h := Open()
+	for _, entry := range entries {
+		if err := h.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return h
+}
+
+func TestLoadNumbersEntriesStably(t *testing.T) {
+	h := testHistory(t, Entry{Time: time.Now(), Command: "ls", Question: "list files", Answer: "ls -l"})
+
+	// A corrupt line is skipped but keeps its number
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("not json\n")
+	f.Close()
+	if err := h.Append(Entry{Time: time.Now(), Command: "du", Question: "disk usage", Answer: "du -sh"}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := h.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].N != 1 || entries[1].N != 3 {
+		t.Fatalf("entries = %+v, want numbers 1 and 3", entries)
+	}
+}
+
+func TestGet(t *testing.T) {
+	h := testHistory(t,
+		Entry{Command: "ls", Answer: "ls -l"},
+		Entry{Command: "du", Answer: "du -sh"},
+		Entry{Command: "tar", Answer: "tar -xzf archive.tar.gz"},
+	)
+
+	tests := []struct {
+		n       int
+		want    string
+		wantErr bool
+	}{
+		{n: 1, want: "ls -l"},
+		{n: 3, want: "tar -xzf archive.tar.gz"},
+		{n: -1, want: "tar -xzf archive.tar.gz"}, // "!!"
+		{n: -3, want: "ls -l"},
+		{n: 4, wantErr: true},
+		{n: -4, wantErr: true},
+		{n: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		entry, err := h.Get(tt.n)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Get(%d) = %q, want an error", tt.n, entry.Answer)
+			}
+			continue
+		}
+		if err != nil || entry.Answer != tt.want {
+			t.Errorf("Get(%d) = %v, %v; want %q", tt.n, entry, err, tt.want)
+		}
+	}
+
+	if _, err := testHistory(t).Get(-1); err == nil {
+		t.Error("Get(-1) on an empty history succeeded")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	h := testHistory(t,
+		Entry{Command: "tar", Question: "extract a tar.gz", Answer: "tar -xzf archive.tar.gz"},
+		Entry{Command: "ls", Question: "list files by size", Answer: "ls -lS"},
+		Entry{Command: "tar", Question: "create an archive", Answer: "tar -czf out.tgz dir"},
+	)
+
+	tests := []struct {
+		terms []string
+		want  []int // Entry numbers, best match first
+	}{
+		{terms: []string{"tgz"}, want: []int{3, 1}},             // Substring beats subsequence
+		{terms: []string{"TAR", "extract"}, want: []int{1}},     // Every term must match, ignoring case
+		{terms: []string{"archive"}, want: []int{3, 1}},         // Whole word beats substring
+		{terms: []string{"size", "nothing-like-it"}, want: nil}, // One term missing
+	}
+	for _, tt := range tests {
+		entries, err := h.Search(tt.terms)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []int
+		for _, entry := range entries {
+			got = append(got, entry.N)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.terms, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Search(%q) = %v, want %v", tt.terms, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMatchScore(t *testing.T) {
+	tests := []struct {
+		text, term string
+		want       int
+	}{
+		{"tar -xzf archive.tar.gz", "archive.tar.gz", 3},
+		{"tar -xzf archive.tar.gz", "tar.gz", 2},
+		{"tar -xzf archive.tar.gz", "tgz", 1},
+		{"tar -xzf archive.tar.gz", "zip", 0},
+		{"anything", "", 1},
+	}
+	for _, tt := range tests {
+		if got := matchScore(tt.text, tt.term); got != tt.want {
+			t.Errorf("matchScore(%q, %q) = %d, want %d", tt.text, tt.term, got, tt.want)
+		}
+	}
+}
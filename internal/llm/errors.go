@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"github.com/openai/openai-go/v3"
+)
+
+// Error classes for provider failures. Use errors.Is to test for them.
+var (
+	ErrAuth          = errors.New("authentication failed")
+	ErrRateLimited   = errors.New("rate limited")
+	ErrContextLength = errors.New("context length exceeded")
+	ErrModelNotFound = errors.New("model not found")
+	ErrUnavailable   = errors.New("provider unavailable")
+	ErrTimeout       = errors.New("timed out")
+)
+
+// TimeoutError reports a request that ran past one of its profile's time limits
+type TimeoutError struct {
+	Model string        // Model the request was sent to
+	Limit time.Duration // The limit that was exceeded
+	Wait  string        // What was being waited for ("first token", "response")
+}
+
+// Error returns the error message
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("no %s from %s within %s", e.Wait, e.Model, e.Limit)
+}
+
+// Unwrap classifies the error as ErrTimeout
+func (e *TimeoutError) Unwrap() error {
+	return ErrTimeout
+}
+
+// Error is a classified provider error
+type Error struct {
+	Provider   string        // Provider name (openai, ollama)
+	Kind       error         // One of the Err* classes, nil if unclassified
+	StatusCode int           // HTTP status code, 0 if the request never got a response
+	RetryAfter time.Duration // Server-requested delay before retrying, 0 if none
+	Err        error         // Underlying error
+}
+
+// Error returns the error message
+func (e *Error) Error() string {
+	name := e.Provider
+	switch name {
+	case "openai":
+		name = "OpenAI"
+	case "ollama":
+		name = "Ollama"
+	}
+
+	if e.Kind != nil {
+		return fmt.Sprintf("%s API error (%v): %v", name, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%s API error: %v", name, e.Err)
+}
+
+// Unwrap exposes both the error class and the underlying error to errors.Is/As
+func (e *Error) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// IsTransient reports whether an error is likely to go away if the same
+// request is retried against the same provider
+func IsTransient(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrUnavailable)
+}
+
+// RetryAfter returns the server-requested retry delay carried by err, if any
+func RetryAfter(err error) (time.Duration, bool) {
+	var providerErr *Error
+	if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+		return providerErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// classifyOpenAIError maps an OpenAI SDK error to a classified Error
+func classifyOpenAIError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	classified := &Error{Provider: "openai", Err: err}
+
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) {
+		classified.StatusCode = apiErr.StatusCode
+		if apiErr.Response != nil {
+			classified.RetryAfter = parseRetryAfter(apiErr.Response.Header)
+		}
+
+		switch {
+		case apiErr.Code == "context_length_exceeded":
+			classified.Kind = ErrContextLength
+		case apiErr.Code == "model_not_found":
+			classified.Kind = ErrModelNotFound
+		default:
+			classified.Kind = classifyStatus(apiErr.StatusCode)
+		}
+		return classified
+	}
+
+	classified.Kind = classifyTransportError(err)
+	return classified
+}
+
+// classifyOllamaError maps an Ollama client error to a classified Error
+func classifyOllamaError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	classified := &Error{Provider: "ollama", Err: err}
+
+	var authErr api.AuthorizationError
+	if errors.As(err, &authErr) {
+		classified.StatusCode = authErr.StatusCode
+		classified.Kind = ErrAuth
+		return classified
+	}
+
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		classified.StatusCode = statusErr.StatusCode
+		msg := strings.ToLower(statusErr.ErrorMessage)
+		switch {
+		case statusErr.StatusCode == http.StatusNotFound || strings.Contains(msg, "not found, try pulling"):
+			classified.Kind = ErrModelNotFound
+		case strings.Contains(msg, "context length") || strings.Contains(msg, "context window"):
+			classified.Kind = ErrContextLength
+		default:
+			classified.Kind = classifyStatus(statusErr.StatusCode)
+		}
+		return classified
+	}
+
+	classified.Kind = classifyTransportError(err)
+	return classified
+}
+
+// classifyStatus maps an HTTP status code to an error class
+func classifyStatus(status int) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusNotFound:
+		return ErrModelNotFound
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusRequestTimeout || status >= http.StatusInternalServerError:
+		return ErrUnavailable
+	default:
+		return nil
+	}
+}
+
+// classifyTransportError maps network-level failures (the request never got
+// an HTTP response) to ErrUnavailable
+func classifyTransportError(err error) error {
+	// The caller cancelling is not a provider failure
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return ErrUnavailable
+	}
+
+	// Connection dropped mid-response
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrUnavailable
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrUnavailable
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrUnavailable
+	}
+
+	return nil
+}
+
+// parseRetryAfter reads a Retry-After (or retry-after-ms) header.
+// Supports both delay-seconds and HTTP-date forms.
+func parseRetryAfter(header http.Header) time.Duration {
+	if ms := header.Get("Retry-After-Ms"); ms != "" {
+		if n, err := strconv.ParseFloat(ms, 64); err == nil && n > 0 {
+			return time.Duration(n * float64(time.Millisecond))
+		}
+	}
+
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(when); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,188 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeResponse is one scripted reply from a FakeProvider
+type FakeResponse struct {
+	Content      string
+	Chunks       []string // Streamed pieces; defaults to Content as a single chunk
+	TokensInput  int
+	TokensOutput int
+	Err          error         // Returned instead of the response (after any Chunks when streaming)
+	Delay        time.Duration // Wait before replying, or before the first chunk when streaming
+}
+
+// FakeProvider is a deterministic Provider for tests. Each Query or
+// StreamQuery call consumes the next scripted response in order.
+type FakeProvider struct {
+	ProviderName string // Defaults to "fake"
+	Structured   bool   // Whether to report structured output support
+
+	mu        sync.Mutex
+	responses []FakeResponse
+	requests  []QueryRequest
+}
+
+// NewFakeProvider creates a fake provider that replies with the given responses in order
+func NewFakeProvider(responses ...FakeResponse) *FakeProvider {
+	return &FakeProvider{responses: responses}
+}
+
+// Script appends more responses to the queue
+func (p *FakeProvider) Script(responses ...FakeResponse) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses = append(p.responses, responses...)
+}
+
+// Requests returns every request the provider has received
+func (p *FakeProvider) Requests() []QueryRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]QueryRequest(nil), p.requests...)
+}
+
+// next records the request and pops the next scripted response
+func (p *FakeProvider) next(req QueryRequest) (FakeResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.requests = append(p.requests, req)
+	if len(p.responses) == 0 {
+		return FakeResponse{}, fmt.Errorf("fake provider: no scripted response for request %d", len(p.requests))
+	}
+
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	return resp, nil
+}
+
+// Query returns the next scripted response
+func (p *FakeProvider) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+	scripted, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := sleep(ctx, scripted.Delay); err != nil {
+		return nil, err
+	}
+	if scripted.Err != nil {
+		return nil, scripted.Err
+	}
+
+	content := scripted.Content
+	if content == "" {
+		for _, chunk := range scripted.Chunks {
+			content += chunk
+		}
+	}
+
+	return &QueryResponse{
+		Content:      content,
+		TokensInput:  scripted.TokensInput,
+		TokensOutput: scripted.TokensOutput,
+		Model:        req.Model,
+		Provider:     p.Name(),
+		Cached:       false,
+	}, nil
+}
+
+// StreamQuery streams the next scripted response chunk by chunk
+func (p *FakeProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-chan StreamChunk, <-chan error) {
+	chunkCh := make(chan StreamChunk)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+
+		scripted, err := p.next(req)
+		if err != nil {
+			errCh <- err
+			return
+		}
+
+		if err := sleep(ctx, scripted.Delay); err != nil {
+			errCh <- err
+			return
+		}
+
+		chunks := scripted.Chunks
+		if len(chunks) == 0 && scripted.Content != "" {
+			chunks = []string{scripted.Content}
+		}
+
+		send := func(chunk StreamChunk) bool {
+			select {
+			case chunkCh <- chunk:
+				return true
+			case <-ctx.Done():
+				errCh <- ctx.Err()
+				return false
+			}
+		}
+
+		for _, content := range chunks {
+			if !send(StreamChunk{Content: content}) {
+				return
+			}
+		}
+
+		if scripted.Err != nil {
+			errCh <- scripted.Err
+			return
+		}
+
+		send(StreamChunk{
+			IsComplete:   true,
+			TokensInput:  scripted.TokensInput,
+			TokensOutput: scripted.TokensOutput,
+		})
+	}()
+
+	return chunkCh, errCh
+}
+
+// GetAvailableModels returns no models
+func (p *FakeProvider) GetAvailableModels(ctx context.Context) ([]Model, error) {
+	return nil, nil
+}
+
+// Name returns the provider name
+func (p *FakeProvider) Name() string {
+	if p.ProviderName != "" {
+		return p.ProviderName
+	}
+	return "fake"
+}
+
+// SupportsStreaming indicates that the fake provider supports streaming
+func (p *FakeProvider) SupportsStreaming() bool {
+	return true
+}
+
+// SupportsStructuredOutput reports the Structured setting
+func (p *FakeProvider) SupportsStructuredOutput(model string) bool {
+	return p.Structured
+}
+
+// sleep waits for d, returning early with ctx's error if it is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,32 +1,49 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
 )
 
 // OllamaProvider implements the Provider interface for Ollama
 type OllamaProvider struct {
-	client *api.Client
+	client     *api.Client
+	host       *url.URL     // Server the client talks to
+	httpClient *http.Client // For endpoints the api package has no method for
+
+	// KeepAlive, if set, is how long Ollama keeps the model loaded after each
+	// request (negative keeps it loaded indefinitely); nil uses the server default
+	KeepAlive *time.Duration
 }
 
-// NewOllamaProvider creates a new Ollama provider
-func NewOllamaProvider() (*OllamaProvider, error) {
-	// Initialize client from environment (OLLAMA_HOST)
-	client, err := api.ClientFromEnvironment()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Ollama client: %w", err)
-	}
+// NewOllamaProvider creates a new Ollama provider. Connecting to the server
+// fails after connectTimeout (0 for no limit).
+func NewOllamaProvider(connectTimeout time.Duration) (*OllamaProvider, error) {
+	// Initialize client from environment (OLLAMA_HOST), honoring record/replay mode
+	host := envconfig.Host()
+	httpClient := HTTPClientFromEnv(connectTimeout)
 
 	return &OllamaProvider{
-		client: client,
+		client:     api.NewClient(host, httpClient),
+		host:       host,
+		httpClient: httpClient,
 	}, nil
 }
 
-// Query sends a non-streaming request to Ollama
-func (p *OllamaProvider) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+// chatRequest builds the Ollama chat request for a query
+func (p *OllamaProvider) chatRequest(req QueryRequest) (*api.ChatRequest, error) {
 	messages := []api.Message{
 		{
 			Role:    "system",
@@ -48,10 +65,30 @@ func (p *OllamaProvider) Query(ctx context.Context, req QueryRequest) (*QueryRes
 		Model:    req.Model,
 		Messages: messages,
 		Options: map[string]interface{}{
-			"temperature":   req.Temperature,
-			"num_predict":   req.MaxTokens,
-			"num_ctx":       contextWindow,
+			"temperature": req.Temperature,
+			"num_predict": req.MaxTokens,
+			"num_ctx":     contextWindow,
 		},
+		KeepAlive: p.keepAlive(),
+	}
+
+	// Constrain output to the JSON schema when requested
+	if req.ResponseSchema != nil {
+		format, err := json.Marshal(req.ResponseSchema.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode response schema: %w", err)
+		}
+		chatReq.Format = format
+	}
+
+	return chatReq, nil
+}
+
+// Query sends a non-streaming request to Ollama
+func (p *OllamaProvider) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+	chatReq, err := p.chatRequest(req)
+	if err != nil {
+		return nil, err
 	}
 
 	// Accumulate response content
@@ -69,9 +106,9 @@ func (p *OllamaProvider) Query(ctx context.Context, req QueryRequest) (*QueryRes
 		return nil
 	}
 
-	err := p.client.Chat(ctx, chatReq, respFunc)
+	err = p.client.Chat(ctx, chatReq, respFunc)
 	if err != nil {
-		return nil, fmt.Errorf("Ollama API error: %w", err)
+		return nil, classifyOllamaError(err)
 	}
 
 	return &QueryResponse{
@@ -84,7 +121,8 @@ func (p *OllamaProvider) Query(ctx context.Context, req QueryRequest) (*QueryRes
 	}, nil
 }
 
-// StreamQuery sends a streaming request to Ollama
+// StreamQuery sends a streaming request to Ollama. The stream stops when ctx
+// is cancelled, so callers that stop reading must cancel it.
 func (p *OllamaProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-chan StreamChunk, <-chan error) {
 	chunkCh := make(chan StreamChunk)
 	errCh := make(chan error, 1)
@@ -93,31 +131,10 @@ func (p *OllamaProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-c
 		defer close(chunkCh)
 		defer close(errCh)
 
-		messages := []api.Message{
-			{
-				Role:    "system",
-				Content: req.SystemPrompt,
-			},
-			{
-				Role:    "user",
-				Content: req.UserPrompt,
-			},
-		}
-
-		// Use configured context window, default to 8192 if not set
-		contextWindow := req.ContextWindow
-		if contextWindow == 0 {
-			contextWindow = 8192
-		}
-
-		chatReq := &api.ChatRequest{
-			Model:    req.Model,
-			Messages: messages,
-			Options: map[string]interface{}{
-				"temperature":   req.Temperature,
-				"num_predict":   req.MaxTokens,
-				"num_ctx":       contextWindow,
-			},
+		chatReq, err := p.chatRequest(req)
+		if err != nil {
+			errCh <- err
+			return
 		}
 
 		var promptTokens, completionTokens int
@@ -128,27 +145,28 @@ func (p *OllamaProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-c
 				promptTokens = resp.PromptEvalCount
 				completionTokens = resp.EvalCount
 
-				chunkCh <- StreamChunk{
+				if !sendChunk(ctx, chunkCh, StreamChunk{
 					Content:      "",
 					IsComplete:   true,
 					TokensInput:  promptTokens,
 					TokensOutput: completionTokens,
+				}) {
+					return ctx.Err()
 				}
 			} else {
 				// Stream content chunks
 				if resp.Message.Content != "" {
-					chunkCh <- StreamChunk{
-						Content:    resp.Message.Content,
-						IsComplete: false,
+					if !sendChunk(ctx, chunkCh, StreamChunk{Content: resp.Message.Content}) {
+						return ctx.Err()
 					}
 				}
 			}
 			return nil
 		}
 
-		err := p.client.Chat(ctx, chatReq, respFunc)
+		err = p.client.Chat(ctx, chatReq, respFunc)
 		if err != nil {
-			errCh <- fmt.Errorf("stream error: %w", err)
+			errCh <- fmt.Errorf("stream error: %w", classifyOllamaError(err))
 			return
 		}
 	}()
@@ -156,11 +174,105 @@ func (p *OllamaProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-c
 	return chunkCh, errCh
 }
 
+// keepAlive returns the keep-alive to send with requests, nil for the server default
+func (p *OllamaProvider) keepAlive() *api.Duration {
+	if p.KeepAlive == nil {
+		return nil
+	}
+	return &api.Duration{Duration: *p.KeepAlive}
+}
+
+// Heartbeat checks that the Ollama server is reachable
+func (p *OllamaProvider) Heartbeat(ctx context.Context) error {
+	if err := p.client.Heartbeat(ctx); err != nil {
+		return classifyOllamaError(err)
+	}
+	return nil
+}
+
+// HasModel reports whether a model is installed on the Ollama server
+func (p *OllamaProvider) HasModel(ctx context.Context, model string) (bool, error) {
+	_, err := p.client.Show(ctx, &api.ShowRequest{Model: model})
+	if err == nil {
+		return true, nil
+	}
+	err = classifyOllamaError(err)
+	if errors.Is(err, ErrModelNotFound) {
+		return false, nil
+	}
+	return false, err
+}
+
+// PullProgress receives updates while a model downloads: the current step
+// ("pulling manifest", "pulling <digest>", "verifying sha256 digest", ...)
+// and, while a layer downloads, its bytes completed and total
+type PullProgress func(status string, completed, total int64)
+
+// PullModel downloads a model to the Ollama server
+func (p *OllamaProvider) PullModel(ctx context.Context, model string, progress PullProgress) error {
+	err := p.client.Pull(ctx, &api.PullRequest{Model: model}, func(resp api.ProgressResponse) error {
+		if progress != nil {
+			progress(resp.Status, resp.Completed, resp.Total)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to pull %s: %w", model, classifyOllamaError(err))
+	}
+	return nil
+}
+
+// Warm loads a model into memory without generating anything, so the next
+// query doesn't wait for it to load. The model stays loaded for KeepAlive.
+func (p *OllamaProvider) Warm(ctx context.Context, model string) error {
+	req := &api.GenerateRequest{
+		Model:     model,
+		KeepAlive: p.keepAlive(),
+	}
+	if err := p.client.Generate(ctx, req, func(api.GenerateResponse) error { return nil }); err != nil {
+		return fmt.Errorf("failed to load %s: %w", model, classifyOllamaError(err))
+	}
+	return nil
+}
+
+// Tokenize returns the tokens a model's tokenizer splits text into. Servers
+// without the tokenize endpoint return an error.
+func (p *OllamaProvider) Tokenize(ctx context.Context, model, text string) ([]int, error) {
+	body, err := json.Marshal(map[string]string{"model": model, "content": text})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.host.JoinPath("/api/tokenize").String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, classifyOllamaError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{Provider: "ollama", Kind: classifyStatus(resp.StatusCode), StatusCode: resp.StatusCode, Err: fmt.Errorf("tokenize: %s", resp.Status)}
+	}
+
+	var tokenized struct {
+		Tokens []int `json:"tokens"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenized); err != nil {
+		return nil, fmt.Errorf("failed to decode tokenize response: %w", err)
+	}
+	return tokenized.Tokens, nil
+}
+
 // GetAvailableModels returns the list of available Ollama models
 func (p *OllamaProvider) GetAvailableModels(ctx context.Context) ([]Model, error) {
 	listResp, err := p.client.List(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list Ollama models: %w", err)
+		return nil, fmt.Errorf("failed to list Ollama models: %w", classifyOllamaError(err))
 	}
 
 	models := make([]Model, 0, len(listResp.Models))
@@ -186,31 +298,99 @@ func (p *OllamaProvider) SupportsStreaming() bool {
 	return true
 }
 
-// GetModelContextWindow queries Ollama for the model's context window size
-func (p *OllamaProvider) GetModelContextWindow(ctx context.Context, modelName string) (int, error) {
-	showReq := &api.ShowRequest{
-		Name: modelName,
+// SupportsStructuredOutput indicates that Ollama can constrain any model to a
+// JSON schema via the format parameter
+func (p *OllamaProvider) SupportsStructuredOutput(model string) bool {
+	return true
+}
+
+// ModelInfo is what Ollama reports about an installed model
+type ModelInfo struct {
+	ContextLength int `json:"context_length,omitempty"` // Longest context the model was trained for, 0 if unknown
+	NumCtx        int `json:"num_ctx,omitempty"`        // Context window set by the model's Modelfile, 0 if none
+}
+
+// ContextWindow returns the context window Ollama would use for the model:
+// its Modelfile's num_ctx if set (within what the model supports), otherwise
+// what it was trained for capped at limit. 0 if neither is known.
+func (m ModelInfo) ContextWindow(limit int) int {
+	switch {
+	case m.NumCtx > 0 && m.ContextLength > 0:
+		return min(m.NumCtx, m.ContextLength)
+	case m.NumCtx > 0:
+		return m.NumCtx
+	case limit > 0:
+		return min(m.ContextLength, limit)
 	}
+	return m.ContextLength
+}
 
-	showResp, err := p.client.Show(ctx, showReq)
+// GetModelInfo asks Ollama about an installed model
+func (p *OllamaProvider) GetModelInfo(ctx context.Context, modelName string) (*ModelInfo, error) {
+	showResp, err := p.client.Show(ctx, &api.ShowRequest{Model: modelName})
 	if err != nil {
-		return 0, fmt.Errorf("failed to get model info: %w", err)
-	}
-
-	// Look for context_length in model_info
-	modelInfo := showResp.ModelInfo
-	// Check different possible field names for context length
-	for _, key := range []string{
-		"mistral3.context_length",
-		"llama.context_length",
-		"context_length",
-	} {
-		if val, ok := modelInfo[key]; ok {
-			if ctxLen, ok := val.(float64); ok {
-				return int(ctxLen), nil
-			}
+		return nil, fmt.Errorf("failed to get model info: %w", classifyOllamaError(err))
+	}
+
+	return &ModelInfo{
+		ContextLength: contextLength(showResp.ModelInfo),
+		NumCtx:        parameterInt(showResp.Parameters, "num_ctx"),
+	}, nil
+}
+
+// contextLength finds the trained context length in a model's metadata. Keys
+// are prefixed with the model's architecture ("qwen2.context_length",
+// "gemma3.context_length"), named by general.architecture.
+func contextLength(modelInfo map[string]any) int {
+	if arch, ok := modelInfo["general.architecture"].(string); ok {
+		if n := metadataInt(modelInfo[arch+".context_length"]); n > 0 {
+			return n
+		}
+	}
+
+	// Older servers may not report the architecture: take any architecture's
+	// key, preferring the first in sorted order so the result is stable
+	var keys []string
+	for key := range modelInfo {
+		if key == "context_length" || strings.HasSuffix(key, ".context_length") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if n := metadataInt(modelInfo[key]); n > 0 {
+			return n
 		}
 	}
+	return 0
+}
+
+// metadataInt converts a numeric model metadata value, 0 if it isn't one
+func metadataInt(value any) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	}
+	return 0
+}
 
-	return 0, fmt.Errorf("context_length not found in model info")
+// parameterInt reads an integer parameter from a model's Modelfile
+// parameters, which Ollama reports one per line as "name value"
+func parameterInt(parameters, name string) int {
+	for _, line := range strings.Split(parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == name {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
 }
@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestModelInfoContextWindow(t *testing.T) {
+	tests := []struct {
+		name  string
+		info  ModelInfo
+		limit int
+		want  int
+	}{
+		{"Modelfile num_ctx", ModelInfo{ContextLength: 131072, NumCtx: 8192}, 32768, 8192},
+		{"num_ctx beyond training", ModelInfo{ContextLength: 4096, NumCtx: 8192}, 32768, 4096},
+		{"num_ctx alone", ModelInfo{NumCtx: 16384}, 32768, 16384},
+		{"trained length capped", ModelInfo{ContextLength: 131072}, 32768, 32768},
+		{"trained length under the cap", ModelInfo{ContextLength: 8192}, 32768, 8192},
+		{"no cap", ModelInfo{ContextLength: 131072}, 0, 131072},
+		{"unknown", ModelInfo{}, 32768, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.info.ContextWindow(tt.limit); got != tt.want {
+			t.Errorf("%s: ContextWindow(%d) = %d, want %d", tt.name, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestContextLength(t *testing.T) {
+	tests := []struct {
+		name      string
+		modelInfo map[string]any
+		want      int
+	}{
+		{"named architecture", map[string]any{"general.architecture": "qwen2", "qwen2.context_length": float64(32768), "llama.context_length": float64(4096)}, 32768},
+		{"JSON number", map[string]any{"general.architecture": "gemma3", "gemma3.context_length": json.Number("131072")}, 131072},
+		{"no architecture", map[string]any{"llama.context_length": float64(8192), "qwen2.context_length": float64(32768)}, 8192},
+		{"architecture without its key", map[string]any{"general.architecture": "mllama", "llama.context_length": int64(4096)}, 4096},
+		{"not a number", map[string]any{"general.architecture": "llama", "llama.context_length": "8192"}, 0},
+		{"missing", map[string]any{"general.architecture": "llama"}, 0},
+		{"nil", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := contextLength(tt.modelInfo); got != tt.want {
+			t.Errorf("%s: contextLength() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParameterInt(t *testing.T) {
+	parameters := "stop                           \"<|im_start|>\"\nnum_ctx                        8192\ntemperature                    0.7\nnum_predict                    many"
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"num_ctx", 8192},
+		{"temperature", 0},
+		{"num_predict", 0},
+		{"num_gpu", 0},
+	}
+	for _, tt := range tests {
+		if got := parameterInt(parameters, tt.name); got != tt.want {
+			t.Errorf("parameterInt(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
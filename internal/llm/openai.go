@@ -3,6 +3,8 @@ package llm
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -13,14 +15,18 @@ type OpenAIProvider struct {
 	client openai.Client
 }
 
-// NewOpenAIProvider creates a new OpenAI provider
-func NewOpenAIProvider(apiKey string) (*OpenAIProvider, error) {
+// NewOpenAIProvider creates a new OpenAI provider. Connecting to the API fails
+// after connectTimeout (0 for no limit).
+func NewOpenAIProvider(apiKey string, connectTimeout time.Duration) (*OpenAIProvider, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("OpenAI API key is required")
 	}
 
 	client := openai.NewClient(
 		option.WithAPIKey(apiKey),
+		option.WithHTTPClient(HTTPClientFromEnv(connectTimeout)),
+		// Retries are handled by RetryProvider so they can be classified and logged
+		option.WithMaxRetries(0),
 	)
 
 	return &OpenAIProvider{
@@ -28,8 +34,8 @@ func NewOpenAIProvider(apiKey string) (*OpenAIProvider, error) {
 	}, nil
 }
 
-// Query sends a non-streaming request to OpenAI
-func (p *OpenAIProvider) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+// chatParams builds the chat completion parameters for a request
+func chatParams(req QueryRequest) openai.ChatCompletionNewParams {
 	chatReq := openai.ChatCompletionNewParams{
 		Messages: []openai.ChatCompletionMessageParamUnion{
 			openai.SystemMessage(req.SystemPrompt),
@@ -40,9 +46,29 @@ func (p *OpenAIProvider) Query(ctx context.Context, req QueryRequest) (*QueryRes
 		Temperature: openai.Float(req.Temperature),
 	}
 
+	// Use structured outputs (strict JSON schema) when requested
+	if req.ResponseSchema != nil {
+		chatReq.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &openai.ResponseFormatJSONSchemaParam{
+				JSONSchema: openai.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   req.ResponseSchema.Name,
+					Schema: req.ResponseSchema.Schema,
+					Strict: openai.Bool(true),
+				},
+			},
+		}
+	}
+
+	return chatReq
+}
+
+// Query sends a non-streaming request to OpenAI
+func (p *OpenAIProvider) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+	chatReq := chatParams(req)
+
 	resp, err := p.client.Chat.Completions.New(ctx, chatReq)
 	if err != nil {
-		return nil, fmt.Errorf("OpenAI API error: %w", err)
+		return nil, classifyOpenAIError(err)
 	}
 
 	if len(resp.Choices) == 0 {
@@ -59,7 +85,8 @@ func (p *OpenAIProvider) Query(ctx context.Context, req QueryRequest) (*QueryRes
 	}, nil
 }
 
-// StreamQuery sends a streaming request to OpenAI
+// StreamQuery sends a streaming request to OpenAI. The stream stops when ctx
+// is cancelled, so callers that stop reading must cancel it.
 func (p *OpenAIProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-chan StreamChunk, <-chan error) {
 	chunkCh := make(chan StreamChunk)
 	errCh := make(chan error, 1)
@@ -68,21 +95,15 @@ func (p *OpenAIProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-c
 		defer close(chunkCh)
 		defer close(errCh)
 
-		chatReq := openai.ChatCompletionNewParams{
-			Messages: []openai.ChatCompletionMessageParamUnion{
-				openai.SystemMessage(req.SystemPrompt),
-				openai.UserMessage(req.UserPrompt),
-			},
-			Model:       openai.ChatModel(req.Model),
-			MaxTokens:   openai.Int(int64(req.MaxTokens)),
-			Temperature: openai.Float(req.Temperature),
-		}
+		chatReq := chatParams(req)
 
 		stream := p.client.Chat.Completions.NewStreaming(ctx, chatReq)
 
 		// Use accumulator to track streaming state
 		acc := openai.ChatCompletionAccumulator{}
 
+		defer stream.Close()
+
 		for stream.Next() {
 			chunk := stream.Current()
 			acc.AddChunk(chunk)
@@ -91,16 +112,15 @@ func (p *OpenAIProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-c
 			if len(chunk.Choices) > 0 {
 				delta := chunk.Choices[0].Delta
 				if delta.Content != "" {
-					chunkCh <- StreamChunk{
-						Content:    delta.Content,
-						IsComplete: false,
+					if !sendChunk(ctx, chunkCh, StreamChunk{Content: delta.Content}) {
+						return
 					}
 				}
 			}
 		}
 
 		if err := stream.Err(); err != nil {
-			errCh <- fmt.Errorf("stream error: %w", err)
+			errCh <- fmt.Errorf("stream error: %w", classifyOpenAIError(err))
 			return
 		}
 
@@ -108,12 +128,12 @@ func (p *OpenAIProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-c
 		inputTokens := int(acc.Usage.PromptTokens)
 		outputTokens := int(acc.Usage.CompletionTokens)
 
-		chunkCh <- StreamChunk{
+		sendChunk(ctx, chunkCh, StreamChunk{
 			Content:      "",
 			IsComplete:   true,
 			TokensInput:  inputTokens,
 			TokensOutput: outputTokens,
-		}
+		})
 	}()
 
 	return chunkCh, errCh
@@ -165,3 +185,14 @@ func (p *OpenAIProvider) Name() string {
 func (p *OpenAIProvider) SupportsStreaming() bool {
 	return true
 }
+
+// SupportsStructuredOutput reports whether the model supports strict JSON schema
+// structured outputs (gpt-4o and later; not gpt-4-turbo, gpt-4 or gpt-3.5)
+func (p *OpenAIProvider) SupportsStructuredOutput(model string) bool {
+	for _, prefix := range []string{"gpt-3.5", "gpt-4-", "gpt-4o-2024-05-13"} {
+		if strings.HasPrefix(model, prefix) {
+			return false
+		}
+	}
+	return model != "gpt-4"
+}
@@ -19,6 +19,9 @@ type Provider interface {
 
 	// SupportsStreaming indicates if this provider supports streaming
 	SupportsStreaming() bool
+
+	// SupportsStructuredOutput indicates if the model can be constrained to a JSON schema
+	SupportsStructuredOutput(model string) bool
 }
 
 // Model represents an available LLM model
@@ -44,6 +47,13 @@ type QueryRequest struct {
 	Temperature    float64
 	ContextWindow  int      // Max context window in tokens
 	StopSequences  []string
+	ResponseSchema *Schema  // Constrain the response to JSON matching this schema (nil for free text)
+}
+
+// Schema is a JSON schema for structured output
+type Schema struct {
+	Name   string         // Identifier for the schema (a-z, A-Z, 0-9, _ and -)
+	Schema map[string]any // JSON schema object
 }
 
 // QueryResponse represents a response from an LLM
@@ -53,7 +63,8 @@ type QueryResponse struct {
 	TokensOutput int
 	Model        string
 	Provider     string
-	Cached       bool // Whether this was served from cache
+	Profile      string // Profile that produced the response (set by the caller)
+	Cached       bool   // Whether this was served from cache
 }
 
 // StreamChunk represents a chunk of streaming response
@@ -63,3 +74,15 @@ type StreamChunk struct {
 	TokensInput  int    // Only populated on completion
 	TokensOutput int    // Only populated on completion
 }
+
+// sendChunk sends a chunk to a stream's consumer, giving up if ctx is
+// cancelled first so the streaming goroutine can exit. It reports whether the
+// chunk was sent.
+func sendChunk(ctx context.Context, ch chan<- StreamChunk, chunk StreamChunk) bool {
+	select {
+	case ch <- chunk:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Environment variables that switch provider HTTP traffic into record or replay mode
+const (
+	RecordDirEnv = "HEYMAN_RECORD"
+	ReplayDirEnv = "HEYMAN_REPLAY"
+)
+
+// Fixture is a recorded HTTP exchange with a provider API.
+// Request headers are deliberately not stored so API keys never reach disk.
+type Fixture struct {
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	RequestBody    string      `json:"request_body"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header"`
+	ResponseBody   string      `json:"response_body"`
+}
+
+// HTTPClientFromEnv returns the HTTP client providers should use: a recording
+// client if HEYMAN_RECORD is set, a replaying client if HEYMAN_REPLAY is set,
+// otherwise a client giving up on connections that take longer than
+// connectTimeout (0 for no limit)
+func HTTPClientFromEnv(connectTimeout time.Duration) *http.Client {
+	if dir := os.Getenv(ReplayDirEnv); dir != "" {
+		return &http.Client{Transport: NewReplayTransport(dir)}
+	}
+
+	transport := http.DefaultTransport
+	if connectTimeout > 0 {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		t.TLSHandshakeTimeout = connectTimeout
+		transport = t
+	}
+
+	if dir := os.Getenv(RecordDirEnv); dir != "" {
+		transport = NewRecordingTransport(dir, transport)
+	}
+	return &http.Client{Transport: transport}
+}
+
+// IsReplaying reports whether provider traffic is being served from fixtures
+func IsReplaying() bool {
+	return os.Getenv(ReplayDirEnv) != ""
+}
+
+// RecordingTransport passes requests through to the next transport and saves
+// each exchange as a fixture. Responses are buffered in full before being
+// returned, so streamed responses arrive all at once while recording.
+type RecordingTransport struct {
+	dir  string
+	next http.RoundTripper
+}
+
+// NewRecordingTransport creates a transport that records exchanges into dir
+func NewRecordingTransport(dir string, next http.RoundTripper) *RecordingTransport {
+	return &RecordingTransport{dir: dir, next: next}
+}
+
+// RoundTrip performs the request and records the exchange
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response for recording: %w", err)
+	}
+
+	fixture := Fixture{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestBody:    string(reqBody),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: resp.Header,
+		ResponseBody:   string(respBody),
+	}
+	if err := saveFixture(t.dir, fixtureKey(req, reqBody), &fixture); err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+// ReplayTransport serves responses from fixtures recorded by RecordingTransport
+// and never touches the network
+type ReplayTransport struct {
+	dir string
+}
+
+// NewReplayTransport creates a transport that replays fixtures from dir
+func NewReplayTransport(dir string) *ReplayTransport {
+	return &ReplayTransport{dir: dir}
+}
+
+// RoundTrip returns the recorded response for the request
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fixtureKey(req, reqBody)
+	data, err := os.ReadFile(filepath.Join(t.dir, key+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s %s (fixture %s) in %s", req.Method, req.URL.Path, key, t.dir)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", key, err)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.StatusCode, http.StatusText(fixture.StatusCode)),
+		StatusCode:    fixture.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        fixture.ResponseHeader.Clone(),
+		Body:          io.NopCloser(bytes.NewReader([]byte(fixture.ResponseBody))),
+		ContentLength: int64(len(fixture.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// readRequestBody reads the request body and restores it for the next reader
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// fixtureKey identifies an exchange by method, path and body. The host is
+// ignored so fixtures replay regardless of OLLAMA_HOST or base URL.
+func fixtureKey(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", req.Method, req.URL.Path)
+	hash.Write(body)
+	return fmt.Sprintf("%x", hash.Sum(nil))[:32]
+}
+
+// saveFixture writes a fixture to disk
+func saveFixture(dir, key string, fixture *Fixture) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, key+".json"), data, 0600); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Header.Get("Authorization") == "" {
+			t.Error("request reached the server without an API key")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, openAICompletion)
+	}))
+
+	newProvider := func(transport http.RoundTripper) *OpenAIProvider {
+		return &OpenAIProvider{
+			client: openai.NewClient(
+				option.WithAPIKey("sk-secret"),
+				option.WithBaseURL(srv.URL),
+				option.WithMaxRetries(0),
+				option.WithHTTPClient(&http.Client{Transport: transport}),
+			),
+		}
+	}
+	req := QueryRequest{Model: "gpt-4o-mini", SystemPrompt: "system", UserPrompt: "user", MaxTokens: 10}
+
+	recorded, err := newProvider(NewRecordingTransport(dir, http.DefaultTransport)).Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("recording Query() error = %v", err)
+	}
+	srv.Close() // Replay must not need the server
+
+	fixtures, _ := os.ReadDir(dir)
+	if len(fixtures) != 1 {
+		t.Fatalf("recorded %d fixtures, want 1", len(fixtures))
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, fixtures[0].Name()))
+	if strings.Contains(string(data), "sk-secret") {
+		t.Error("fixture contains the API key")
+	}
+
+	replayed, err := newProvider(NewReplayTransport(dir)).Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("replaying Query() error = %v", err)
+	}
+	if *replayed != *recorded {
+		t.Errorf("replayed = %+v, want %+v", replayed, recorded)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server called %d times, want 1", got)
+	}
+
+	// A request that was never recorded fails instead of reaching the network
+	req.UserPrompt = "something else"
+	if _, err := newProvider(NewReplayTransport(dir)).Query(context.Background(), req); err == nil {
+		t.Error("replaying an unrecorded request succeeded, want error")
+	}
+}
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how RetryProvider retries transient failures
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first (1 disables retries)
+	BaseDelay   time.Duration // Delay before the first retry, doubled on each attempt
+	MaxDelay    time.Duration // Upper bound on any single delay, including Retry-After
+}
+
+// DefaultRetryPolicy returns the retry policy used for interactive queries
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// RetryProvider wraps a Provider and retries transient errors (rate limits,
+// unavailable servers) with jittered exponential backoff.
+// A Retry-After longer than MaxDelay is not waited out; the error is returned
+// so the caller can fall back to another profile instead.
+type RetryProvider struct {
+	Provider
+	policy RetryPolicy
+
+	// OnRetry, if set, is called before each retry with the attempt number
+	// (starting at 1), the error that triggered it and the delay
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// NewRetryProvider wraps a provider with the given retry policy
+func NewRetryProvider(provider Provider, policy RetryPolicy) *RetryProvider {
+	return &RetryProvider{
+		Provider: provider,
+		policy:   policy,
+	}
+}
+
+// Unwrap returns the wrapped provider
+func (p *RetryProvider) Unwrap() Provider {
+	return p.Provider
+}
+
+// Query sends a non-streaming request, retrying transient failures
+func (p *RetryProvider) Query(ctx context.Context, req QueryRequest) (*QueryResponse, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := p.Provider.Query(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+
+		if !p.wait(ctx, attempt, err) {
+			return nil, err
+		}
+	}
+}
+
+// StreamQuery sends a streaming request. Failures are retried only until the
+// first chunk has been delivered; after that the error is passed through.
+func (p *RetryProvider) StreamQuery(ctx context.Context, req QueryRequest) (<-chan StreamChunk, <-chan error) {
+	chunkCh := make(chan StreamChunk)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(chunkCh)
+		defer close(errCh)
+
+		for attempt := 1; ; attempt++ {
+			innerChunks, innerErrs := p.Provider.StreamQuery(ctx, req)
+			delivered := false
+
+			err := forwardStream(ctx, innerChunks, innerErrs, chunkCh, &delivered)
+			if err == nil {
+				return
+			}
+
+			if delivered || !p.wait(ctx, attempt, err) {
+				errCh <- err
+				return
+			}
+		}
+	}()
+
+	return chunkCh, errCh
+}
+
+// forwardStream copies chunks from an inner stream until it completes or fails
+func forwardStream(ctx context.Context, in <-chan StreamChunk, inErr <-chan error, out chan<- StreamChunk, delivered *bool) error {
+	for {
+		select {
+		case chunk, ok := <-in:
+			if !ok {
+				// Chunks are done; pick up any error sent alongside
+				if inErr != nil {
+					if err, ok := <-inErr; ok && err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			select {
+			case out <- chunk:
+				*delivered = true
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		case err, ok := <-inErr:
+			if ok && err != nil {
+				return err
+			}
+			inErr = nil // Closed without error; keep draining chunks
+		}
+	}
+}
+
+// wait decides whether to retry after a failed attempt and sleeps for the
+// backoff delay. Returns false if the error should be returned instead.
+func (p *RetryProvider) wait(ctx context.Context, attempt int, err error) bool {
+	if attempt >= p.policy.MaxAttempts || !IsTransient(err) {
+		return false
+	}
+
+	delay := p.backoff(attempt)
+	if retryAfter, ok := RetryAfter(err); ok {
+		if p.policy.MaxDelay > 0 && retryAfter > p.policy.MaxDelay {
+			return false
+		}
+		delay = retryAfter
+	}
+
+	if p.OnRetry != nil {
+		p.OnRetry(attempt, err, delay)
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// backoff returns a "full jitter" exponential delay for the given attempt:
+// a random duration between 0 and min(MaxDelay, BaseDelay * 2^(attempt-1))
+func (p *RetryProvider) backoff(attempt int) time.Duration {
+	ceiling := p.policy.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || (p.policy.MaxDelay > 0 && ceiling > p.policy.MaxDelay) {
+		ceiling = p.policy.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
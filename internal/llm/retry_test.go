@@ -0,0 +1,268 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+const openAICompletion = `{
+  "id": "chatcmpl-test",
+  "object": "chat.completion",
+  "created": 1700000000,
+  "model": "gpt-4o-mini",
+  "choices": [{"index": 0, "message": {"role": "assistant", "content": "ls -lhS"}, "finish_reason": "stop"}],
+  "usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}
+}`
+
+// fastRetryPolicy keeps test retries quick
+var fastRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   time.Millisecond,
+	MaxDelay:    50 * time.Millisecond,
+}
+
+func newTestOpenAIProvider(t *testing.T, handler http.HandlerFunc) *OpenAIProvider {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return &OpenAIProvider{
+		client: openai.NewClient(
+			option.WithAPIKey("sk-test"),
+			option.WithBaseURL(srv.URL),
+			option.WithMaxRetries(0),
+		),
+	}
+}
+
+func newTestOllamaProvider(t *testing.T, handler http.HandlerFunc) *OllamaProvider {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	base, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &OllamaProvider{client: api.NewClient(base, srv.Client())}
+}
+
+func openAIError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"error": {"message": "test error", "type": "test", "param": null, "code": %q}}`, code)
+}
+
+func TestOpenAIErrorClassification(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		code   string
+		want   error
+	}{
+		{"auth", http.StatusUnauthorized, "invalid_api_key", ErrAuth},
+		{"rate limit", http.StatusTooManyRequests, "rate_limit_exceeded", ErrRateLimited},
+		{"context length", http.StatusBadRequest, "context_length_exceeded", ErrContextLength},
+		{"model not found", http.StatusNotFound, "model_not_found", ErrModelNotFound},
+		{"unavailable", http.StatusServiceUnavailable, "", ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				openAIError(w, tt.status, tt.code)
+			})
+
+			_, err := provider.Query(context.Background(), QueryRequest{Model: "gpt-4o-mini", MaxTokens: 10})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Query() error = %v, want %v", err, tt.want)
+			}
+
+			var providerErr *Error
+			if !errors.As(err, &providerErr) || providerErr.StatusCode != tt.status {
+				t.Errorf("Query() error = %#v, want *Error with status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestOllamaErrorClassification(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"model not found", http.StatusNotFound, `{"error": "model \"nope\" not found, try pulling it first"}`, ErrModelNotFound},
+		{"unavailable", http.StatusInternalServerError, `{"error": "llama runner process has terminated"}`, ErrUnavailable},
+		{"rate limit", http.StatusTooManyRequests, `{"error": "server busy"}`, ErrRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestOllamaProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprintln(w, tt.body)
+			})
+
+			_, err := provider.Query(context.Background(), QueryRequest{Model: "nope"})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Query() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOllamaConnectionRefusedIsUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	base, _ := url.Parse(srv.URL)
+	srv.Close() // Nothing is listening any more
+
+	provider := &OllamaProvider{client: api.NewClient(base, http.DefaultClient)}
+	_, err := provider.Query(context.Background(), QueryRequest{Model: "llama3.2"})
+	if !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Query() error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestRetryProviderRecoversFromRateLimit(t *testing.T) {
+	var calls atomic.Int32
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After-Ms", "1")
+			openAIError(w, http.StatusTooManyRequests, "rate_limit_exceeded")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, openAICompletion)
+	})
+
+	var delays []time.Duration
+	retry := NewRetryProvider(provider, fastRetryPolicy)
+	retry.OnRetry = func(attempt int, err error, delay time.Duration) {
+		delays = append(delays, delay)
+	}
+
+	resp, err := retry.Query(context.Background(), QueryRequest{Model: "gpt-4o-mini", MaxTokens: 10})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if resp.Content != "ls -lhS" {
+		t.Errorf("Content = %q, want %q", resp.Content, "ls -lhS")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("server called %d times, want 3", got)
+	}
+	for _, delay := range delays {
+		if delay != time.Millisecond {
+			t.Errorf("retry delay = %s, want Retry-After of 1ms", delay)
+		}
+	}
+}
+
+func TestRetryProviderGivesUpOnLongRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "120")
+		openAIError(w, http.StatusTooManyRequests, "rate_limit_exceeded")
+	})
+
+	_, err := NewRetryProvider(provider, fastRetryPolicy).Query(context.Background(), QueryRequest{Model: "gpt-4o-mini"})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Query() error = %v, want ErrRateLimited", err)
+	}
+	if retryAfter, ok := RetryAfter(err); !ok || retryAfter != 120*time.Second {
+		t.Errorf("RetryAfter() = %s, %v; want 120s", retryAfter, ok)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server called %d times, want 1 (Retry-After exceeds MaxDelay)", got)
+	}
+}
+
+func TestRetryProviderDoesNotRetryAuthErrors(t *testing.T) {
+	var calls atomic.Int32
+	provider := newTestOpenAIProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		openAIError(w, http.StatusUnauthorized, "invalid_api_key")
+	})
+
+	_, err := NewRetryProvider(provider, fastRetryPolicy).Query(context.Background(), QueryRequest{Model: "gpt-4o-mini"})
+	if !errors.Is(err, ErrAuth) {
+		t.Fatalf("Query() error = %v, want ErrAuth", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server called %d times, want 1", got)
+	}
+}
+
+func TestRetryProviderStreamRetriesBeforeFirstChunk(t *testing.T) {
+	var calls atomic.Int32
+	provider := newTestOllamaProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, `{"error": "loading model"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"model": "llama3.2", "message": {"role": "assistant", "content": "ls "}, "done": false}`)
+		fmt.Fprintln(w, `{"model": "llama3.2", "message": {"role": "assistant", "content": "-lhS"}, "done": false}`)
+		fmt.Fprintln(w, `{"model": "llama3.2", "message": {"role": "assistant", "content": ""}, "done": true, "prompt_eval_count": 12, "eval_count": 3}`)
+	})
+
+	chunkCh, errCh := NewRetryProvider(provider, fastRetryPolicy).StreamQuery(context.Background(), QueryRequest{Model: "llama3.2"})
+
+	var content string
+	var complete StreamChunk
+	for chunk := range chunkCh {
+		if chunk.IsComplete {
+			complete = chunk
+			continue
+		}
+		content += chunk.Content
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("StreamQuery() error = %v", err)
+	}
+
+	if content != "ls -lhS" {
+		t.Errorf("content = %q, want %q", content, "ls -lhS")
+	}
+	if complete.TokensInput != 12 || complete.TokensOutput != 3 {
+		t.Errorf("tokens = %d/%d, want 12/3", complete.TokensInput, complete.TokensOutput)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("server called %d times, want 2", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"none", http.Header{}, 0},
+		{"seconds", http.Header{"Retry-After": {"2"}}, 2 * time.Second},
+		{"milliseconds", http.Header{"Retry-After-Ms": {"250"}}, 250 * time.Millisecond},
+		{"invalid", http.Header{"Retry-After": {"soon"}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header); got != tt.want {
+				t.Errorf("parseRetryAfter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
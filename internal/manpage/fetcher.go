@@ -6,23 +6,109 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 )
 
-// Fetcher handles man page retrieval
+// maxPages bounds how many pages a Fetcher remembers. Past it the least
+// recently used page is forgotten, so a long-running server asked about many
+// commands doesn't keep every page it has ever fetched.
+const maxPages = 64
+
+// Fetcher handles man page retrieval. Pages are fetched once per fetcher and
+// remembered, so share a fetcher to share pages between questions. Failed
+// fetches are not remembered: the next request runs man again.
 type Fetcher struct {
-	// Could add caching here in the future if needed
+	mu    sync.Mutex
+	pages map[string]*fetchedPage // By section and command
+	order []string                // Keys of pages, least recently used first
+
+	// load fetches a page; tests replace it to avoid depending on man
+	load func(command, section string) (string, error)
+}
+
+// fetchedPage is a man page fetched (or being fetched) by a Fetcher
+type fetchedPage struct {
+	once    sync.Once
+	content string
+	err     error
 }
 
 // NewFetcher creates a new man page fetcher
 func NewFetcher() *Fetcher {
-	return &Fetcher{}
+	f := &Fetcher{pages: make(map[string]*fetchedPage)}
+	f.load = f.fetch
+	return f
+}
+
+// validCommand matches a plain command name: no options and no paths
+var validCommand = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.+@:-]*$`)
+
+// validSection matches a man page section such as "1", "3p" or "8ssl"
+var validSection = regexp.MustCompile(`^[0-9][a-z0-9]*$`)
+
+// Validate checks a command and section that come from outside heyman (an
+// HTTP request, an MCP client) before they are passed to man. A value
+// starting with "-" would be read as one of man's options, some of which run
+// other programs.
+func Validate(command, section string) error {
+	if !validCommand.MatchString(command) {
+		return fmt.Errorf("invalid command %q: expected a plain command name", command)
+	}
+	if section != "" && !validSection.MatchString(section) {
+		return fmt.Errorf("invalid man page section %q", section)
+	}
+	return nil
 }
 
 // Fetch retrieves the man page for the given command
 // Supports both "man 3 printf" and "man -s 3 printf" syntax
 // Uses MANPAGER=cat and col -b to get clean text output
 func (f *Fetcher) Fetch(command string, section string) (string, error) {
+	key := section + "/" + command
+
+	f.mu.Lock()
+	page, ok := f.pages[key]
+	if ok {
+		f.forget(key)
+	} else {
+		if len(f.order) >= maxPages {
+			delete(f.pages, f.order[0])
+			f.order = f.order[1:]
+		}
+		page = &fetchedPage{}
+		f.pages[key] = page
+	}
+	f.order = append(f.order, key)
+	f.mu.Unlock()
+
+	// Concurrent callers wait for the first fetch rather than running man again
+	page.once.Do(func() {
+		page.content, page.err = f.load(command, section)
+	})
+
+	if page.err != nil {
+		// The page may be installed, or man may recover, by the next request
+		f.mu.Lock()
+		if f.pages[key] == page {
+			delete(f.pages, key)
+			f.forget(key)
+		}
+		f.mu.Unlock()
+	}
+	return page.content, page.err
+}
+
+// forget removes key from the use order; the caller holds f.mu
+func (f *Fetcher) forget(key string) {
+	if i := slices.Index(f.order, key); i >= 0 {
+		f.order = slices.Delete(f.order, i, i+1)
+	}
+}
+
+// fetch runs man for a page, trying both section syntaxes
+func (f *Fetcher) fetch(command string, section string) (string, error) {
 	if section != "" {
 		// Try both section syntaxes for cross-platform compatibility
 		// First try: man <section> <command>
@@ -48,6 +134,27 @@ func (f *Fetcher) Fetch(command string, section string) (string, error) {
 	return cleanManPage(output), nil
 }
 
+// Locate returns the path of the file man would show for the command, for
+// reporting where a page comes from
+func (f *Fetcher) Locate(command string, section string) (string, error) {
+	argSets := [][]string{{command}}
+	if section != "" {
+		argSets = [][]string{{section, command}, {"-s", section, command}}
+	}
+
+	for _, args := range argSets {
+		output, err := exec.Command("man", append([]string{"-w"}, args...)...).Output()
+		if err != nil {
+			continue
+		}
+		// man -w lists every match; the first is the one it shows
+		if path, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n"); path != "" {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("man page for %q not found", command)
+}
+
 // fetchManPage executes man command with given args and pipes through col -b
 // This avoids shell injection by using exec.Command with separate arguments
 func (f *Fetcher) fetchManPage(args []string) (string, error) {
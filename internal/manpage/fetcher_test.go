@@ -0,0 +1,107 @@
+package manpage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		command, section string
+		ok               bool
+	}{
+		{"tar", "", true},
+		{"git-commit", "1", true},
+		{"g++", "", true},
+		{"python3.12", "", true},
+		{"printf", "3p", true},
+		{"openssl", "8ssl", true},
+		{"-k", "", false},
+		{"--pager=sh", "", false},
+		{"/bin/ls", "", false},
+		{"../ls", "", false},
+		{"ls; rm", "", false},
+		{"", "", false},
+		{"ls", "--pager=sh", false},
+		{"ls", "-s", false},
+		{"ls", "a", false},
+		{"ls", "1 2", false},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.command, tt.section)
+		if (err == nil) != tt.ok {
+			t.Errorf("Validate(%q, %q) error = %v, want ok = %v", tt.command, tt.section, err, tt.ok)
+		}
+	}
+}
+
+// countingLoad replaces f's man lookup with one that counts runs per page
+// and fails for commands in failing
+func countingLoad(f *Fetcher, failing map[string]bool) map[string]int {
+	runs := make(map[string]int)
+	f.load = func(command, section string) (string, error) {
+		runs[command]++
+		if failing[command] {
+			return "", fmt.Errorf("man page for %q not found", command)
+		}
+		return "page for " + command, nil
+	}
+	return runs
+}
+
+func TestFetchRemembersPages(t *testing.T) {
+	f := NewFetcher()
+	runs := countingLoad(f, nil)
+
+	for range 3 {
+		content, err := f.Fetch("tar", "")
+		if err != nil || content != "page for tar" {
+			t.Fatalf("Fetch(tar) = %q, %v", content, err)
+		}
+	}
+	if runs["tar"] != 1 {
+		t.Errorf("man ran %d times for tar, want 1", runs["tar"])
+	}
+}
+
+func TestFetchRetriesFailures(t *testing.T) {
+	f := NewFetcher()
+	failing := map[string]bool{"tar": true}
+	runs := countingLoad(f, failing)
+
+	if _, err := f.Fetch("tar", ""); err == nil {
+		t.Fatal("Fetch(tar) succeeded, want an error")
+	}
+	delete(failing, "tar")
+	content, err := f.Fetch("tar", "")
+	if err != nil || content != "page for tar" {
+		t.Fatalf("Fetch(tar) after a failure = %q, %v, want the page", content, err)
+	}
+	if runs["tar"] != 2 {
+		t.Errorf("man ran %d times for tar, want 2", runs["tar"])
+	}
+}
+
+func TestFetchForgetsLeastRecentlyUsed(t *testing.T) {
+	f := NewFetcher()
+	runs := countingLoad(f, nil)
+
+	for i := range maxPages {
+		f.Fetch(fmt.Sprintf("cmd%d", i), "")
+	}
+	// Using cmd0 again keeps it; cmd1 becomes the least recently used
+	f.Fetch("cmd0", "")
+	f.Fetch("extra", "")
+
+	if len(f.pages) != maxPages {
+		t.Errorf("fetcher holds %d pages, want %d", len(f.pages), maxPages)
+	}
+	f.Fetch("cmd0", "")
+	f.Fetch("cmd1", "")
+	if runs["cmd0"] != 1 {
+		t.Errorf("man ran %d times for cmd0, want 1 (recently used)", runs["cmd0"])
+	}
+	if runs["cmd1"] != 2 {
+		t.Errorf("man ran %d times for cmd1, want 2 (forgotten)", runs["cmd1"])
+	}
+}
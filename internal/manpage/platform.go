@@ -0,0 +1,229 @@
+package manpage
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Implementation variants of common command-line tools
+const (
+	VariantGNU     = "gnu"
+	VariantBSD     = "bsd"
+	VariantBusyBox = "busybox"
+)
+
+// versionProbeCommands are the tools with GNU, BSD and BusyBox
+// implementations whose options differ, and which every implementation either
+// answers "--version" or rejects it without doing anything. No other command
+// is ever run to detect its variant.
+var versionProbeCommands = map[string]bool{
+	"awk": true, "base64": true, "cat": true, "chmod": true, "chown": true,
+	"cp": true, "cut": true, "date": true, "dd": true, "df": true, "du": true,
+	"egrep": true, "fgrep": true, "find": true, "grep": true, "head": true,
+	"ln": true, "ls": true, "mktemp": true, "mv": true, "od": true,
+	"paste": true, "readlink": true, "realpath": true, "rm": true, "sed": true,
+	"seq": true, "sort": true, "split": true, "stat": true, "tail": true,
+	"tar": true, "tee": true, "touch": true, "tr": true, "uniq": true,
+	"wc": true, "xargs": true,
+}
+
+// VariantStore remembers what "--version" probes found, by executable path,
+// so a binary is probed once rather than on every query
+type VariantStore interface {
+	GetVariant(path string) (variant string, ok bool)
+	SetVariant(path, variant string) error
+}
+
+// versionProbeTimeout bounds how long "<command> --version" may run, and
+// versionOutputLimit how much of its output is read
+const (
+	versionProbeTimeout = 2 * time.Second
+	versionOutputLimit  = 4096
+)
+
+// Platform describes the system a command runs on and which implementation
+// of the command is installed
+type Platform struct {
+	OS      string // runtime.GOOS
+	Variant string // VariantGNU, VariantBSD, VariantBusyBox or "" if unknown
+	Source  string // How the variant was detected: "path", "man page" or "--version"
+}
+
+// Key identifies the platform in cache keys, e.g. "darwin/bsd"
+func (p Platform) Key() string {
+	variant := p.Variant
+	if variant == "" {
+		variant = "unknown"
+	}
+	return p.OS + "/" + variant
+}
+
+// Describe names the platform and variant for a command, e.g. "the BSD
+// version of sed on macOS"
+func (p Platform) Describe(command string) string {
+	osName := osNames[p.OS]
+	if osName == "" {
+		osName = p.OS
+	}
+
+	switch p.Variant {
+	case VariantGNU:
+		return "the GNU version of " + command + " on " + osName
+	case VariantBSD:
+		return "the BSD version of " + command + " on " + osName
+	case VariantBusyBox:
+		return "the BusyBox version of " + command + " on " + osName
+	}
+	return command + " on " + osName
+}
+
+var osNames = map[string]string{
+	"darwin":  "macOS",
+	"linux":   "Linux",
+	"freebsd": "FreeBSD",
+	"openbsd": "OpenBSD",
+	"netbsd":  "NetBSD",
+	"windows": "Windows",
+}
+
+// DetectPlatform works out which implementation of a command is installed:
+// first from where its binary lives, then from the man page header and footer,
+// and finally, if probeVersion is set and the command is one of
+// versionProbeCommands, by running "<command> --version". Results of probes
+// are remembered in variants, if it is non-nil. Only probe commands the user
+// typed; never ones from a request or a file.
+func (f *Fetcher) DetectPlatform(command, manPage string, probeVersion bool, variants VariantStore) Platform {
+	platform := Platform{OS: runtime.GOOS}
+
+	if variant := variantFromPath(command); variant != "" {
+		platform.Variant, platform.Source = variant, "path"
+	} else if variant := variantFromManPage(manPage); variant != "" {
+		platform.Variant, platform.Source = variant, "man page"
+	} else if !probeVersion {
+		return platform
+	} else if variant := variantFromVersion(command, variants); variant != "" {
+		platform.Variant, platform.Source = variant, "--version"
+	}
+
+	return platform
+}
+
+// variantFromPath detects BusyBox applets (symlinks to the busybox binary) and
+// GNU tools installed under a gnubin directory, as Homebrew does on macOS
+func variantFromPath(command string) string {
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	switch {
+	case filepath.Base(path) == "busybox":
+		return VariantBusyBox
+	case strings.Contains(path, "/gnubin/"):
+		return VariantGNU
+	}
+	return ""
+}
+
+// variantFromManPage looks for the project or OS named in the first and last
+// lines of a man page, e.g. "GNU coreutils 9.4" or "macOS 14.5"
+func variantFromManPage(manPage string) string {
+	lines := strings.Split(strings.TrimSpace(manPage), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return ""
+	}
+	edges := lines[0] + "\n" + lines[len(lines)-1]
+
+	switch {
+	case strings.Contains(edges, "BusyBox"):
+		return VariantBusyBox
+	case strings.Contains(edges, "GNU"):
+		return VariantGNU
+	case strings.Contains(edges, "BSD"), strings.Contains(edges, "macOS"), strings.Contains(edges, "Mac OS X"):
+		return VariantBSD
+	}
+	return ""
+}
+
+// variantFromVersion runs "<command> --version" for one of
+// versionProbeCommands resolved to an executable on PATH, reading at most
+// versionOutputLimit bytes of its output. The result is looked up in and
+// recorded to variants, if non-nil.
+func variantFromVersion(command string, variants VariantStore) string {
+	if !versionProbeCommands[command] {
+		return ""
+	}
+	path, err := exec.LookPath(command)
+	if err != nil {
+		return "" // Includes exec.ErrDot: never run a binary from the current directory
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	if variants != nil {
+		if variant, ok := variants.GetVariant(path); ok {
+			return variant
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionProbeTimeout)
+	defer cancel()
+
+	output := &cappedBuffer{limit: versionOutputLimit}
+	cmd := exec.CommandContext(ctx, path, "--version")
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return "" // Not remembered: a slow start may not happen next time
+	}
+
+	variant := variantFromVersionOutput(output.String(), err != nil, runtime.GOOS)
+	if variants != nil {
+		_ = variants.SetVariant(path, variant) // Only costs a probe next time
+	}
+	return variant
+}
+
+// variantFromVersionOutput classifies the output of "<command> --version" on
+// an OS. GNU and BusyBox tools name themselves; BSD tools reject the option.
+// Anything else is inconclusive.
+func variantFromVersionOutput(text string, failed bool, goos string) string {
+	switch {
+	case strings.Contains(text, "BusyBox"):
+		return VariantBusyBox
+	case strings.Contains(text, "GNU") || strings.Contains(text, "Free Software Foundation"):
+		return VariantGNU
+	case failed && (strings.Contains(text, "illegal option") || strings.Contains(text, "unrecognized option") || strings.Contains(text, "invalid option")) && goos != "linux":
+		return VariantBSD
+	}
+	return ""
+}
+
+// cappedBuffer keeps the first limit bytes written to it and discards the
+// rest, so a command can't make heyman read unbounded output
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// Write keeps what fits and reports everything as written, so the command
+// isn't stopped by a broken pipe
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,137 @@
+package manpage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestVariantFromVersionOutput(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		failed bool
+		goos   string
+		want   string
+	}{
+		{"GNU coreutils", "ls (GNU coreutils) 9.4\nCopyright (C) 2023 Free Software Foundation, Inc.\n", false, "linux", VariantGNU},
+		{"GNU on macOS via Homebrew", "sed (GNU sed) 4.9\n", false, "darwin", VariantGNU},
+		{"FSF without GNU in the name", "Copyright (C) 2022 Free Software Foundation, Inc.\n", false, "linux", VariantGNU},
+		{"BusyBox", "BusyBox v1.36.1 (2023-06-16) multi-call binary.\n", true, "linux", VariantBusyBox},
+		{"BSD rejects the option", "ls: illegal option -- -\nusage: ls [-@ABCFGHILOPRSTUWabcdefghiklmnopqrstuvwxy1%,] [--color=when] [file ...]\n", true, "darwin", VariantBSD},
+		{"FreeBSD", "sed: unrecognized option `--version'\n", true, "freebsd", VariantBSD},
+		{"rejected on Linux is inconclusive", "foo: invalid option -- '-'\n", true, "linux", ""},
+		{"rejection that didn't fail is inconclusive", "illegal option\n", false, "darwin", ""},
+		{"no version info", "jq-1.7.1\n", false, "darwin", ""},
+		{"no output", "", true, "darwin", ""},
+	}
+	for _, tt := range tests {
+		if got := variantFromVersionOutput(tt.text, tt.failed, tt.goos); got != tt.want {
+			t.Errorf("%s: variantFromVersionOutput() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// memoryVariants is a VariantStore in memory
+type memoryVariants map[string]string
+
+func (m memoryVariants) GetVariant(path string) (string, bool) {
+	variant, ok := m[path]
+	return variant, ok
+}
+
+func (m memoryVariants) SetVariant(path, variant string) error {
+	m[path] = variant
+	return nil
+}
+
+// writeProbeScript installs a script on a fresh PATH that reports GNU and
+// counts its runs in a file next to it
+func writeProbeScript(t *testing.T, name string) (bin, runs string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	bin = t.TempDir()
+	runs = filepath.Join(bin, "runs")
+	script := "#!/bin/sh\necho run >> '" + runs + "'\necho 'probe (GNU coreutils) 9.4'\n"
+	if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	return bin, runs
+}
+
+func probeRuns(t *testing.T, runs string) int {
+	t.Helper()
+	data, err := os.ReadFile(runs)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "run")
+}
+
+func TestVariantFromVersionProbesOnceAndRemembers(t *testing.T) {
+	bin, runs := writeProbeScript(t, "sed")
+	variants := memoryVariants{}
+
+	for range 2 {
+		if got := variantFromVersion("sed", variants); got != VariantGNU {
+			t.Errorf("variantFromVersion(sed) = %q, want %q", got, VariantGNU)
+		}
+	}
+	if got := probeRuns(t, runs); got != 1 {
+		t.Errorf("sed --version ran %d times, want once and then remembered", got)
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(bin, "sed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if variants[path] != VariantGNU {
+		t.Errorf("recorded variants = %v, want sed's path", variants)
+	}
+}
+
+func TestVariantFromVersionRunsOnlyKnownToolsOnPath(t *testing.T) {
+	bin, runs := writeProbeScript(t, "rsync")
+	if got := variantFromVersion("rsync", nil); got != "" {
+		t.Errorf("variantFromVersion(rsync) = %q, want no probe", got)
+	}
+
+	// Paths, options and binaries found through a relative PATH entry never run
+	sed := filepath.Join(bin, "sed")
+	if err := os.Rename(filepath.Join(bin, "rsync"), sed); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(bin)
+	for _, command := range []string{sed, "./sed", "--version"} {
+		if got := variantFromVersion(command, nil); got != "" {
+			t.Errorf("variantFromVersion(%q) = %q, want no probe", command, got)
+		}
+	}
+	t.Setenv("PATH", ".")
+	if got := variantFromVersion("sed", nil); got != "" {
+		t.Errorf("variantFromVersion(sed) with PATH=. = %q, want no probe", got)
+	}
+
+	if got := probeRuns(t, runs); got != 0 {
+		t.Errorf("probe script ran %d times, want never", got)
+	}
+}
+
+func TestCappedBuffer(t *testing.T) {
+	b := &cappedBuffer{limit: 8}
+	for range 3 {
+		if n, err := b.Write([]byte("abcdef")); n != 6 || err != nil {
+			t.Fatalf("Write() = %d, %v; want 6, nil", n, err)
+		}
+	}
+	if got := b.String(); got != "abcdefab" {
+		t.Errorf("kept %q, want the first 8 bytes", got)
+	}
+}
@@ -0,0 +1,129 @@
+package manpage
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// termRegex matches the words (and flags, like "-r" or "--recursive") of a
+// man page or query
+var termRegex = regexp.MustCompile(`-{0,2}[A-Za-z0-9][A-Za-z0-9_.-]*`)
+
+// stopWords are left out of search queries
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "by": true, "can": true,
+	"do": true, "for": true, "how": true, "i": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "the": true, "to": true,
+	"what": true, "with": true,
+}
+
+// Paragraphs splits a man page into its blank-line separated paragraphs
+func Paragraphs(content string) []Excerpt {
+	lines := strings.Split(content, "\n")
+	sections := Sections(content)
+
+	var paragraphs []Excerpt
+	start := -1
+	for i := 0; i <= len(lines); i++ {
+		blank := i == len(lines) || strings.TrimSpace(lines[i]) == ""
+		switch {
+		case !blank && start < 0:
+			start = i
+		case blank && start >= 0:
+			paragraphs = append(paragraphs, Excerpt{
+				Text:      strings.Join(lines[start:i], "\n"),
+				Section:   SectionAt(sections, start+1),
+				StartLine: start + 1,
+				EndLine:   i,
+			})
+			start = -1
+		}
+	}
+	return paragraphs
+}
+
+// Search returns the paragraphs of a man page most relevant to a query, best
+// first: those containing the most (and the rarest) of its words and flags.
+// At most limit paragraphs are returned, and none that match nothing.
+func Search(content, query string, limit int) []Excerpt {
+	var terms []string
+	for _, term := range searchTerms(query) {
+		if !stopWords[term] && !slices.Contains(terms, term) {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil
+	}
+
+	paragraphs := Paragraphs(content)
+	counts := make([]map[string]int, len(paragraphs))
+	docFreq := make(map[string]int)
+	for i, paragraph := range paragraphs {
+		counts[i] = make(map[string]int)
+		for _, word := range searchTerms(paragraph.Text) {
+			if slices.Contains(terms, word) {
+				if counts[i][word] == 0 {
+					docFreq[word]++
+				}
+				counts[i][word]++
+			}
+		}
+	}
+
+	type scored struct {
+		Excerpt
+		score float64
+	}
+	var matches []scored
+	for i, paragraph := range paragraphs {
+		score := 0.0
+		for term, count := range counts[i] {
+			// Rare terms count for more, repeats for less and less
+			idf := math.Log(1 + float64(len(paragraphs))/float64(docFreq[term]))
+			score += idf * float64(count) / (float64(count) + 1)
+		}
+		if score > 0 {
+			matches = append(matches, scored{paragraph, score})
+		}
+	}
+
+	slices.SortStableFunc(matches, func(a, b scored) int {
+		switch {
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	result := make([]Excerpt, len(matches))
+	for i, match := range matches {
+		result[i] = match.Excerpt
+	}
+	return result
+}
+
+// searchTerms splits text into words and flags. Words are lowercased; flags
+// keep their case, since "-r" and "-R" usually differ.
+func searchTerms(text string) []string {
+	var terms []string
+	for _, term := range termRegex.FindAllString(text, -1) {
+		// "-r," and "files." should match "-r" and "files"
+		term = strings.TrimRight(term, ".-")
+		if term == "" {
+			continue
+		}
+		if !strings.HasPrefix(term, "-") {
+			term = strings.ToLower(term)
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
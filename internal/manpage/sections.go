@@ -0,0 +1,210 @@
+package manpage
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Section is a top-level section of a man page (NAME, SYNOPSIS, OPTIONS...)
+type Section struct {
+	Name      string
+	StartLine int // 1-based line of the section heading
+	EndLine   int // 1-based last line of the section
+}
+
+// Excerpt is a passage of a man page, located by line range
+type Excerpt struct {
+	Text      string // The text as it appears in the page (whitespace collapsed, for quotes)
+	Section   string // Section containing the first line, empty if before any heading
+	StartLine int    // 1-based
+	EndLine   int    // 1-based, inclusive
+}
+
+// Sections splits a cleaned man page into its top-level sections.
+// Headings are unindented lines written entirely in capitals.
+func Sections(content string) []Section {
+	lines := strings.Split(content, "\n")
+	var sections []Section
+
+	for i, line := range lines {
+		if !isSectionHeading(line) {
+			continue
+		}
+		if n := len(sections); n > 0 {
+			sections[n-1].EndLine = i
+		}
+		sections = append(sections, Section{
+			Name:      strings.TrimSpace(line),
+			StartLine: i + 1,
+		})
+	}
+	if n := len(sections); n > 0 {
+		sections[n-1].EndLine = len(lines)
+	}
+
+	return sections
+}
+
+// isSectionHeading reports whether a line looks like a man page section heading
+func isSectionHeading(line string) bool {
+	if line == "" || len(line) > 40 || unicode.IsSpace(rune(line[0])) {
+		return false
+	}
+
+	hasLetter := false
+	for _, r := range strings.TrimSpace(line) {
+		switch {
+		case unicode.IsUpper(r):
+			hasLetter = true
+		case r == ' ' || r == '-' || r == '&' || r == '/':
+		default:
+			return false
+		}
+	}
+	return hasLetter
+}
+
+// Description returns the one-line description from the NAME section of a man
+// page ("ls - list directory contents" gives "list directory contents"), or ""
+// if there is none
+func Description(content string) string {
+	lines := strings.Split(content, "\n")
+	for _, section := range Sections(content) {
+		if section.Name != "NAME" {
+			continue
+		}
+
+		var text []string
+		for _, line := range lines[section.StartLine:section.EndLine] {
+			if strings.TrimSpace(line) == "" && len(text) > 0 {
+				break
+			}
+			text = append(text, line)
+		}
+		name := collapseSpace(strings.Join(text, " "))
+		for _, separator := range []string{" - ", " \\- ", " — ", " -- "} {
+			if _, description, found := strings.Cut(name, separator); found {
+				return strings.TrimSpace(description)
+			}
+		}
+		return name
+	}
+	return ""
+}
+
+// SectionAt returns the name of the section containing a 1-based line
+func SectionAt(sections []Section, line int) string {
+	for _, s := range sections {
+		if line >= s.StartLine && line <= s.EndLine {
+			return s.Name
+		}
+	}
+	return ""
+}
+
+// Locate finds a quote in the man page, ignoring differences in whitespace and
+// line wrapping (and, failing an exact match, case). ok is false if the quote
+// does not appear in the page.
+func Locate(content, quote string) (excerpt Excerpt, ok bool) {
+	needle := collapseSpace(quote)
+	if needle == "" {
+		return Excerpt{}, false
+	}
+
+	haystack, lineOf := normalizeWithLines(content)
+
+	idx, matchEnd := strings.Index(haystack, needle), 0
+	if idx >= 0 {
+		matchEnd = idx + len(needle)
+	} else if loc := regexp.MustCompile("(?i)" + regexp.QuoteMeta(needle)).FindStringIndex(haystack); loc != nil {
+		// Matched in the page itself, so offsets hold even where case
+		// folding changes the length of non-ASCII text
+		idx, matchEnd = loc[0], loc[1]
+	}
+	if idx < 0 {
+		return Excerpt{}, false
+	}
+
+	start := lineOf[idx]
+	end := lineOf[matchEnd-1]
+	return Excerpt{
+		Text:      haystack[idx:matchEnd],
+		Section:   SectionAt(Sections(content), start),
+		StartLine: start,
+		EndLine:   end,
+	}, true
+}
+
+// FindFlag finds the line(s) in the man page that document a flag: an indented
+// line that starts with the flag, plus its description up to the next blank line
+// (at most three lines). ok is false if the flag is not documented.
+func FindFlag(content, flag string) (excerpt Excerpt, ok bool) {
+	if flag == "" {
+		return Excerpt{}, false
+	}
+
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, flag) || !unicode.IsSpace(rune(line[0])) {
+			continue
+		}
+
+		// The flag must end at a word boundary: "-l" must not match "-list"
+		if rest := trimmed[len(flag):]; rest != "" && !strings.ContainsRune(" \t,=[<", rune(rest[0])) {
+			continue
+		}
+
+		end := i
+		for end+1 < len(lines) && end-i < 2 && strings.TrimSpace(lines[end+1]) != "" {
+			end++
+		}
+
+		return Excerpt{
+			Text:      collapseSpace(strings.Join(lines[i:end+1], " ")),
+			Section:   SectionAt(Sections(content), i+1),
+			StartLine: i + 1,
+			EndLine:   end + 1,
+		}, true
+	}
+
+	return Excerpt{}, false
+}
+
+// collapseSpace trims s and replaces every run of whitespace with one space
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// normalizeWithLines collapses whitespace in content like collapseSpace and
+// returns, for every byte of the result, the 1-based line it came from
+func normalizeWithLines(content string) (string, []int) {
+	var b strings.Builder
+	lineOf := make([]int, 0, len(content))
+
+	line := 1
+	pendingSpace := false
+	for _, r := range content {
+		if unicode.IsSpace(r) {
+			if r == '\n' {
+				line++
+			}
+			pendingSpace = b.Len() > 0
+			continue
+		}
+
+		if pendingSpace {
+			b.WriteByte(' ')
+			lineOf = append(lineOf, line)
+			pendingSpace = false
+		}
+
+		n, _ := b.WriteRune(r)
+		for range n {
+			lineOf = append(lineOf, line)
+		}
+	}
+
+	return b.String(), lineOf
+}
@@ -0,0 +1,39 @@
+package manpage
+
+import "testing"
+
+func TestLocate(t *testing.T) {
+	content := "NAME\n       tool - İİİ does things\n\nDESCRIPTION\n       Writes the\n       Output Format described below.\n"
+	tests := []struct {
+		quote     string
+		text      string
+		startLine int
+		endLine   int
+		ok        bool
+	}{
+		{"Writes the Output Format", "Writes the Output Format", 5, 6, true},
+		{"writes   the\noutput format", "Writes the Output Format", 5, 6, true},
+		{"İİİ DOES THINGS", "İİİ does things", 2, 2, true},
+		{"not in the page", "", 0, 0, false},
+		{"  ", "", 0, 0, false},
+	}
+	for _, tt := range tests {
+		excerpt, ok := Locate(content, tt.quote)
+		if ok != tt.ok {
+			t.Errorf("Locate(%q) ok = %v, want %v", tt.quote, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if excerpt.Text != tt.text || excerpt.StartLine != tt.startLine || excerpt.EndLine != tt.endLine {
+			t.Errorf("Locate(%q) = %q lines %d-%d, want %q lines %d-%d", tt.quote, excerpt.Text, excerpt.StartLine, excerpt.EndLine, tt.text, tt.startLine, tt.endLine)
+		}
+	}
+
+	// Lowercasing İ adds a byte per letter; the match must still be verbatim page text
+	excerpt, ok := Locate("İİİİİİ\nDESCRIPTION of the Flags", "description OF THE flags")
+	if !ok || excerpt.Text != "DESCRIPTION of the Flags" || excerpt.StartLine != 2 {
+		t.Errorf("Locate after non-ASCII text = %+v, %v", excerpt, ok)
+	}
+}
@@ -0,0 +1,220 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+)
+
+// ProtocolVersion is the latest MCP revision the server implements
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the protocol revisions the server can speak, newest first
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// Tool is a tool the server exposes to clients
+type Tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	// Handler runs the tool with its JSON arguments and returns text for the
+	// model. Errors are reported to the model as tool errors, not protocol errors.
+	Handler func(ctx context.Context, arguments json.RawMessage) (string, error) `json:"-"`
+}
+
+// Server is a Model Context Protocol server exposing tools over JSON-RPC
+type Server struct {
+	name    string
+	version string
+	tools   []Tool
+
+	writeMu sync.Mutex
+	cancels sync.Map // Cancel functions of calls in progress, by request ID
+}
+
+// NewServer creates a server that identifies itself with name and version
+func NewServer(name, version string, tools ...Tool) *Server {
+	return &Server{name: name, version: version, tools: tools}
+}
+
+// message is a JSON-RPC request, notification or response
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve reads newline-delimited JSON-RPC messages from r and writes responses
+// to w until r is exhausted, then waits for tool calls in progress. Tool calls
+// run concurrently, each with a context derived from ctx that is cancelled if
+// the client cancels the call.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg message
+		if err := json.Unmarshal(line, &msg); err != nil {
+			s.write(w, message{ID: json.RawMessage("null"), Error: &rpcError{codeParseError, "parse error"}})
+			continue
+		}
+		if msg.Method == "" {
+			continue // A response; the server sends no requests
+		}
+
+		if msg.Method == "tools/call" && msg.ID != nil {
+			callCtx, cancelCall := context.WithCancel(ctx)
+			s.cancels.Store(string(msg.ID), cancelCall)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer s.cancels.Delete(string(msg.ID))
+				defer cancelCall()
+				result, rpcErr := s.callTool(callCtx, msg.Params)
+				s.reply(w, msg.ID, result, rpcErr)
+			}()
+			continue
+		}
+
+		result, rpcErr := s.handle(msg)
+		if msg.ID != nil { // Notifications get no response
+			s.reply(w, msg.ID, result, rpcErr)
+		}
+	}
+	return scanner.Err()
+}
+
+// handle answers every method but tools/call
+func (s *Server) handle(msg message) (any, *rpcError) {
+	switch msg.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if len(msg.Params) > 0 {
+			if err := json.Unmarshal(msg.Params, &params); err != nil {
+				return nil, &rpcError{codeInvalidParams, err.Error()}
+			}
+		}
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": s.name, "version": s.version},
+		}, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		return map[string]any{"tools": s.tools}, nil
+
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err == nil {
+			if cancel, ok := s.cancels.Load(string(params.RequestID)); ok {
+				cancel.(context.CancelFunc)()
+			}
+		}
+		return nil, nil
+
+	case "tools/call":
+		return nil, &rpcError{codeInvalidRequest, "tools/call must be a request"}
+	}
+
+	return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method not found: %s", msg.Method)}
+}
+
+// callTool runs a tool. Unknown tools and bad parameters are protocol errors;
+// failures of the tool itself are reported in the result.
+func (s *Server) callTool(ctx context.Context, rawParams json.RawMessage) (any, *rpcError) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(rawParams, &params); err != nil {
+		return nil, &rpcError{codeInvalidParams, err.Error()}
+	}
+
+	i := slices.IndexFunc(s.tools, func(tool Tool) bool { return tool.Name == params.Name })
+	if i < 0 {
+		return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool: %s", params.Name)}
+	}
+	if params.Arguments == nil {
+		params.Arguments = json.RawMessage("{}")
+	}
+
+	text, err := s.tools[i].Handler(ctx, params.Arguments)
+	if err != nil {
+		return toolResult(err.Error(), true), nil
+	}
+	return toolResult(text, false), nil
+}
+
+// toolResult is the result of a tools/call
+func toolResult(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// reply writes the response to request id: its result, or error if non-nil
+func (s *Server) reply(w io.Writer, id json.RawMessage, result any, rpcErr *rpcError) {
+	if rpcErr != nil {
+		s.write(w, message{ID: id, Error: rpcErr})
+		return
+	}
+	s.write(w, message{ID: id, Result: result})
+}
+
+// write sends one message per line
+func (s *Server) write(w io.Writer, msg message) {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		data, _ = json.Marshal(message{JSONRPC: "2.0", ID: msg.ID, Error: &rpcError{codeInternalError, err.Error()}})
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	w.Write(append(data, '\n'))
+}
@@ -0,0 +1,254 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// response is a message written by the server, with its result left raw
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// lineWriter passes each line the server writes to a channel
+type lineWriter chan []byte
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	w <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+// session drives a server over in-memory pipes
+type session struct {
+	t    *testing.T
+	in   *io.PipeWriter
+	out  lineWriter
+	done chan error
+}
+
+func newSession(t *testing.T, tools ...Tool) *session {
+	t.Helper()
+	r, w := io.Pipe()
+	s := &session{t: t, in: w, out: make(lineWriter, 16), done: make(chan error, 1)}
+	go func() {
+		s.done <- NewServer("heyman", "1.2.3", tools...).Serve(context.Background(), r, s.out)
+	}()
+	t.Cleanup(func() { w.Close() })
+	return s
+}
+
+// send writes a line of input
+func (s *session) send(line string) {
+	s.t.Helper()
+	if _, err := io.WriteString(s.in, line+"\n"); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+// receive returns the next message the server writes
+func (s *session) receive() response {
+	s.t.Helper()
+	select {
+	case line := <-s.out:
+		var resp response
+		if err := json.Unmarshal(line, &resp); err != nil {
+			s.t.Fatalf("invalid response %q: %v", line, err)
+		}
+		return resp
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no response from the server")
+	}
+	return response{}
+}
+
+// finish closes the input and waits for Serve to return
+func (s *session) finish() {
+	s.t.Helper()
+	s.in.Close()
+	select {
+	case err := <-s.done:
+		if err != nil {
+			s.t.Errorf("Serve error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("Serve didn't return after its input closed")
+	}
+	if len(s.out) > 0 {
+		s.t.Errorf("unexpected response: %s", <-s.out)
+	}
+}
+
+func TestInitializeNegotiatesVersion(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{"protocolVersion": "2025-03-26"}`, "2025-03-26"},
+		{`{"protocolVersion": "2024-11-05"}`, "2024-11-05"},
+		{`{"protocolVersion": "1999-01-01"}`, ProtocolVersion},
+		{`{}`, ProtocolVersion},
+	}
+	s := newSession(t)
+	for i, tt := range tests {
+		s.send(fmt.Sprintf(`{"jsonrpc": "2.0", "id": %d, "method": "initialize", "params": %s}`, i, tt.params))
+		resp := s.receive()
+		var result struct {
+			ProtocolVersion string            `json:"protocolVersion"`
+			ServerInfo      map[string]string `json:"serverInfo"`
+		}
+		if err := json.Unmarshal(resp.Result, &result); err != nil || resp.Error != nil {
+			t.Fatalf("initialize(%s) = %+v, %v", tt.params, resp, err)
+		}
+		if string(resp.ID) != fmt.Sprint(i) || result.ProtocolVersion != tt.want {
+			t.Errorf("initialize(%s) = id %s, version %s; want id %d, version %s", tt.params, resp.ID, result.ProtocolVersion, i, tt.want)
+		}
+		if result.ServerInfo["name"] != "heyman" || result.ServerInfo["version"] != "1.2.3" {
+			t.Errorf("serverInfo = %v", result.ServerInfo)
+		}
+	}
+	s.finish()
+}
+
+func TestNotificationsGetNoReply(t *testing.T) {
+	s := newSession(t)
+	s.send(`{"jsonrpc": "2.0", "method": "notifications/initialized"}`)
+	s.send(`{"jsonrpc": "2.0", "method": "notifications/unknown"}`)
+	s.send(`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": 99}}`)
+	s.send(`{"jsonrpc": "2.0", "id": "r1", "result": {}}`) // A response, not a request
+	s.send(`{"jsonrpc": "2.0", "id": "p", "method": "ping"}`)
+
+	// The ping's reply is the first thing written
+	if resp := s.receive(); string(resp.ID) != `"p"` || resp.Error != nil {
+		t.Errorf("first reply = %+v, want the ping's", resp)
+	}
+
+	s.send(`{"jsonrpc": "2.0", "id": 3, "method": "resources/list"}`)
+	if resp := s.receive(); resp.Error == nil || resp.Error.Code != codeMethodNotFound {
+		t.Errorf("unknown method reply = %+v, want method not found", resp)
+	}
+	s.finish()
+}
+
+func TestParseErrorReply(t *testing.T) {
+	s := newSession(t)
+	s.send(`{"jsonrpc": "2.0", "id": 1, "method":`)
+	resp := s.receive()
+	if string(resp.ID) != "null" || resp.Error == nil || resp.Error.Code != codeParseError {
+		t.Errorf("reply to malformed JSON = %+v, want a parse error with a null id", resp)
+	}
+
+	// The server keeps reading after a bad line
+	s.send(`{"jsonrpc": "2.0", "id": 2, "method": "ping"}`)
+	if resp := s.receive(); string(resp.ID) != "2" || resp.Error != nil {
+		t.Errorf("ping after a parse error = %+v", resp)
+	}
+	s.finish()
+}
+
+func TestToolCalls(t *testing.T) {
+	echo := Tool{
+		Name:        "echo",
+		Description: "Echo the text argument",
+		InputSchema: map[string]any{"type": "object"},
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct{ Text string }
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			if args.Text == "" {
+				return "", errors.New("no text")
+			}
+			return args.Text, nil
+		},
+	}
+	s := newSession(t, echo)
+
+	s.send(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`)
+	var list struct {
+		Tools []map[string]any `json:"tools"`
+	}
+	if err := json.Unmarshal(s.receive().Result, &list); err != nil || len(list.Tools) != 1 || list.Tools[0]["name"] != "echo" {
+		t.Errorf("tools/list = %+v, %v", list, err)
+	}
+
+	tests := []struct {
+		params    string
+		wantText  string
+		wantError bool // Reported to the model in the result
+		wantCode  int  // Protocol error
+	}{
+		{`{"name": "echo", "arguments": {"text": "hi"}}`, "hi", false, 0},
+		{`{"name": "echo"}`, "no text", true, 0},
+		{`{"name": "missing"}`, "", false, codeInvalidParams},
+		{`[1]`, "", false, codeInvalidParams},
+	}
+	for i, tt := range tests {
+		s.send(fmt.Sprintf(`{"jsonrpc": "2.0", "id": %d, "method": "tools/call", "params": %s}`, i+2, tt.params))
+		resp := s.receive()
+		if tt.wantCode != 0 {
+			if resp.Error == nil || resp.Error.Code != tt.wantCode {
+				t.Errorf("tools/call %s = %+v, want error code %d", tt.params, resp, tt.wantCode)
+			}
+			continue
+		}
+		var result struct {
+			Content []struct{ Text string } `json:"content"`
+			IsError bool                    `json:"isError"`
+		}
+		if err := json.Unmarshal(resp.Result, &result); err != nil || len(result.Content) != 1 {
+			t.Fatalf("tools/call %s = %+v, %v", tt.params, resp, err)
+		}
+		if result.Content[0].Text != tt.wantText || result.IsError != tt.wantError {
+			t.Errorf("tools/call %s = %+v, want %q (error %v)", tt.params, result, tt.wantText, tt.wantError)
+		}
+	}
+
+	s.send(`{"jsonrpc": "2.0", "method": "tools/call", "params": {"name": "echo"}}`)
+	s.finish()
+}
+
+func TestCancelledNotificationCancelsCall(t *testing.T) {
+	started := make(chan struct{})
+	wait := Tool{
+		Name: "wait",
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			close(started)
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(5 * time.Second):
+				return "not cancelled", nil
+			}
+		},
+	}
+	s := newSession(t, wait)
+
+	s.send(`{"jsonrpc": "2.0", "id": "slow", "method": "tools/call", "params": {"name": "wait"}}`)
+	<-started
+	// Other requests are answered while the call runs
+	s.send(`{"jsonrpc": "2.0", "id": 1, "method": "ping"}`)
+	if resp := s.receive(); string(resp.ID) != "1" {
+		t.Fatalf("reply during the call = %+v, want the ping's", resp)
+	}
+
+	s.send(`{"jsonrpc": "2.0", "method": "notifications/cancelled", "params": {"requestId": "slow", "reason": "user"}}`)
+	resp := s.receive()
+	var result struct {
+		Content []struct{ Text string } `json:"content"`
+		IsError bool                    `json:"isError"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.ID) != `"slow"` || !result.IsError || len(result.Content) != 1 || result.Content[0].Text != context.Canceled.Error() {
+		t.Errorf("cancelled call reply = %s %+v, want the call's context cancelled", resp.ID, result)
+	}
+	s.finish()
+}
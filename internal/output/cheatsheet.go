@@ -0,0 +1,72 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alecf/heyman/internal/parser"
+)
+
+// FormatCheatsheetMarkdown formats cheat sheet entries as a Markdown document:
+// a heading per task, each followed by its command in a code block
+func FormatCheatsheetMarkdown(command, description string, entries []parser.ParsedResponse) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", command)
+	if description != "" {
+		fmt.Fprintf(&b, "\n%s\n", sentence(description))
+	}
+
+	for _, entry := range entries {
+		heading := strings.TrimSuffix(sentence(entryTask(entry)), ".")
+		fmt.Fprintf(&b, "\n## %s\n\n```sh\n%s\n```\n", heading, entry.Command)
+	}
+	return b.String()
+}
+
+// FormatCheatsheetTLDR formats cheat sheet entries as a tldr page
+// (https://github.com/tldr-pages/tldr), with placeholders in {{braces}}
+func FormatCheatsheetTLDR(command, description string, entries []parser.ParsedResponse) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", command)
+	if description != "" {
+		fmt.Fprintf(&b, "> %s\n", sentence(description))
+	}
+	fmt.Fprintf(&b, "> More information: <man %s>.\n", command)
+
+	for _, entry := range entries {
+		task := strings.TrimSuffix(sentence(entryTask(entry)), ".")
+		example := parser.PlaceholderRegex.ReplaceAllStringFunc(entry.Command, func(placeholder string) string {
+			return "{{" + strings.Trim(placeholder, "<>") + "}}"
+		})
+		fmt.Fprintf(&b, "\n- %s:\n\n`%s`\n", task, example)
+	}
+	return b.String()
+}
+
+// entryTask returns the task a cheat sheet entry performs, falling back to its
+// explanation or command if the model gave none
+func entryTask(entry parser.ParsedResponse) string {
+	switch {
+	case entry.Task != "":
+		return entry.Task
+	case entry.Explanation != "":
+		return entry.Explanation
+	}
+	return entry.Command
+}
+
+// sentence capitalizes text and ends it with a period
+func sentence(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(text)
+	text = string(unicode.ToUpper(r)) + text[size:]
+	if !strings.HasSuffix(text, ".") {
+		text += "."
+	}
+	return text
+}
@@ -0,0 +1,53 @@
+package output
+
+import (
+	"testing"
+
+	"github.com/alecf/heyman/internal/parser"
+)
+
+var cheatsheetEntries = []parser.ParsedResponse{
+	{Task: "list files by size", Command: "ls -lS", Valid: true},
+	{Task: "List the contents of <dir> recursively.", Command: "ls -R <dir>", Valid: true},
+	{Explanation: "shows hidden files", Command: "ls -a", Valid: true},
+	{Command: "ls -1", Valid: true},
+}
+
+func TestFormatCheatsheetMarkdown(t *testing.T) {
+	got := FormatCheatsheetMarkdown("ls", "list directory contents", cheatsheetEntries)
+	want := "# ls\n\nList directory contents.\n" +
+		"\n## List files by size\n\n```sh\nls -lS\n```\n" +
+		"\n## List the contents of <dir> recursively\n\n```sh\nls -R <dir>\n```\n" +
+		"\n## Shows hidden files\n\n```sh\nls -a\n```\n" +
+		"\n## Ls -1\n\n```sh\nls -1\n```\n"
+	if got != want {
+		t.Errorf("FormatCheatsheetMarkdown =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := FormatCheatsheetMarkdown("ls", "", nil); got != "# ls\n" {
+		t.Errorf("empty cheat sheet = %q, want only the heading", got)
+	}
+}
+
+func TestFormatCheatsheetTLDR(t *testing.T) {
+	entries := append(cheatsheetEntries[:2:2], parser.ParsedResponse{
+		Task:    "copy <src> to <user@host:/path>",
+		Command: "scp <src> <user@host:/path> <not a placeholder>",
+		Valid:   true,
+	})
+	got := FormatCheatsheetTLDR("ls", "list directory contents.", entries)
+	want := "# ls\n\n" +
+		"> List directory contents.\n" +
+		"> More information: <man ls>.\n" +
+		"\n- List files by size:\n\n`ls -lS`\n" +
+		"\n- List the contents of <dir> recursively:\n\n`ls -R {{dir}}`\n" +
+		"\n- Copy <src> to <user@host:/path>:\n\n`scp {{src}} <user@host:/path> <not a placeholder>`\n"
+	if got != want {
+		t.Errorf("FormatCheatsheetTLDR =\n%s\nwant\n%s", got, want)
+	}
+
+	got = FormatCheatsheetTLDR("ls", "", nil)
+	if want := "# ls\n\n> More information: <man ls>.\n"; got != want {
+		t.Errorf("tldr page without a description = %q, want %q", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package output
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/alecf/heyman/internal/llm"
 	"github.com/alecf/heyman/internal/parser"
@@ -10,14 +11,20 @@ import (
 
 // JSONOutput represents the JSON output format
 type JSONOutput struct {
-	Command     string              `json:"command"`
-	Explanation string              `json:"explanation,omitempty"`
-	Metadata    *Metadata           `json:"metadata,omitempty"`
+	Command      string             `json:"command"`
+	Explanation  string             `json:"explanation,omitempty"`
+	Tradeoff     string             `json:"tradeoff,omitempty"`
+	Task         string             `json:"task,omitempty"`
+	FlagsUsed    []parser.FlagUsage `json:"flags_used,omitempty"`
+	Placeholders []string           `json:"placeholders,omitempty"`
+	Confidence   float64            `json:"confidence,omitempty"`
+	Metadata     *Metadata          `json:"metadata,omitempty"`
 }
 
 // Metadata represents metadata about the query
 type Metadata struct {
 	Provider     string  `json:"provider"`
+	Profile      string  `json:"profile,omitempty"`
 	Model        string  `json:"model"`
 	TokensInput  int     `json:"tokens_input"`
 	TokensOutput int     `json:"tokens_output"`
@@ -27,18 +34,7 @@ type Metadata struct {
 
 // FormatJSON formats the output as JSON
 func FormatJSON(parsed parser.ParsedResponse, resp *llm.QueryResponse, cost *float64) (string, error) {
-	output := JSONOutput{
-		Command:     parsed.Command,
-		Explanation: parsed.Explanation,
-		Metadata: &Metadata{
-			Provider:     resp.Provider,
-			Model:        resp.Model,
-			TokensInput:  resp.TokensInput,
-			TokensOutput: resp.TokensOutput,
-			Cached:       resp.Cached,
-			Cost:         cost,
-		},
-	}
+	output := newJSONOutput(parsed, newMetadata(resp, cost))
 
 	data, err := json.MarshalIndent(output, "", "  ")
 	if err != nil {
@@ -48,6 +44,86 @@ func FormatJSON(parsed parser.ParsedResponse, resp *llm.QueryResponse, cost *flo
 	return string(data), nil
 }
 
+// FormatJSONAlternatives formats alternative commands as a JSON array, best
+// first. Every element carries the metadata of the single query that produced them.
+func FormatJSONAlternatives(alternatives []parser.ParsedResponse, resp *llm.QueryResponse, cost *float64) (string, error) {
+	metadata := newMetadata(resp, cost)
+	outputs := make([]JSONOutput, len(alternatives))
+	for i, parsed := range alternatives {
+		outputs[i] = newJSONOutput(parsed, metadata)
+	}
+
+	data, err := json.MarshalIndent(outputs, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// BatchInput is a question from a heyman batch input file
+type BatchInput struct {
+	Command  string `json:"command"`
+	Section  string `json:"section,omitempty"`
+	Question string `json:"question"`
+}
+
+// BatchResult is one line of heyman batch output: the answer to a question,
+// or why there is none
+type BatchResult struct {
+	Line   int         `json:"line"` // 1-based line of the question in the input
+	Input  BatchInput  `json:"input"`
+	Answer *JSONOutput `json:"answer,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// FormatBatchAnswer formats the answer to a batch question as one line of JSON
+func FormatBatchAnswer(line int, input BatchInput, parsed parser.ParsedResponse, resp *llm.QueryResponse, cost *float64) (string, error) {
+	answer := newJSONOutput(parsed, newMetadata(resp, cost))
+	return formatBatchResult(BatchResult{Line: line, Input: input, Answer: &answer})
+}
+
+// FormatBatchError formats the failure to answer a batch question as one line of JSON
+func FormatBatchError(line int, input BatchInput, err error) (string, error) {
+	return formatBatchResult(BatchResult{Line: line, Input: input, Error: err.Error()})
+}
+
+// formatBatchResult marshals a batch result without indentation
+func formatBatchResult(result BatchResult) (string, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// newJSONOutput converts a parsed response for JSON output
+func newJSONOutput(parsed parser.ParsedResponse, metadata *Metadata) JSONOutput {
+	return JSONOutput{
+		Command:      parsed.Command,
+		Explanation:  parsed.Explanation,
+		Tradeoff:     parsed.Tradeoff,
+		Task:         parsed.Task,
+		FlagsUsed:    parsed.FlagsUsed,
+		Placeholders: parsed.Placeholders,
+		Confidence:   parsed.Confidence,
+		Metadata:     metadata,
+	}
+}
+
+// newMetadata builds the metadata for a query response
+func newMetadata(resp *llm.QueryResponse, cost *float64) *Metadata {
+	return &Metadata{
+		Provider:     resp.Provider,
+		Profile:      resp.Profile,
+		Model:        resp.Model,
+		TokensInput:  resp.TokensInput,
+		TokensOutput: resp.TokensOutput,
+		Cached:       resp.Cached,
+		Cost:         cost,
+	}
+}
+
 // FormatPlain formats the output as plain text
 func FormatPlain(parsed parser.ParsedResponse) string {
 	result := parsed.Command
@@ -56,3 +132,63 @@ func FormatPlain(parsed parser.ParsedResponse) string {
 	}
 	return result
 }
+
+// FormatSources formats the man page citations backing each flag, one per
+// entry with its section and line range. Returns "" if there are none.
+func FormatSources(flags []parser.FlagUsage) string {
+	var b strings.Builder
+	for _, flag := range flags {
+		if flag.Citation == "" {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString("Sources:\n")
+		}
+
+		if flag.Verified {
+			location := fmt.Sprintf("lines %d-%d", flag.StartLine, flag.EndLine)
+			if flag.StartLine == flag.EndLine {
+				location = fmt.Sprintf("line %d", flag.StartLine)
+			}
+			if flag.Section != "" {
+				location = flag.Section + ", " + location
+			}
+			fmt.Fprintf(&b, "  %s (%s)\n", flag.Flag, location)
+		} else {
+			fmt.Fprintf(&b, "  %s (⚠ not found in man page)\n", flag.Flag)
+		}
+		fmt.Fprintf(&b, "    \"%s\"\n", flag.Citation)
+	}
+	return b.String()
+}
+
+// FormatAlternatives formats alternative commands as a numbered list with
+// their trade-offs and, when explain is set, explanations and sources
+func FormatAlternatives(alternatives []parser.ParsedResponse, explain bool) string {
+	var b strings.Builder
+	for i, alt := range alternatives {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%d. %s\n", i+1, alt.Command)
+		if alt.Tradeoff != "" {
+			fmt.Fprintf(&b, "   Trade-off: %s\n", alt.Tradeoff)
+		}
+		if !explain {
+			continue
+		}
+		if alt.Explanation != "" {
+			b.WriteString(indent(alt.Explanation, "   "))
+		}
+		if sources := FormatSources(alt.FlagsUsed); sources != "" {
+			b.WriteString(indent(sources, "   "))
+		}
+	}
+	return b.String()
+}
+
+// indent prefixes every line of s, ending it with a newline
+func indent(s, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
@@ -0,0 +1,220 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AlternativesResponse is the JSON object requested when several alternative
+// commands are asked for
+type AlternativesResponse struct {
+	Alternatives []StructuredAlternative `json:"alternatives"`
+	NotFound     bool                    `json:"not_found"`
+}
+
+// StructuredAlternative is one command in an AlternativesResponse
+type StructuredAlternative struct {
+	StructuredResponse
+	Tradeoff string `json:"tradeoff"`
+}
+
+// AlternativesSchemaName identifies AlternativesSchema in structured output requests
+const AlternativesSchemaName = "heyman_alternatives"
+
+// AlternativesSchema returns the JSON schema for AlternativesResponse, following
+// the same strict-mode rules as ResponseSchema
+func AlternativesSchema() map[string]any {
+	properties := responseProperties()
+	properties["tradeoff"] = map[string]any{
+		"type":        "string",
+		"description": "One line on when to prefer this alternative",
+	}
+
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"alternatives": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type":                 "object",
+					"properties":           properties,
+					"required":             []string{"command", "explanation", "flags_used", "placeholders", "confidence", "tradeoff"},
+					"additionalProperties": false,
+				},
+				"description": "Distinct commands that answer the question, best first",
+			},
+			"not_found": map[string]any{
+				"type":        "boolean",
+				"description": "True if the man page does not contain the information needed",
+			},
+		},
+		"required":             []string{"alternatives", "not_found"},
+		"additionalProperties": false,
+	}
+}
+
+// listMarkerRegex matches list numbering models put in front of alternatives ("1. ", "2) ", "- ")
+var listMarkerRegex = regexp.MustCompile(`^(\d+[.)]|[-*])\s+`)
+
+// numberedItemRegex matches the numbering the alternatives prompt asks for ("1. ", "2) ")
+var numberedItemRegex = regexp.MustCompile(`^\d+[.)]\s+`)
+
+// ParseAlternatives parses a response listing several alternative commands.
+// Each alternative is validated independently and duplicates are dropped, so
+// the result may mix valid and invalid entries; it is never empty. A response
+// containing a single command (e.g. from a strict retry) parses as one entry.
+func (p *Parser) ParseAlternatives(response string) []ParsedResponse {
+	response = strings.TrimSpace(response)
+
+	alternatives, ok := p.parseStructuredAlternatives(response)
+	if !ok {
+		if parsed, ok := p.parseStructured(response); ok {
+			alternatives = []ParsedResponse{parsed}
+		} else {
+			alternatives = p.parseTextAlternatives(response)
+		}
+	}
+
+	return p.distinct(alternatives)
+}
+
+// distinct drops duplicate commands from a list and cites the flags of the
+// valid ones. The result is never empty: if the list is, it holds one invalid
+// entry explaining why.
+func (p *Parser) distinct(commands []ParsedResponse) []ParsedResponse {
+	seen := make(map[string]bool)
+	var result []ParsedResponse
+	for _, parsed := range commands {
+		if parsed.Valid {
+			key := strings.Join(strings.Fields(parsed.Command), " ")
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			if p.manPage != "" {
+				parsed.FlagsUsed = p.citeFlags(parsed.Command, parsed.FlagsUsed)
+			}
+		}
+		result = append(result, parsed)
+	}
+
+	if len(result) == 0 {
+		return []ParsedResponse{{
+			Valid: false,
+			Error: fmt.Errorf("no command found in response (expected lines running '%s')", p.commandName),
+		}}
+	}
+	return result
+}
+
+// parseStructuredAlternatives parses a JSON alternatives object. ok is false if
+// the response is not one.
+func (p *Parser) parseStructuredAlternatives(response string) (alternatives []ParsedResponse, ok bool) {
+	body := strings.TrimSpace(stripMarkdownCodeBlocks(response))
+	if !strings.HasPrefix(body, "{") {
+		return nil, false
+	}
+
+	var structured AlternativesResponse
+	if err := json.Unmarshal([]byte(body), &structured); err != nil || (structured.Alternatives == nil && !structured.NotFound) {
+		return nil, false
+	}
+
+	if structured.NotFound {
+		return []ParsedResponse{{
+			Valid: false,
+			Error: fmt.Errorf("information not found in man page"),
+		}}, true
+	}
+
+	for _, alt := range structured.Alternatives {
+		parsed := p.fromStructured(alt.StructuredResponse)
+		parsed.Tradeoff = strings.TrimSpace(alt.Tradeoff)
+		alternatives = append(alternatives, parsed)
+	}
+	return alternatives, true
+}
+
+// parseTextAlternatives parses free-text alternatives: each starts with a
+// numbered line, or a line in a code fence, that parses as a command running
+// the expected program, optionally followed by a "Trade-off:" line and (in
+// explain mode) explanation lines. Other lines never start an alternative, so
+// prose that happens to parse as a command ("ls lists files in order") isn't
+// mistaken for one. A response that is a single line is parsed as one command,
+// as the strict retry prompt asks for.
+func (p *Parser) parseTextAlternatives(response string) []ParsedResponse {
+	if strings.Contains(response, "cannot find this information in the man page") {
+		return []ParsedResponse{{
+			Valid: false,
+			Error: fmt.Errorf("information not found in man page"),
+		}}
+	}
+	if !strings.Contains(response, "\n") {
+		return []ParsedResponse{p.Parse(response)}
+	}
+
+	var alternatives []ParsedResponse
+	var explanation []string
+
+	finish := func() {
+		if n := len(alternatives); n > 0 && p.explainMode {
+			alternatives[n-1].Explanation = strings.Join(explanation, "\n")
+		}
+		explanation = nil
+	}
+
+	inFence := false
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "```") {
+			inFence = !inFence
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		numbered := numberedItemRegex.MatchString(line)
+		line = listMarkerRegex.ReplaceAllString(line, "")
+		line = strings.Trim(line, "`")
+
+		if label, rest, found := strings.Cut(line, ":"); found && isTradeoffLabel(label) {
+			if n := len(alternatives); n > 0 {
+				alternatives[n-1].Tradeoff = strings.TrimSpace(rest)
+			}
+			continue
+		}
+
+		if numbered || inFence {
+			command, err := p.NormalizeCommand(line)
+			if err == nil {
+				finish()
+				alternatives = append(alternatives, ParsedResponse{Command: command, Valid: true})
+				continue
+			}
+			if strings.HasPrefix(line, p.commandName) {
+				finish()
+				alternatives = append(alternatives, ParsedResponse{Valid: false, Error: err})
+				continue
+			}
+		}
+
+		if len(alternatives) > 0 {
+			explanation = append(explanation, line)
+		}
+	}
+	finish()
+
+	return alternatives
+}
+
+// isTradeoffLabel reports whether a line label introduces a trade-off note
+func isTradeoffLabel(label string) bool {
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case "trade-off", "tradeoff", "trade off":
+		return true
+	}
+	return false
+}
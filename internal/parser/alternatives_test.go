@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseTextAlternatives(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []string // Valid commands, in order
+	}{
+		{
+			name:     "numbered",
+			response: "1. ls -lS\nTrade-off: Largest first\n\n2) ls -lSr\nTrade-off: Smallest first",
+			want:     []string{"ls -lS", "ls -lSr"},
+		},
+		{
+			name:     "fenced",
+			response: "```\nls -lS\n```\nTrade-off: Largest first\n\n```bash\nls -1S\n```",
+			want:     []string{"ls -lS", "ls -1S"},
+		},
+		{
+			name:     "strict retry answer",
+			response: "ls -lS",
+			want:     []string{"ls -lS"},
+		},
+		{
+			name:     "prose that parses as a command",
+			response: "Here is how to do it:\nls lists files in order\nUse -S to sort by size.",
+			want:     nil,
+		},
+		{
+			name:     "prose after a numbered alternative",
+			response: "1. ls -lS\nTrade-off: Largest first\nls lists files in order of size with -S",
+			want:     []string{"ls -lS"},
+		},
+		{
+			name:     "unnumbered blocks",
+			response: "ls -lS\nTrade-off: Largest first\n\nls -lSr\nTrade-off: Smallest first",
+			want:     nil,
+		},
+	}
+	p := New("ls", false)
+	for _, tt := range tests {
+		var got []string
+		for _, alt := range p.ParseAlternatives(tt.response) {
+			if alt.Valid {
+				got = append(got, alt.Command)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: valid commands = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseTextAlternativesKeepsTradeoffsAndExplanations(t *testing.T) {
+	response := "1. ls -lS\nTrade-off: Largest first\nSorts by size.\n\n2. ls -lSr\nTrade-off: Smallest first"
+	got := New("ls", true).ParseAlternatives(response)
+	if len(got) != 2 {
+		t.Fatalf("got %d alternatives, want 2: %+v", len(got), got)
+	}
+	if got[0].Tradeoff != "Largest first" || got[0].Explanation != "Sorts by size." || got[1].Tradeoff != "Smallest first" {
+		t.Errorf("alternatives = %+v", got)
+	}
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CheatsheetResponse is the JSON object requested for a cheat sheet
+type CheatsheetResponse struct {
+	Entries  []StructuredCheatsheetEntry `json:"entries"`
+	NotFound bool                        `json:"not_found"`
+}
+
+// StructuredCheatsheetEntry is one task in a CheatsheetResponse
+type StructuredCheatsheetEntry struct {
+	StructuredResponse
+	Task string `json:"task"`
+}
+
+// CheatsheetSchemaName identifies CheatsheetSchema in structured output requests
+const CheatsheetSchemaName = "heyman_cheatsheet"
+
+// CheatsheetSchema returns the JSON schema for CheatsheetResponse, following
+// the same strict-mode rules as ResponseSchema
+func CheatsheetSchema() map[string]any {
+	properties := responseProperties()
+	properties["task"] = map[string]any{
+		"type":        "string",
+		"description": "Short description of the task, starting with a verb",
+	}
+
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"entries": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type":                 "object",
+					"properties":           properties,
+					"required":             []string{"command", "explanation", "flags_used", "placeholders", "confidence", "task"},
+					"additionalProperties": false,
+				},
+				"description": "The most useful tasks, most common first",
+			},
+			"not_found": map[string]any{
+				"type":        "boolean",
+				"description": "True if the man page does not contain the information needed",
+			},
+		},
+		"required":             []string{"entries", "not_found"},
+		"additionalProperties": false,
+	}
+}
+
+// ParseCheatsheet parses a cheat sheet response into its entries, each a
+// command with its Task set. Like ParseAlternatives, every entry is validated
+// independently, duplicates are dropped and the result is never empty.
+func (p *Parser) ParseCheatsheet(response string) []ParsedResponse {
+	response = strings.TrimSpace(response)
+
+	entries, ok := p.parseStructuredCheatsheet(response)
+	if !ok {
+		entries = p.parseTextCheatsheet(response)
+	}
+	return p.distinct(entries)
+}
+
+// parseStructuredCheatsheet parses a JSON cheat sheet. ok is false if the
+// response is not one.
+func (p *Parser) parseStructuredCheatsheet(response string) (entries []ParsedResponse, ok bool) {
+	body := strings.TrimSpace(stripMarkdownCodeBlocks(response))
+	if !strings.HasPrefix(body, "{") {
+		return nil, false
+	}
+
+	var structured CheatsheetResponse
+	if err := json.Unmarshal([]byte(body), &structured); err != nil || (structured.Entries == nil && !structured.NotFound) {
+		return nil, false
+	}
+
+	if structured.NotFound {
+		return []ParsedResponse{{
+			Valid: false,
+			Error: fmt.Errorf("information not found in man page"),
+		}}, true
+	}
+
+	for _, entry := range structured.Entries {
+		parsed := p.fromStructured(entry.StructuredResponse)
+		parsed.Task = strings.TrimSpace(entry.Task)
+		entries = append(entries, parsed)
+	}
+	return entries, true
+}
+
+// parseTextCheatsheet parses a free-text cheat sheet: "Task:" lines, each
+// followed by a line that parses as a command running the expected program
+func (p *Parser) parseTextCheatsheet(response string) []ParsedResponse {
+	if strings.Contains(response, "cannot find this information in the man page") {
+		return []ParsedResponse{{
+			Valid: false,
+			Error: fmt.Errorf("information not found in man page"),
+		}}
+	}
+
+	var entries []ParsedResponse
+	var task string
+
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "```") {
+			continue
+		}
+		line = listMarkerRegex.ReplaceAllString(line, "")
+		line = strings.Trim(line, "`*")
+
+		if label, rest, found := strings.Cut(line, ":"); found && strings.EqualFold(strings.TrimSpace(label), "task") {
+			task = strings.Trim(rest, "* ")
+			continue
+		}
+
+		command, err := p.NormalizeCommand(line)
+		switch {
+		case err == nil:
+			entries = append(entries, ParsedResponse{Command: command, Task: task, Valid: true})
+		case strings.HasPrefix(line, p.commandName):
+			entries = append(entries, ParsedResponse{Task: task, Valid: false, Error: err})
+		default:
+			continue
+		}
+		task = ""
+	}
+
+	return entries
+}
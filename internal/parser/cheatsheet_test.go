@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+// cheatsheetEntry is the part of a parsed cheat sheet entry the tests compare
+type cheatsheetEntry struct {
+	Task, Command string
+	Valid         bool
+}
+
+func TestParseCheatsheet(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []cheatsheetEntry
+	}{
+		{
+			name: "structured",
+			response: `{"entries": [
+				{"task": " List files by size ", "command": "ls  -lS", "explanation": "", "flags_used": [], "placeholders": [], "confidence": 0.9},
+				{"task": "Delete everything", "command": "rm -rf ~", "explanation": "", "flags_used": [], "placeholders": [], "confidence": 0.1},
+				{"task": "Sort by size again", "command": "ls -lS", "explanation": "", "flags_used": [], "placeholders": [], "confidence": 0.5},
+				{"task": "Show hidden files", "command": "ls -a", "explanation": "", "flags_used": [], "placeholders": [], "confidence": 0.8}
+			], "not_found": false}`,
+			want: []cheatsheetEntry{
+				{"List files by size", "ls -lS", true},
+				{"Delete everything", "", false},
+				{"Show hidden files", "ls -a", true},
+			},
+		},
+		{
+			name:     "structured in a fence",
+			response: "```json\n{\"entries\": [{\"task\": \"List one per line\", \"command\": \"ls -1\"}], \"not_found\": false}\n```",
+			want:     []cheatsheetEntry{{"List one per line", "ls -1", true}},
+		},
+		{
+			name:     "structured not found",
+			response: `{"entries": [], "not_found": true}`,
+			want:     []cheatsheetEntry{{"", "", false}},
+		},
+		{
+			name: "text",
+			response: "Here is a cheat sheet:\n\n" +
+				"1. **Task:** List files by size\n```sh\nls -lS\n```\n\n" +
+				"2. Task: Show hidden files\n`ls -a`\n\n" +
+				"- task: Broken\nls -l; rm -rf ~\n\n" +
+				"Task: Duplicate\nls   -a\n\n" +
+				"Task: No task line follows\n" +
+				"ls -1",
+			want: []cheatsheetEntry{
+				{"List files by size", "ls -lS", true},
+				{"Show hidden files", "ls -a", true},
+				{"Broken", "", false},
+				{"No task line follows", "ls -1", true},
+			},
+		},
+		{
+			name:     "text without a task",
+			response: "ls -lS",
+			want:     []cheatsheetEntry{{"", "ls -lS", true}},
+		},
+		{
+			name:     "text not found",
+			response: "I cannot find this information in the man page.",
+			want:     []cheatsheetEntry{{"", "", false}},
+		},
+		{
+			name:     "prose only",
+			response: "Sorry, I have nothing useful.",
+			want:     []cheatsheetEntry{{"", "", false}},
+		},
+	}
+
+	p := New("ls", false)
+	for _, tt := range tests {
+		var got []cheatsheetEntry
+		for _, entry := range p.ParseCheatsheet(tt.response) {
+			if !entry.Valid && entry.Error == nil {
+				t.Errorf("%s: invalid entry without an error: %+v", tt.name, entry)
+			}
+			got = append(got, cheatsheetEntry{entry.Task, entry.Command, entry.Valid})
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: entries = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
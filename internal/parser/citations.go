@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"strings"
+
+	"github.com/alecf/heyman/internal/manpage"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// citeFlags verifies the model's citations against the man page and adds
+// citations for flags in the command the model did not cite (all of them, for
+// free-text responses). A citation that cannot be found is replaced by the
+// page's own entry for the flag; failing that it is kept but marked unverified
+// so it is never presented as a quote from the page.
+func (p *Parser) citeFlags(command string, flags []FlagUsage) []FlagUsage {
+	cited := make(map[string]bool)
+	result := make([]FlagUsage, 0, len(flags))
+
+	for _, flag := range flags {
+		flag.Verified = false
+		if excerpt, ok := manpage.Locate(p.manPage, flag.Citation); ok {
+			flag.setExcerpt(excerpt)
+		} else if excerpt, ok := manpage.FindFlag(p.manPage, flag.Flag); ok {
+			flag.setExcerpt(excerpt)
+		}
+		cited[flag.Flag] = true
+		result = append(result, flag)
+	}
+
+	for _, flag := range p.Flags(command) {
+		excerpt, ok := manpage.FindFlag(p.manPage, flag)
+		if !ok && !strings.HasPrefix(flag, "--") {
+			// Not documented as a whole ("-la"), so try it as a cluster of short flags
+			for _, r := range flag[1:] {
+				short := "-" + string(r)
+				if excerpt, ok := manpage.FindFlag(p.manPage, short); ok && !cited[short] {
+					cited[short] = true
+					result = append(result, citedFlag(short, excerpt))
+				}
+			}
+			continue
+		}
+		if ok && !cited[flag] {
+			cited[flag] = true
+			result = append(result, citedFlag(flag, excerpt))
+		}
+	}
+
+	return result
+}
+
+// citedFlag creates a FlagUsage for a flag found in the man page
+func citedFlag(flag string, excerpt manpage.Excerpt) FlagUsage {
+	usage := FlagUsage{Flag: flag}
+	usage.setExcerpt(excerpt)
+	return usage
+}
+
+// setExcerpt records a verified man page location
+func (f *FlagUsage) setExcerpt(excerpt manpage.Excerpt) {
+	f.Citation = excerpt.Text
+	f.Section = excerpt.Section
+	f.StartLine = excerpt.StartLine
+	f.EndLine = excerpt.EndLine
+	f.Verified = true
+}
+
+// Flags extracts the flags passed to the expected command, wherever it runs
+// in the command line: "ls -la --color=auto | grep -v x" yields -la and
+// --color, not grep's -v. Values attached with "=" are dropped.
+func (p *Parser) Flags(command string) []string {
+	file, _, err := parseShell(command)
+	if err != nil {
+		return nil
+	}
+
+	var flags []string
+	syntax.Walk(file, func(node syntax.Node) bool {
+		call, ok := node.(*syntax.CallExpr)
+		if !ok {
+			return true
+		}
+		name, args := commandCall(call.Args)
+		if name != p.commandName {
+			return true
+		}
+		for _, arg := range args {
+			field, ok := wordText(arg)
+			if field == "--" {
+				break
+			}
+			if !ok || len(field) < 2 || field[0] != '-' {
+				continue
+			}
+			name, _, _ := strings.Cut(field, "=")
+			flags = append(flags, name)
+		}
+		return true
+	})
+	return flags
+}
+
+// wordText returns the text of a word made only of literal and quoted
+// parts ("-x", '-x', "--name=value"); ok is false if it has expansions
+func wordText(word *syntax.Word) (text string, ok bool) {
+	var b strings.Builder
+	for _, part := range word.Parts {
+		switch part := part.(type) {
+		case *syntax.Lit:
+			b.WriteString(part.Value)
+		case *syntax.SglQuoted:
+			b.WriteString(part.Value)
+		case *syntax.DblQuoted:
+			for _, inner := range part.Parts {
+				lit, isLit := inner.(*syntax.Lit)
+				if !isLit {
+					return "", false
+				}
+				b.WriteString(lit.Value)
+			}
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
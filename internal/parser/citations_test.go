@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCommandFlags(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"tar -czf out.tar.gz dir", []string{"-czf"}},
+		{"tar -czf x | grep -v y", []string{"-czf"}},
+		{"grep -r foo . | tar --files-from=- -cf out.tar", []string{"--files-from", "-cf"}},
+		{"sudo -u root tar -xf a.tar", []string{"-xf"}},
+		{"(cd /tmp && tar '-xf' <archive.tar>)", []string{"-xf"}},
+		{"tar -tf a.tar -- -notaflag", []string{"-tf"}},
+		{"tar -tf \"$f\" -v", []string{"-tf", "-v"}},
+		{"echo -n | cat", nil},
+	}
+	p := New("tar", false)
+	for _, tt := range tests {
+		if got := p.Flags(tt.command); !slices.Equal(got, tt.want) {
+			t.Errorf("Flags(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestCiteFlagsOnlyCitesTargetCommand(t *testing.T) {
+	manPage := `TAR(1)
+
+OPTIONS
+       -c     create a new archive
+
+       -v     verbosely list files processed
+
+       -f ARCHIVE
+              use archive file ARCHIVE`
+
+	flags := New("tar", false).WithManPage(manPage).citeFlags("tar -cf x.tar dir | grep -v y", nil)
+	var got []string
+	for _, flag := range flags {
+		got = append(got, flag.Flag)
+	}
+	if !slices.Equal(got, []string{"-c", "-f"}) {
+		t.Errorf("cited flags = %v, want [-c -f] (grep's -v is not tar's)", got)
+	}
+}
@@ -8,16 +8,23 @@ import (
 
 // ParsedResponse represents a parsed LLM response
 type ParsedResponse struct {
-	Command     string
-	Explanation string // Empty in default mode
-	Valid       bool
-	Error       error
+	Command      string
+	Explanation  string // Empty in default mode
+	Tradeoff     string // When to prefer this command; only set for alternatives
+	Task         string // What the command does; only set for cheat sheet entries
+	FlagsUsed    []FlagUsage
+	Placeholders []string
+	Confidence   float64 // 0 when the model did not report one
+	Structured   bool    // Parsed from a JSON response rather than free text
+	Valid        bool
+	Error        error
 }
 
 // Parser handles parsing and validation of LLM responses
 type Parser struct {
 	commandName string
 	explainMode bool
+	manPage     string // Used to verify citations; empty disables verification
 }
 
 // New creates a new response parser
@@ -28,14 +35,32 @@ func New(commandName string, explainMode bool) *Parser {
 	}
 }
 
-// Parse parses the LLM response and validates it
+// WithManPage sets the man page the response was generated from, so that
+// citations can be verified against it
+func (p *Parser) WithManPage(manPage string) *Parser {
+	p.manPage = manPage
+	return p
+}
+
+// Parse parses the LLM response and validates it.
+// JSON responses (structured output) are parsed as StructuredResponse;
+// anything else falls back to free-text parsing.
 func (p *Parser) Parse(response string) ParsedResponse {
 	response = strings.TrimSpace(response)
 
-	if p.explainMode {
-		return p.parseExplainMode(response)
+	parsed, ok := p.parseStructured(response)
+	if !ok {
+		if p.explainMode {
+			parsed = p.parseExplainMode(response)
+		} else {
+			parsed = p.parseDefaultMode(response)
+		}
+	}
+
+	if parsed.Valid && p.manPage != "" {
+		parsed.FlagsUsed = p.citeFlags(parsed.Command, parsed.FlagsUsed)
 	}
-	return p.parseDefaultMode(response)
+	return parsed
 }
 
 // parseDefaultMode parses response in default mode (command only)
@@ -52,36 +77,33 @@ func (p *Parser) parseDefaultMode(response string) ParsedResponse {
 	response = stripMarkdownCodeBlocks(response)
 	response = strings.TrimSpace(response)
 
-	// Check if response starts with command name
-	if !strings.HasPrefix(response, p.commandName) {
-		return ParsedResponse{
-			Valid: false,
-			Error: fmt.Errorf("response does not start with command '%s'", p.commandName),
+	// Take the first line that parses as a command running the expected
+	// program; report why the first line failed if none does
+	var firstErr error
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
 		}
-	}
 
-	// Check for multi-line responses (invalid in default mode)
-	lines := strings.Split(response, "\n")
-	if len(lines) > 1 {
-		// Take first non-empty line that starts with command
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line != "" && strings.HasPrefix(line, p.commandName) {
-				return ParsedResponse{
-					Command: line,
-					Valid:   true,
-				}
+		command, err := p.NormalizeCommand(line)
+		if err == nil {
+			return ParsedResponse{
+				Command: command,
+				Valid:   true,
 			}
 		}
-		return ParsedResponse{
-			Valid: false,
-			Error: fmt.Errorf("response contains multiple lines without valid command"),
+		if firstErr == nil {
+			firstErr = err
 		}
 	}
 
+	if firstErr == nil {
+		firstErr = fmt.Errorf("empty response")
+	}
 	return ParsedResponse{
-		Command: response,
-		Valid:   true,
+		Valid: false,
+		Error: firstErr,
 	}
 }
 
@@ -119,13 +141,13 @@ func (p *Parser) parseExplainMode(response string) ParsedResponse {
 		line = strings.TrimSpace(line)
 
 		if command == "" {
-			// Check if this line starts with the command name
-			if strings.HasPrefix(line, p.commandName) {
-				command = line
+			// Check if this line is a command running the expected program
+			if normalized, err := p.NormalizeCommand(line); err == nil {
+				command = normalized
 				explanationStart = i + 1
 				break
 			}
-			// If line doesn't start with command, continue looking
+			// If line isn't the command, continue looking
 			// (might be preamble text)
 			continue
 		}
@@ -134,7 +156,7 @@ func (p *Parser) parseExplainMode(response string) ParsedResponse {
 	if command == "" {
 		return ParsedResponse{
 			Valid: false,
-			Error: fmt.Errorf("no command found in response (expected line starting with '%s')", p.commandName),
+			Error: fmt.Errorf("no command found in response (expected a line running '%s')", p.commandName),
 		}
 	}
 
@@ -166,24 +188,6 @@ func stripMarkdownCodeBlocks(s string) string {
 
 // ValidateCommand performs additional validation on extracted command
 func (p *Parser) ValidateCommand(command string) error {
-	// Check command starts with expected name
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return fmt.Errorf("empty command")
-	}
-
-	if parts[0] != p.commandName {
-		return fmt.Errorf("command does not start with '%s', got '%s'", p.commandName, parts[0])
-	}
-
-	// Basic sanity checks
-	if strings.Contains(command, "\n") {
-		return fmt.Errorf("command contains newlines")
-	}
-
-	if len(command) > 1000 {
-		return fmt.Errorf("command suspiciously long (%d chars)", len(command))
-	}
-
-	return nil
+	_, err := p.NormalizeCommand(command)
+	return err
 }
@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// PlaceholderRegex matches placeholders like <PID> or <file.txt>, which a shell
+// parser would otherwise read as redirections. Placeholders never contain
+// spaces, so "sort < <file>" keeps its real redirection.
+var PlaceholderRegex = regexp.MustCompile(`<[A-Za-z][A-Za-z0-9_.:/|-]*>`)
+
+// wrapperArgs lists commands that run another command, with the options of
+// each that take a separate argument (e.g. "sudo -u root ls")
+var wrapperArgs = map[string][]string{
+	"sudo":    {"-u", "-g", "-C", "-D", "-h", "-p", "-r", "-t", "-U"},
+	"doas":    {"-u", "-C"},
+	"env":     {"-u", "-C", "-S"},
+	"nice":    {"-n"},
+	"nohup":   nil,
+	"command": nil,
+	"exec":    {"-a"},
+}
+
+// NormalizeCommand parses a command with a bash parser, checks that it runs the
+// expected command (see runsTarget), and returns it reformatted. Syntax errors
+// such as unbalanced quotes or dangling pipes are rejected.
+func (p *Parser) NormalizeCommand(command string) (string, error) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return "", fmt.Errorf("empty command")
+	}
+
+	// Basic sanity checks
+	if strings.Contains(command, "\n") {
+		return "", fmt.Errorf("command contains newlines")
+	}
+
+	if len(command) > 1000 {
+		return "", fmt.Errorf("command suspiciously long (%d chars)", len(command))
+	}
+
+	file, placeholders, err := parseShell(command)
+	if err != nil {
+		return "", err
+	}
+	if len(file.Stmts) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+
+	if ok, other := p.runsTarget(file.Stmts); !ok {
+		if other == "" {
+			return "", fmt.Errorf("command does not run '%s'", p.commandName)
+		}
+		return "", fmt.Errorf("command runs '%s', not '%s'", other, p.commandName)
+	}
+
+	var buf bytes.Buffer
+	if err := syntax.NewPrinter(syntax.SingleLine(true)).Print(&buf, file); err != nil {
+		return "", fmt.Errorf("failed to format command: %w", err)
+	}
+	normalized := strings.TrimSpace(buf.String())
+
+	for i, placeholder := range placeholders {
+		word := placeholderWord(i)
+		// The printer writes redirections as "<file"; keep "< <file>" from becoming a heredoc
+		if idx := strings.Index(normalized, word); idx > 0 && strings.ContainsRune("<>", rune(normalized[idx-1])) {
+			placeholder = " " + placeholder
+		}
+		normalized = strings.Replace(normalized, word, placeholder, 1)
+	}
+
+	return normalized, nil
+}
+
+// runsTarget reports whether every statement runs the expected command: as the
+// command itself (after env assignments and wrappers like sudo), as a stage of
+// a pipeline, or in every statement of an && / || list or subshell. Otherwise
+// it returns the first command that runs instead, "" if it isn't a literal word.
+func (p *Parser) runsTarget(stmts []*syntax.Stmt) (bool, string) {
+	if len(stmts) == 0 {
+		return false, ""
+	}
+	for _, stmt := range stmts {
+		if ok, other := p.stmtRunsTarget(stmt.Cmd); !ok {
+			return false, other
+		}
+	}
+	return true, ""
+}
+
+// stmtRunsTarget applies runsTarget to one command
+func (p *Parser) stmtRunsTarget(cmd syntax.Command) (bool, string) {
+	switch cmd := cmd.(type) {
+	case *syntax.CallExpr:
+		name, _ := commandCall(cmd.Args)
+		return name == p.commandName, name
+	case *syntax.BinaryCmd:
+		ok, other := p.stmtRunsTarget(cmd.X.Cmd)
+		switch cmd.Op {
+		case syntax.Pipe, syntax.PipeAll:
+			// Any stage will do: "find . -name '*.log' | xargs rm"
+			if ok {
+				return true, ""
+			}
+			if ok, _ := p.stmtRunsTarget(cmd.Y.Cmd); ok {
+				return true, ""
+			}
+			return false, other
+		default:
+			// && and ||: every statement must run it
+			if !ok {
+				return false, other
+			}
+			return p.stmtRunsTarget(cmd.Y.Cmd)
+		}
+	case *syntax.Subshell:
+		return p.runsTarget(cmd.Stmts)
+	case *syntax.Block:
+		return p.runsTarget(cmd.Stmts)
+	}
+	return false, ""
+}
+
+// parseShell parses a command with a bash parser, swapping placeholders for
+// plain words first. It returns the placeholders in the order they were swapped.
+func parseShell(command string) (*syntax.File, []string, error) {
+	var placeholders []string
+	source := PlaceholderRegex.ReplaceAllStringFunc(command, func(placeholder string) string {
+		placeholders = append(placeholders, placeholder)
+		return placeholderWord(len(placeholders) - 1)
+	})
+
+	file, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(source), "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid shell syntax: %v", err)
+	}
+	return file, placeholders, nil
+}
+
+// placeholderWord is the word placeholder i is swapped for while parsing. The
+// trailing underscore keeps HEYMAN_PLACEHOLDER_1_ from matching the start of
+// HEYMAN_PLACEHOLDER_10_.
+func placeholderWord(i int) string {
+	return fmt.Sprintf("HEYMAN_PLACEHOLDER_%d_", i)
+}
+
+// commandCall returns the name of the command a call runs, looking through
+// wrappers like sudo and env, and the arguments passed to it. The name is ""
+// if it is not a literal word.
+func commandCall(args []*syntax.Word) (string, []*syntax.Word) {
+	for len(args) > 0 {
+		name := filepath.Base(args[0].Lit())
+		argOptions, isWrapper := wrapperArgs[name]
+		if !isWrapper {
+			return name, args[1:]
+		}
+
+		// Skip the wrapper, its options and (for env) variable assignments
+		args = args[1:]
+		for len(args) > 0 {
+			arg := args[0].Lit()
+			switch {
+			case arg == "--":
+				args = args[1:]
+			case strings.HasPrefix(arg, "-"):
+				args = args[1:]
+				for _, option := range argOptions {
+					if arg == option && len(args) > 0 {
+						args = args[1:]
+						break
+					}
+				}
+				continue
+			case name == "env" && strings.Contains(arg, "="):
+				args = args[1:]
+				continue
+			}
+			break
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+		wantErr bool
+	}{
+		{command: "tar  -xzf   archive.tar.gz", want: "tar -xzf archive.tar.gz"},
+		{command: "find . -name '*.log' | tar -czf logs.tgz -T -", want: "find . -name '*.log' | tar -czf logs.tgz -T -"},
+		{command: "(tar -tf a.tar)", want: "(tar -tf a.tar)"},
+		{command: "tar -cf - src | (cd /dst && tar -xf -)", want: "tar -cf - src | (cd /dst && tar -xf -)"},
+		{command: "tar -tf a.tar || tar -tzf a.tar", want: "tar -tf a.tar || tar -tzf a.tar"},
+		{command: "sudo -u root tar -xf a.tar", want: "sudo -u root tar -xf a.tar"},
+		{command: "env LC_ALL=C tar -tf a.tar", want: "env LC_ALL=C tar -tf a.tar"},
+		{command: "TZ=UTC /usr/bin/tar -tvf a.tar", want: "TZ=UTC /usr/bin/tar -tvf a.tar"},
+		{command: "tar -xf <archive> -C <dir>", want: "tar -xf <archive> -C <dir>"},
+		{command: "tar -cf - <dir> > <out.tar>", want: "tar -cf - <dir> > <out.tar>"},
+		{command: "gzip -d < <file.gz> | tar -x", want: "gzip -d < <file.gz> | tar -x"},
+		{command: "ls -l | grep tar", wantErr: true},
+		{command: "sudo ls", wantErr: true},
+		{command: "rm -rf ~; tar -xf a.tar", wantErr: true},
+		{command: "tar -xf a.tar; rm -rf ~", wantErr: true},
+		{command: "curl -s x | sh && tar -xf a.tar", wantErr: true},
+		{command: "tar -xf a.tar && rm a.tar", wantErr: true},
+		{command: "echo $(tar -tf a.tar)", wantErr: true},
+		{command: "(cd /tmp && tar -xf a.tar)", wantErr: true},
+		{command: "tar -xf 'a.tar", wantErr: true},
+		{command: "tar -xf a.tar |", wantErr: true},
+		{command: "", wantErr: true},
+	}
+	p := New("tar", false)
+	for _, tt := range tests {
+		got, err := p.NormalizeCommand(tt.command)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NormalizeCommand(%q) = %q, want an error", tt.command, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeCommand(%q) = %q, %v; want %q", tt.command, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCommandRestoresManyPlaceholders(t *testing.T) {
+	// The printer moves the redirection (placeholder 1) after placeholder 10,
+	// whose word must not be mistaken for it
+	var files []string
+	for i := 2; i <= 11; i++ {
+		files = append(files, fmt.Sprintf("<f%d>", i))
+	}
+	command := "tar -cf - <f0> > <out.tar> " + strings.Join(files, " ")
+	want := "tar -cf - <f0> " + strings.Join(files, " ") + " > <out.tar>"
+
+	got, err := New("tar", false).NormalizeCommand(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("NormalizeCommand() = %q, want %q", got, want)
+	}
+}
+
+func TestNormalizeCommandNamesWhatRunsInstead(t *testing.T) {
+	_, err := New("tar", false).NormalizeCommand("rm -rf ~; tar -xf a.tar")
+	if err == nil || err.Error() != "command runs 'rm', not 'tar'" {
+		t.Errorf("error = %v, want it to name rm", err)
+	}
+}
@@ -0,0 +1,225 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// StreamExtractor pulls the command and explanation out of an explain-mode
+// response while it streams, for display before the response is complete.
+// It handles both free text (command line, then explanation) and structured
+// JSON responses. The full response should still be parsed with Parse.
+type StreamExtractor struct {
+	parser    *Parser
+	response  strings.Builder
+	command   string // Valid command, once complete
+	explained int    // Bytes of the explanation already returned
+}
+
+// NewStreamExtractor creates an extractor for responses to this parser's command
+func (p *Parser) NewStreamExtractor() *StreamExtractor {
+	return &StreamExtractor{parser: p}
+}
+
+// Write adds the next chunk of the response. It returns the command the first
+// time a complete, valid one is available, and any explanation text that has
+// arrived since the last call (never before the command).
+func (e *StreamExtractor) Write(chunk string) (command, explanation string) {
+	e.response.WriteString(chunk)
+	response := e.response.String()
+
+	var found, text string
+	if strings.HasPrefix(strings.TrimLeft(response, " \t\r\n`json"), "{") {
+		found, text = e.extractStructured(response)
+	} else {
+		found, text = e.extractText(response)
+	}
+
+	if e.command == "" {
+		if found == "" {
+			return "", ""
+		}
+		e.command = found
+		command = found
+	}
+
+	if len(text) > e.explained {
+		explanation = text[e.explained:]
+		e.explained = len(text)
+	}
+	return command, explanation
+}
+
+// Close marks the response complete and returns what Write held back in case
+// more arrived: a last line without a newline, which may be the command or
+// the end of the explanation.
+func (e *StreamExtractor) Close() (command, explanation string) {
+	if e.response.Len() == 0 || strings.HasSuffix(e.response.String(), "\n") {
+		return "", ""
+	}
+	return e.Write("\n")
+}
+
+// extractText finds the first complete line that is a valid command and the
+// explanation after it, leaving out markdown fences and any partial line that
+// may turn out to be one
+func (e *StreamExtractor) extractText(response string) (command, explanation string) {
+	lines := strings.SplitAfter(response, "\n")
+	for i, line := range lines {
+		if !strings.HasSuffix(line, "\n") {
+			return "", "" // The command line is not complete yet
+		}
+		candidate := strings.TrimSpace(stripMarkdownCodeBlocks(strings.TrimSpace(line)))
+		if candidate == "" {
+			continue
+		}
+		normalized, err := e.parser.NormalizeCommand(candidate)
+		if err != nil {
+			continue
+		}
+
+		var b strings.Builder
+		for _, rest := range lines[i+1:] {
+			if strings.HasPrefix(strings.TrimSpace(rest), "`") {
+				if !strings.HasSuffix(rest, "\n") {
+					break // Wait to see whether this is a fence
+				}
+				if strings.HasPrefix(strings.TrimSpace(rest), "```") {
+					continue
+				}
+			}
+			b.WriteString(rest)
+		}
+		return normalized, strings.TrimLeft(b.String(), " \t\r\n")
+	}
+	return "", ""
+}
+
+// extractStructured finds the command once its JSON string is complete and
+// valid, and as much of the explanation string as has arrived
+func (e *StreamExtractor) extractStructured(response string) (command, explanation string) {
+	if value, complete := jsonStringField(response, "command"); complete {
+		if normalized, err := e.parser.NormalizeCommand(value); err == nil {
+			command = normalized
+		}
+	}
+	explanation, _ = jsonStringField(response, "explanation")
+	return command, explanation
+}
+
+// jsonStringField returns the decoded value of a string field in a possibly
+// incomplete JSON object, as far as it has arrived, and whether it is complete
+func jsonStringField(object, field string) (value string, complete bool) {
+	key := strconv.Quote(field)
+	idx := strings.Index(object, key)
+	if idx < 0 {
+		return "", false
+	}
+	rest := strings.TrimLeft(object[idx+len(key):], " \t\r\n")
+	rest, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return "", false
+	}
+	rest, ok = strings.CutPrefix(strings.TrimLeft(rest, " \t\r\n"), `"`)
+	if !ok {
+		return "", false
+	}
+	return decodeJSONStringPrefix(rest)
+}
+
+// decodeJSONStringPrefix decodes the start of a JSON string (after its opening
+// quote), stopping at the closing quote or before an escape sequence that has
+// not fully arrived
+func decodeJSONStringPrefix(s string) (value string, complete bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case '"':
+			return b.String(), true
+
+		case '\\':
+			if i+1 >= len(s) {
+				return b.String(), false
+			}
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'u':
+				r, size, ok := decodeUnicodeEscape(s[i:])
+				if !ok {
+					return b.String(), false
+				}
+				b.WriteRune(r)
+				i += size
+				continue
+			default: // \" \\ \/
+				b.WriteByte(s[i+1])
+			}
+			i += 2
+
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 && !utf8.FullRuneInString(s[i:]) {
+				return b.String(), false // The rest of the character is still streaming
+			}
+			b.WriteRune(r)
+			i += size
+		}
+	}
+	return b.String(), false
+}
+
+// decodeUnicodeEscape decodes a \uXXXX escape, and the escape after it if the
+// two form a surrogate pair. A surrogate without its other half decodes to
+// U+FFFD, as encoding/json does. ok is false if the escape is incomplete.
+func decodeUnicodeEscape(s string) (r rune, size int, ok bool) {
+	if len(s) < 6 {
+		return 0, 0, false
+	}
+	code, err := strconv.ParseUint(s[2:6], 16, 16)
+	if err != nil {
+		return utf8.RuneError, 6, true
+	}
+	r = rune(code)
+	if !utf16.IsSurrogate(r) {
+		return r, 6, true
+	}
+
+	next := s[6:]
+	if len(next) < 6 {
+		if couldBeEscape(next) {
+			return 0, 0, false // The other half may still be streaming
+		}
+		return utf8.RuneError, 6, true
+	}
+	if low, err := strconv.ParseUint(next[2:6], 16, 16); err == nil && next[:2] == `\u` {
+		if pair := utf16.DecodeRune(r, rune(low)); pair != utf8.RuneError {
+			return pair, 12, true
+		}
+	}
+	return utf8.RuneError, 6, true
+}
+
+// couldBeEscape reports whether s is the start of a \uXXXX escape
+func couldBeEscape(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case i == 0 && s[i] == '\\':
+		case i == 1 && s[i] == 'u':
+		case i >= 2 && strings.IndexByte("0123456789abcdefABCDEF", s[i]) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
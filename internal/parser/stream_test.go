@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestJSONStringField(t *testing.T) {
+	tests := []struct {
+		object, field string
+		want          string
+		wantComplete  bool
+	}{
+		{`{"command": "ls -l"}`, "command", "ls -l", true},
+		{`{"command" :"ls -l"`, "command", "ls -l", true},
+		{`{"command": "ls -l`, "command", "ls -l", false},
+		{`{"command": "ls`, "explanation", "", false},
+		{`{"command": `, "command", "", false},
+		{`{"command": 1}`, "command", "", false},
+		{`{"e": "a\"b\\c\/d\n\t"}`, "e", "a\"b\\c/d\n\t", true},
+		{`{"e": "café"}`, "e", "café", true},
+		{`{"e": "smile 😀"}`, "e", "smile 😀", true},
+		{`{"e": "smile \ud83d\ude`, "e", "smile ", false},
+		{`{"e": "smile \ud83d\`, "e", "smile ", false},
+		{`{"e": "lone \ud83d"}`, "e", "lone �", true},
+		{`{"e": "lone \ud83d!"}`, "e", "lone �!", true},
+		{`{"e": "lone \ude00A"}`, "e", "lone �A", true},
+		{`{"e": "unpaired \ud83dA"}`, "e", "unpaired �A", true},
+		{`{"e": "split é`[:len(`{"e": "split é`)-1], "e", "split ", false},
+		{`{"e": "trailing \`, "e", "trailing ", false},
+	}
+	for _, tt := range tests {
+		got, complete := jsonStringField(tt.object, tt.field)
+		if got != tt.want || complete != tt.wantComplete {
+			t.Errorf("jsonStringField(%q, %q) = %q, %v; want %q, %v", tt.object, tt.field, got, complete, tt.want, tt.wantComplete)
+		}
+	}
+}
+
+func TestDecodeJSONStringPrefixAtEverySplit(t *testing.T) {
+	for _, encoded := range []string{
+		`"tabs\tand \"quotes\" \\ café 😀 é😀 \ud83d end"`,
+		`"\ud83d"`,
+		`"\ude00😀"`,
+	} {
+		var want string
+		if err := json.Unmarshal([]byte(encoded), &want); err != nil {
+			t.Fatal(err)
+		}
+		body := encoded[1:]
+		for i := 0; i <= len(body); i++ {
+			got, complete := decodeJSONStringPrefix(body[:i])
+			if complete != (i == len(body)) {
+				t.Errorf("%s split at %d: complete = %v", encoded, i, complete)
+			}
+			if !strings.HasPrefix(want, got) {
+				t.Errorf("%s split at %d: %q is not a prefix of %q", encoded, i, got, want)
+			}
+			if complete && got != want {
+				t.Errorf("%s: decoded %q, want %q", encoded, got, want)
+			}
+		}
+	}
+}
+
+// streamed feeds response to a new extractor in the given chunks and returns
+// the command and the whole explanation it produced
+func streamed(t *testing.T, p *Parser, chunks ...string) (command, explanation string) {
+	t.Helper()
+	e := p.NewStreamExtractor()
+	var b strings.Builder
+	record := func(c, text string) {
+		if c != "" {
+			if command != "" {
+				t.Errorf("command returned twice: %q then %q", command, c)
+			}
+			command = c
+		}
+		if text != "" && command == "" {
+			t.Errorf("explanation %q returned before the command", text)
+		}
+		b.WriteString(text)
+	}
+	for _, chunk := range chunks {
+		record(e.Write(chunk))
+	}
+	record(e.Close())
+	return command, b.String()
+}
+
+// explanationLines drops blank lines and markdown fences, which Parse keeps
+// and the stream does not
+func explanationLines(explanation string) []string {
+	var lines []string
+	for _, line := range strings.Split(explanation, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "```") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestStreamExtractorMatchesParseAtEverySplit(t *testing.T) {
+	responses := []string{
+		"ls -lS\nSorts by size, largest first.\n\nUse -r to reverse.",
+		"Here is the command:\n```bash\nls -lhS\n```\n`-h` prints sizes like 1K.\n`-S` sorts by size.",
+		"```\nls -la\n```",
+		"ls -la",
+		`{"command": "ls -lS", "explanation": "Sorts by size\n\"largest\" first — café 😀", "not_found": false}`,
+		"```json\n{\"explanation\": \"before the command\", \"command\": \"ls -a\"}\n```",
+		`{"command": "ls -lS", "explanation": "lone \ud83d"}`,
+	}
+
+	p := New("ls", true)
+	for _, response := range responses {
+		parsed := p.Parse(response)
+		if !parsed.Valid {
+			t.Fatalf("Parse(%q) invalid: %v", response, parsed.Error)
+		}
+		structured := strings.Contains(response, "{")
+		check := func(split, command, explanation string) {
+			t.Helper()
+			if command != parsed.Command {
+				t.Errorf("%q split %s: command = %q, want %q", response, split, command, parsed.Command)
+			}
+			if structured {
+				if explanation != parsed.Explanation {
+					t.Errorf("%q split %s: explanation = %q, want %q", response, split, explanation, parsed.Explanation)
+				}
+				return
+			}
+			got, want := explanationLines(explanation), explanationLines(parsed.Explanation)
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("%q split %s: explanation = %q, want %q", response, split, got, want)
+			}
+		}
+
+		for i := 0; i <= len(response); i++ {
+			command, explanation := streamed(t, p, response[:i], response[i:])
+			check(fmt.Sprintf("at %d", i), command, explanation)
+		}
+
+		bytes := make([]string, len(response))
+		for i := 0; i < len(response); i++ {
+			bytes[i] = response[i : i+1]
+		}
+		command, explanation := streamed(t, p, bytes...)
+		check("into bytes", command, explanation)
+	}
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// StructuredResponse is the JSON object requested from models that support
+// structured output
+type StructuredResponse struct {
+	Command      string      `json:"command"`
+	Explanation  string      `json:"explanation"`
+	FlagsUsed    []FlagUsage `json:"flags_used"`
+	Placeholders []string    `json:"placeholders"`
+	Confidence   float64     `json:"confidence"`
+	NotFound     bool        `json:"not_found"`
+}
+
+// FlagUsage describes one flag in the suggested command. Flag, Purpose and
+// Citation come from the model; the location fields are filled in when the
+// citation is verified against the man page.
+type FlagUsage struct {
+	Flag      string `json:"flag"`
+	Purpose   string `json:"purpose,omitempty"`
+	Citation  string `json:"citation"` // Man page text documenting the flag
+	Section   string `json:"section,omitempty"`
+	StartLine int    `json:"start_line,omitempty"` // 1-based line range in the cleaned man page
+	EndLine   int    `json:"end_line,omitempty"`
+	Verified  bool   `json:"verified"` // Citation appears verbatim in the man page
+}
+
+// ResponseSchemaName identifies ResponseSchema in structured output requests
+const ResponseSchemaName = "heyman_command"
+
+// responseProperties returns the schema properties describing one command
+func responseProperties() map[string]any {
+	return map[string]any{
+		"command": map[string]any{
+			"type":        "string",
+			"description": "The complete command, starting with the command name. Empty if not_found is true.",
+		},
+		"explanation": map[string]any{
+			"type":        "string",
+			"description": "Brief explanation based only on the man page",
+		},
+		"flags_used": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"flag":     map[string]any{"type": "string"},
+					"purpose":  map[string]any{"type": "string"},
+					"citation": map[string]any{"type": "string", "description": "Exact text quoted from the man page that documents this flag"},
+				},
+				"required":             []string{"flag", "purpose", "citation"},
+				"additionalProperties": false,
+			},
+		},
+		"placeholders": map[string]any{
+			"type":        "array",
+			"items":       map[string]any{"type": "string"},
+			"description": "Placeholders like <PID> the user must fill in",
+		},
+		"confidence": map[string]any{
+			"type":        "number",
+			"description": "Confidence from 0 to 1 that the command answers the question",
+		},
+	}
+}
+
+// ResponseSchema returns the JSON schema for StructuredResponse. It follows the
+// OpenAI strict-mode rules: every property is required and no others are allowed.
+func ResponseSchema() map[string]any {
+	properties := responseProperties()
+	properties["not_found"] = map[string]any{
+		"type":        "boolean",
+		"description": "True if the man page does not contain the information needed",
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             []string{"command", "explanation", "flags_used", "placeholders", "confidence", "not_found"},
+		"additionalProperties": false,
+	}
+}
+
+// parseStructured parses a JSON response. ok is false if the response is not
+// a JSON object, in which case the caller should fall back to text parsing.
+func (p *Parser) parseStructured(response string) (parsed ParsedResponse, ok bool) {
+	body := strings.TrimSpace(stripMarkdownCodeBlocks(response))
+	if !strings.HasPrefix(body, "{") {
+		return ParsedResponse{}, false
+	}
+
+	var structured StructuredResponse
+	if err := json.Unmarshal([]byte(body), &structured); err != nil {
+		return ParsedResponse{}, false
+	}
+
+	if structured.NotFound {
+		return ParsedResponse{
+			Valid: false,
+			Error: fmt.Errorf("information not found in man page"),
+		}, true
+	}
+
+	return p.fromStructured(structured), true
+}
+
+// fromStructured validates the command in a structured response and converts it
+func (p *Parser) fromStructured(structured StructuredResponse) ParsedResponse {
+	command, err := p.NormalizeCommand(structured.Command)
+	if err != nil {
+		return ParsedResponse{
+			Valid: false,
+			Error: err,
+		}
+	}
+
+	parsed := ParsedResponse{
+		Command:      command,
+		FlagsUsed:    structured.FlagsUsed,
+		Placeholders: structured.Placeholders,
+		Confidence:   structured.Confidence,
+		Structured:   true,
+		Valid:        true,
+	}
+	if p.explainMode {
+		parsed.Explanation = strings.TrimSpace(structured.Explanation)
+	}
+
+	return parsed
+}
@@ -0,0 +1,78 @@
+package parser
+
+import "testing"
+
+func TestParseStructured(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		wantOK    bool
+		wantValid bool
+		want      string
+	}{
+		{
+			name:      "JSON object",
+			response:  `{"command": "ls  -lS", "explanation": "Sorts by size", "flags_used": [], "placeholders": [], "confidence": 0.9, "not_found": false}`,
+			wantOK:    true,
+			wantValid: true,
+			want:      "ls -lS",
+		},
+		{
+			name:      "fenced JSON",
+			response:  "```json\n{\"command\": \"ls -a\", \"not_found\": false}\n```",
+			wantOK:    true,
+			wantValid: true,
+			want:      "ls -a",
+		},
+		{name: "not found", response: `{"command": "", "not_found": true}`, wantOK: true},
+		{name: "wrong command", response: `{"command": "rm -rf /", "not_found": false}`, wantOK: true},
+		{name: "free text", response: "ls -lS", wantOK: false},
+		{name: "malformed JSON", response: `{"command": "ls -lS"`, wantOK: false},
+	}
+	p := New("ls", false)
+	for _, tt := range tests {
+		parsed, ok := p.parseStructured(tt.response)
+		if ok != tt.wantOK {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if parsed.Valid != tt.wantValid || parsed.Command != tt.want {
+			t.Errorf("%s: parsed = %+v, want valid = %v, command %q", tt.name, parsed, tt.wantValid, tt.want)
+		}
+		if parsed.Valid && !parsed.Structured {
+			t.Errorf("%s: Structured = false", tt.name)
+		}
+	}
+}
+
+func TestParseFallsBackToText(t *testing.T) {
+	tests := []struct {
+		name     string
+		explain  bool
+		response string
+		want     string
+	}{
+		{name: "plain command", response: "ls -lS", want: "ls -lS"},
+		{name: "malformed JSON, then a command", response: "{\"command\": \"ls -a\"\nls -a", want: "ls -a"},
+		{name: "explain mode", explain: true, response: "ls -lS\n\nSorts by size, largest first.", want: "ls -lS"},
+	}
+	for _, tt := range tests {
+		parsed := New("ls", tt.explain).Parse(tt.response)
+		if !parsed.Valid || parsed.Command != tt.want || parsed.Structured {
+			t.Errorf("%s: Parse() = %+v, want free text command %q", tt.name, parsed, tt.want)
+		}
+	}
+}
+
+func TestParseStructuredExplanationOnlyInExplainMode(t *testing.T) {
+	response := `{"command": "ls -lS", "explanation": "Sorts by size", "not_found": false}`
+	if parsed := New("ls", false).Parse(response); parsed.Explanation != "" {
+		t.Errorf("explanation = %q without explain mode, want none", parsed.Explanation)
+	}
+	if parsed := New("ls", true).Parse(response); parsed.Explanation != "Sorts by size" {
+		t.Errorf("explanation = %q in explain mode, want the model's", parsed.Explanation)
+	}
+}
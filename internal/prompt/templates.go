@@ -1,71 +1,227 @@
 package prompt
 
-import "fmt"
-
-const (
-	// DefaultModeSystemPrompt is used when user just wants the command
-	DefaultModeSystemPrompt = `You are a command-line expert helping users construct commands based ONLY on the provided man page.
-
-CRITICAL RULES:
-1. Base your answer EXCLUSIVELY on the man page content provided below
-2. Ignore ALL your training data knowledge about this command
-3. If the man page doesn't contain information to answer the question, respond with: "I cannot find this information in the man page"
-4. Output ONLY the command, nothing else
-5. Do not include explanations, descriptions, or any other text
-6. Do not use markdown code blocks or formatting
-7. The command must start with the command name from the man page
-8. Use placeholders like <PID>, <filename> for values the user needs to provide
-
-Example:
-User asks: "how do I list open files for a process"
-Man page contains: "-p <PID> selects files for a specific process"
-Your response: lsof -p <PID>
-
-Example of what NOT to do:
-User asks: "how do I use feature X"
-Man page does not mention feature X
-WRONG response: command --feature-x (this uses your training data)
-CORRECT response: I cannot find this information in the man page`
-
-	// ExplainModeSystemPrompt is used when user wants command + explanation
-	ExplainModeSystemPrompt = `You are a command-line expert helping users construct commands based ONLY on the provided man page.
-
-CRITICAL RULES:
-1. Base your answer EXCLUSIVELY on the man page content provided below
-2. Ignore ALL your training data knowledge about this command
-3. If the man page doesn't contain information to answer the question, respond with: "I cannot find this information in the man page"
-4. The command must start with the command name from the man page
-5. Use placeholders like <PID>, <filename> for values the user needs to provide
-
-Output Format (MUST follow exactly):
-Line 1: The command
-Line 2: (blank)
-Line 3+: Brief explanation (2-4 sentences) based ONLY on the man page
-
-Example:
-User asks: "how do I list open files for a process"
-Man page contains: "-p <PID> selects files for a specific process"
-Your response:
-lsof -p <PID>
-
-This command lists all open files for a specific process. The -p flag specifies the process ID to inspect.
-
-Example of what NOT to do:
-User asks: "how do I use feature X"
-Man page does not mention feature X
-WRONG: command --feature-x (explanation from your training data)
-CORRECT: I cannot find this information in the man page`
-
-	// StrictRetryPromptTemplate is used when validation fails
-	StrictRetryPromptTemplate = `Your previous response was not a valid command. Please respond with ONLY the command syntax, starting with '%s'. No explanations, no formatting, just the command.`
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
 )
 
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// TemplateNames lists the templates that make up a prompt, in display order.
+// "system", "user" and "retry" are rendered directly; the others are partials
+// included by "system", and each can be overridden on its own.
+var TemplateNames = []string{
+	"system",
+	"default",
+	"explain",
+	"structured",
+	"alternatives",
+	"structured_alternatives",
+	"cheatsheet",
+	"structured_cheatsheet",
+	"platform",
+	"examples",
+	"user",
+	"retry",
+}
+
+// Data holds the variables available to prompt templates
+type Data struct {
+	Command      string
+	Section      string // Man page section, empty if not specified
+	Platform     string // e.g. "the BSD version of sed on macOS", empty if unknown
+	ManPage      string
+	Question     string
+	Explain      bool // Include an explanation
+	Structured   bool // Respond with a JSON object matching the response schema
+	Alternatives int  // Number of alternative commands requested, 0 for one
+	Cheatsheet   int  // Number of cheat sheet tasks requested, 0 for an answer to the question
+	Examples     []Example
+	Correction   *Correction // Set when re-asking after an answer was rated bad
+}
+
+// Correction describes an earlier answer the user rejected
+type Correction struct {
+	Previous string // The rejected command
+	Reason   string // Why it was wrong, if the user said
+}
+
+// Example is an earlier question and the command the user accepted for it
+type Example struct {
+	Question string
+	Command  string
+}
+
+// Template is the source of one prompt template
+type Template struct {
+	Name   string
+	Text   string
+	Source string // "built-in" or the file it was loaded from
+}
+
+// Templates is a validated set of prompt templates
+type Templates struct {
+	set     *template.Template
+	sources map[string]Template
+}
+
+// DefaultTemplates returns the built-in templates
+func DefaultTemplates() *Templates {
+	templates, err := LoadTemplates("", nil)
+	if err != nil {
+		panic(fmt.Sprintf("built-in prompt templates are invalid: %v", err))
+	}
+	return templates
+}
+
+// LoadTemplates loads the built-in templates, replacing any that have a
+// <name>.tmpl file in dir, then any named in overrides (name -> file path,
+// relative to dir). Every template is parsed and rendered with sample data so
+// mistakes are reported now rather than in the middle of a query.
+func LoadTemplates(dir string, overrides map[string]string) (*Templates, error) {
+	sources := make(map[string]Template, len(TemplateNames))
+	for _, name := range TemplateNames {
+		text, err := builtinTemplates.ReadFile("templates/" + name + ".tmpl")
+		if err != nil {
+			return nil, fmt.Errorf("missing built-in prompt template %q: %w", name, err)
+		}
+		sources[name] = Template{Name: name, Text: string(text), Source: "built-in"}
+
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, name+".tmpl")
+		if text, err := os.ReadFile(path); err == nil {
+			sources[name] = Template{Name: name, Text: string(text), Source: path}
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read prompt template: %w", err)
+		}
+	}
+
+	for name, path := range overrides {
+		if !slices.Contains(TemplateNames, name) {
+			return nil, fmt.Errorf("unknown prompt template %q (expected one of: %s)", name, strings.Join(TemplateNames, ", "))
+		}
+		if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template %q: %w", name, err)
+		}
+		sources[name] = Template{Name: name, Text: string(text), Source: path}
+	}
+
+	set := template.New("").Option("missingkey=error")
+	for _, name := range TemplateNames {
+		if _, err := set.New(name).Parse(sources[name].Text); err != nil {
+			return nil, fmt.Errorf("invalid prompt template %q (%s): %w", name, sources[name].Source, err)
+		}
+	}
+
+	templates := &Templates{set: set, sources: sources}
+	if err := templates.validate(); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// validate renders every entry point with each combination of options
+func (t *Templates) validate() error {
+	for _, explain := range []bool{false, true} {
+		for _, structured := range []bool{false, true} {
+			for _, alternatives := range []int{0, 3} {
+				for _, cheatsheet := range []int{0, 15} {
+					data := Data{
+						Command:      "ls",
+						Section:      "1",
+						Platform:     "the GNU version of ls on Linux",
+						ManPage:      "LS(1)\n\nNAME\n       ls - list directory contents",
+						Question:     "how do I list hidden files",
+						Explain:      explain,
+						Structured:   structured,
+						Alternatives: alternatives,
+						Cheatsheet:   cheatsheet,
+						Examples:     []Example{{Question: "how do I sort by size", Command: "ls -S"}},
+						Correction:   &Correction{Previous: "ls -a", Reason: "shows hidden files only"},
+					}
+					for _, name := range []string{"system", "user", "retry"} {
+						if _, err := t.render(name, data); err != nil {
+							return fmt.Errorf("invalid prompt template %q: %w", name, err)
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// blankLinesRegex matches runs of blank lines, which appear where partials
+// ending in a newline are included
+var blankLinesRegex = regexp.MustCompile(`\n{3,}`)
+
+// render executes a template, trimming surrounding whitespace and extra blank lines
+func (t *Templates) render(name string, data Data) (string, error) {
+	var buf bytes.Buffer
+	if err := t.set.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(blankLinesRegex.ReplaceAllString(buf.String(), "\n\n")), nil
+}
+
+// Sources returns the effective templates in TemplateNames order
+func (t *Templates) Sources() []Template {
+	sources := make([]Template, len(TemplateNames))
+	for i, name := range TemplateNames {
+		sources[i] = t.sources[name]
+	}
+	return sources
+}
+
+// Fingerprint identifies customized templates, so answers to different prompts
+// are cached separately. It is empty when every template is built in.
+func (t *Templates) Fingerprint() string {
+	hash := sha256.New()
+	custom := false
+	for _, source := range t.Sources() {
+		if source.Source != "built-in" {
+			custom = true
+		}
+		fmt.Fprintf(hash, "%s\x00%s\x00", source.Name, source.Text)
+	}
+	if !custom {
+		return ""
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))[:16]
+}
+
+// defaultTemplates is used by builders without a template set
+var defaultTemplates = DefaultTemplates()
+
 // Builder helps construct LLM prompts
 type Builder struct {
-	command    string
-	manPage    string
-	question   string
-	explainMode bool
+	command      string
+	section      string
+	manPage      string
+	question     string
+	explainMode  bool
+	structured   bool
+	alternatives int
+	cheatsheet   int
+	platform     string
+	examples     []Example
+	correction   *Correction
+	templates    *Templates
 }
 
 // NewBuilder creates a new prompt builder
@@ -75,24 +231,146 @@ func NewBuilder(command, manPage, question string, explainMode bool) *Builder {
 		manPage:     manPage,
 		question:    question,
 		explainMode: explainMode,
+		templates:   defaultTemplates,
+	}
+}
+
+// WithTemplates returns a copy of the builder that renders with the given
+// templates. nil selects the built-in templates.
+func (b Builder) WithTemplates(templates *Templates) *Builder {
+	if templates == nil {
+		templates = defaultTemplates
+	}
+	b.templates = templates
+	return &b
+}
+
+// WithSection returns a copy of the builder for a specific man page section
+func (b Builder) WithSection(section string) *Builder {
+	b.section = section
+	return &b
+}
+
+// WithStructuredOutput returns a copy of the builder that asks for a JSON
+// object matching parser.ResponseSchema instead of free text
+func (b Builder) WithStructuredOutput(structured bool) *Builder {
+	b.structured = structured
+	return &b
+}
+
+// WithAlternatives returns a copy of the builder that asks for up to n
+// alternative commands with trade-offs. n <= 1 asks for a single command.
+func (b Builder) WithAlternatives(n int) *Builder {
+	b.alternatives = n
+	return &b
+}
+
+// Alternatives returns the number of alternatives requested, or 0 for a single command
+func (b *Builder) Alternatives() int {
+	if b.alternatives <= 1 {
+		return 0
+	}
+	return b.alternatives
+}
+
+// WithCheatsheet returns a copy of the builder that asks for a cheat sheet of
+// the n most useful tasks instead of an answer to the question. n <= 0 asks
+// for an answer.
+func (b Builder) WithCheatsheet(n int) *Builder {
+	b.cheatsheet = max(n, 0)
+	return &b
+}
+
+// Cheatsheet returns the number of cheat sheet tasks requested, or 0 for an answer
+func (b *Builder) Cheatsheet() int {
+	return b.cheatsheet
+}
+
+// WithPlatform returns a copy of the builder that tells the model which
+// platform and implementation the command runs on, e.g. "the BSD version of
+// sed on macOS"
+func (b Builder) WithPlatform(platform string) *Builder {
+	b.platform = platform
+	return &b
+}
+
+// WithExamples returns a copy of the builder that includes previously
+// accepted answers as few-shot examples
+func (b Builder) WithExamples(examples []Example) *Builder {
+	b.examples = examples
+	return &b
+}
+
+// Examples returns the few-shot examples the prompt includes
+func (b *Builder) Examples() []Example {
+	return b.examples
+}
+
+// WithCorrection returns a copy of the builder that tells the model its
+// previous answer was wrong
+func (b Builder) WithCorrection(correction *Correction) *Builder {
+	b.correction = correction
+	return &b
+}
+
+// Structured reports whether the builder asks for structured output
+func (b *Builder) Structured() bool {
+	return b.structured
+}
+
+// Section returns the man page section the prompt asks about, if any
+func (b *Builder) Section() string {
+	return b.section
+}
+
+// ManPage returns the man page content the prompt is built from
+func (b *Builder) ManPage() string {
+	return b.manPage
+}
+
+// Templates returns the templates the builder renders with
+func (b *Builder) Templates() *Templates {
+	return b.templates
+}
+
+// data returns the template variables for the builder
+func (b *Builder) data() Data {
+	return Data{
+		Command:      b.command,
+		Section:      b.section,
+		Platform:     b.platform,
+		ManPage:      b.manPage,
+		Question:     b.question,
+		Explain:      b.explainMode,
+		Structured:   b.structured,
+		Alternatives: b.Alternatives(),
+		Cheatsheet:   b.cheatsheet,
+		Examples:     b.examples,
+		Correction:   b.correction,
 	}
 }
 
+// render renders a template, falling back to the built-in templates if a
+// custom one fails (validation at load time makes that unlikely)
+func (b *Builder) render(name string) string {
+	text, err := b.templates.render(name, b.data())
+	if err != nil {
+		text, _ = defaultTemplates.render(name, b.data())
+	}
+	return text
+}
+
 // SystemPrompt returns the appropriate system prompt
 func (b *Builder) SystemPrompt() string {
-	if b.explainMode {
-		return ExplainModeSystemPrompt
-	}
-	return DefaultModeSystemPrompt
+	return b.render("system")
 }
 
 // UserPrompt returns the user prompt with man page and question
 func (b *Builder) UserPrompt() string {
-	return fmt.Sprintf("Man page for '%s':\n\n%s\n\nUser question: %s\n\nProvide the command:",
-		b.command, b.manPage, b.question)
+	return b.render("user")
 }
 
 // StrictRetryPrompt returns a stricter prompt for retry attempts
 func (b *Builder) StrictRetryPrompt() string {
-	return fmt.Sprintf(StrictRetryPromptTemplate, b.command)
+	return b.render("retry")
 }
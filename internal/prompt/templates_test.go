@@ -0,0 +1,76 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, dir, name, text string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(text), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadTemplatesOverrides(t *testing.T) {
+	dir := t.TempDir()
+	dirDefault := writeTemplate(t, dir, "default.tmpl", "Answer with one {{.Command}} command.")
+	profileUser := writeTemplate(t, dir, "small.tmpl", "{{.ManPage}}\nQ: {{.Question}}")
+
+	templates, err := LoadTemplates(dir, map[string]string{"user": "small.tmpl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sources := make(map[string]string)
+	for _, source := range templates.Sources() {
+		sources[source.Name] = source.Source
+	}
+	if sources["default"] != dirDefault || sources["user"] != profileUser || sources["system"] != "built-in" {
+		t.Errorf("sources = %v, want default from the directory, user from the profile and the rest built in", sources)
+	}
+
+	b := NewBuilder("ls", "LS(1)", "list files", false).WithTemplates(templates)
+	if !strings.Contains(b.SystemPrompt(), "Answer with one ls command.") {
+		t.Errorf("system prompt doesn't include the overridden default:\n%s", b.SystemPrompt())
+	}
+	if got := b.UserPrompt(); got != "LS(1)\nQ: list files" {
+		t.Errorf("user prompt = %q", got)
+	}
+
+	if templates.Fingerprint() == "" {
+		t.Error("customized templates have no fingerprint")
+	}
+	if DefaultTemplates().Fingerprint() != "" {
+		t.Error("built-in templates have a fingerprint")
+	}
+}
+
+func TestLoadTemplatesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string // Contents of the override, if any
+		overrides map[string]string
+		wantErr   string
+	}{
+		{name: "unknown template", overrides: map[string]string{"sytem": "x.tmpl"}, wantErr: `unknown prompt template "sytem"`},
+		{name: "missing file", overrides: map[string]string{"user": "missing.tmpl"}, wantErr: `failed to read prompt template "user"`},
+		{name: "parse error", file: "{{.Question", overrides: map[string]string{"user": "bad.tmpl"}, wantErr: `invalid prompt template "user"`},
+		{name: "unknown field", file: "{{.Questoin}}", overrides: map[string]string{"user": "bad.tmpl"}, wantErr: `invalid prompt template "user"`},
+		{name: "missing partial", file: `{{template "nope" .}}`, overrides: map[string]string{"system": "bad.tmpl"}, wantErr: `invalid prompt template "system"`},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if tt.file != "" {
+			writeTemplate(t, dir, "bad.tmpl", tt.file)
+		}
+		_, err := LoadTemplates(dir, tt.overrides)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: LoadTemplates() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
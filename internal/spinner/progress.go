@@ -0,0 +1,73 @@
+package spinner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// progressWidth is the number of cells in a progress bar
+const progressWidth = 30
+
+// Progress displays a progress bar for a download. On a terminal it redraws
+// one line; otherwise it prints each new step on its own line.
+type Progress struct {
+	writer io.Writer
+	tty    bool
+	status string // Last step shown
+}
+
+// NewProgress creates a progress bar writing to stderr
+func NewProgress() *Progress {
+	return &Progress{
+		writer: os.Stderr,
+		tty:    term.IsTerminal(int(os.Stderr.Fd())),
+	}
+}
+
+// Update shows the current step and, if total is known, how much of it is done
+func (p *Progress) Update(status string, completed, total int64) {
+	if !p.tty {
+		if status != p.status {
+			fmt.Fprintln(p.writer, status)
+		}
+		p.status = status
+		return
+	}
+	p.status = status
+
+	if total <= 0 {
+		fmt.Fprintf(p.writer, "\r\033[K%s", status)
+		return
+	}
+
+	completed = min(completed, total)
+	filled := int(completed * progressWidth / total)
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressWidth-filled)
+	fmt.Fprintf(p.writer, "\r\033[K%s %s %3d%% %s/%s", status, bar, completed*100/total, formatBytes(completed), formatBytes(total))
+}
+
+// Done clears the bar and prints a final message
+func (p *Progress) Done(message string) {
+	if p.tty {
+		fmt.Fprint(p.writer, "\r\033[K")
+	}
+	fmt.Fprintln(p.writer, message)
+}
+
+// formatBytes formats a byte count with a binary unit ("1.5 GB")
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
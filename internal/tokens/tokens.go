@@ -0,0 +1,221 @@
+// Package tokens counts the tokens a model sees in a prompt, with the model's
+// own tokenizer where heyman has it and a calibrated estimate otherwise.
+package tokens
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	tiktoken "github.com/pkoukk/tiktoken-go"
+)
+
+// Tokenizer counts tokens for one model
+type Tokenizer interface {
+	// Count returns the number of tokens in text
+	Count(ctx context.Context, text string) int
+
+	// Exact reports whether counts come from the model's own tokenizer
+	// rather than an estimate
+	Exact() bool
+
+	// Name describes how tokens are counted, for verbose and dry-run output
+	Name() string
+}
+
+// For returns the tokenizer for a provider's model. Ollama models can count
+// exactly with Ollama's tokenizer; see Ollama.
+func For(provider, model string) Tokenizer {
+	if provider == "openai" {
+		return Encoding(openAIEncoding(model))
+	}
+	return Estimate(model)
+}
+
+// openAIEncoding returns the tiktoken encoding an OpenAI model uses:
+// o200k_base from gpt-4o on, cl100k_base for gpt-4, gpt-4-turbo and gpt-3.5
+func openAIEncoding(model string) string {
+	for _, prefix := range []string{"gpt-3.5", "gpt-4-", "text-embedding"} {
+		if strings.HasPrefix(model, prefix) {
+			return tiktoken.MODEL_CL100K_BASE
+		}
+	}
+	if model == "gpt-4" {
+		return tiktoken.MODEL_CL100K_BASE
+	}
+	return tiktoken.MODEL_O200K_BASE
+}
+
+// encoding counts tokens with a tiktoken encoding
+type encoding struct {
+	name string
+}
+
+// Encoding returns a tokenizer for a tiktoken encoding ("o200k_base",
+// "cl100k_base"). If the encoding can't be loaded, counts fall back to four
+// characters per token.
+func Encoding(name string) Tokenizer {
+	return encoding{name: name}
+}
+
+// Count returns the number of tokens in text
+func (e encoding) Count(ctx context.Context, text string) int {
+	tke, err := loadEncoding(e.name)
+	if err != nil {
+		return characterEstimate(text)
+	}
+	return len(tke.Encode(text, nil, nil))
+}
+
+// Exact reports whether the encoding could be loaded
+func (e encoding) Exact() bool {
+	_, err := loadEncoding(e.name)
+	return err == nil
+}
+
+// Name returns the encoding name
+func (e encoding) Name() string {
+	if !e.Exact() {
+		return "character estimate (" + e.name + " unavailable)"
+	}
+	return e.name
+}
+
+// loadedEncoding is a tiktoken encoding loaded (or failed to load) once per process
+type loadedEncoding struct {
+	once sync.Once
+	tke  *tiktoken.Tiktoken
+	err  error
+}
+
+var encodings sync.Map // Encoding name -> *loadedEncoding
+
+// loadEncoding loads a tiktoken encoding, which is slow (its ranks may be
+// downloaded), once per process
+func loadEncoding(name string) (*tiktoken.Tiktoken, error) {
+	value, _ := encodings.LoadOrStore(name, &loadedEncoding{})
+	loaded := value.(*loadedEncoding)
+	loaded.once.Do(func() {
+		loaded.tke, loaded.err = tiktoken.GetEncoding(name)
+	})
+	return loaded.tke, loaded.err
+}
+
+// characterEstimate estimates tokens from length when no tokenizer is available
+func characterEstimate(text string) int {
+	return len(text) / 4
+}
+
+// estimate approximates a model's tokenizer by scaling cl100k_base counts
+type estimate struct {
+	family string
+	ratio  float64 // Model tokens per cl100k_base token
+}
+
+// estimateRatios are approximate model tokens per cl100k_base token for model
+// families, from the size of their vocabularies. Small-vocabulary tokenizers (Llama 2, Mistral, Phi-3)
+// split text into more pieces; large-vocabulary ones come close to cl100k.
+// Checked in order, so longer prefixes come first.
+var estimateRatios = []struct {
+	prefix string
+	ratio  float64
+}{
+	{"llama2", 1.20},
+	{"codellama", 1.20},
+	{"llama3", 1.00},
+	{"llama", 1.00},
+	{"mistral-nemo", 1.05},
+	{"mistral", 1.20},
+	{"mixtral", 1.20},
+	{"phi4", 1.00},
+	{"phi", 1.20},
+	{"gemma", 1.05},
+	{"qwen", 1.00},
+	{"deepseek", 1.05},
+	{"claude", 1.15},
+}
+
+// defaultRatio is used for unknown models; overestimating slightly makes the
+// context window check err on the side of warning
+const defaultRatio = 1.10
+
+// Estimate returns a calibrated estimate for a model without an available tokenizer
+func Estimate(model string) Tokenizer {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:] // Registry namespaces, e.g. "hf.co/user/model"
+	}
+	for _, family := range estimateRatios {
+		if strings.HasPrefix(name, family.prefix) {
+			return estimate{family: family.prefix, ratio: family.ratio}
+		}
+	}
+	return estimate{ratio: defaultRatio}
+}
+
+// Count returns the estimated number of tokens in text
+func (e estimate) Count(ctx context.Context, text string) int {
+	return int(math.Ceil(float64(Encoding(tiktoken.MODEL_CL100K_BASE).Count(ctx, text)) * e.ratio))
+}
+
+// Exact is always false for estimates
+func (e estimate) Exact() bool {
+	return false
+}
+
+// Name describes the estimate
+func (e estimate) Name() string {
+	family := e.family
+	if family == "" {
+		family = "unknown models"
+	}
+	base := tiktoken.MODEL_CL100K_BASE
+	if !Encoding(base).Exact() {
+		base = "characters / 4"
+	}
+	return fmt.Sprintf("estimate (%s × %.2f for %s)", base, e.ratio, family)
+}
+
+// ollama counts tokens with Ollama's tokenizer for the model
+type ollama struct {
+	tokenize    func(ctx context.Context, text string) ([]int, error)
+	fallback    Tokenizer
+	succeeded   atomic.Bool // Set once the server has tokenized text
+	unavailable atomic.Bool // Set once tokenizing fails, e.g. on servers without the endpoint
+}
+
+// Ollama returns a tokenizer that asks the Ollama server to tokenize text,
+// using fallback if it can't (servers without a tokenize endpoint)
+func Ollama(tokenize func(ctx context.Context, text string) ([]int, error), fallback Tokenizer) Tokenizer {
+	return &ollama{tokenize: tokenize, fallback: fallback}
+}
+
+// Count returns the number of tokens in text
+func (o *ollama) Count(ctx context.Context, text string) int {
+	if !o.unavailable.Load() {
+		tokens, err := o.tokenize(ctx, text)
+		if err == nil {
+			o.succeeded.Store(true)
+			return len(tokens)
+		}
+		o.unavailable.Store(true)
+	}
+	return o.fallback.Count(ctx, text)
+}
+
+// Exact reports whether Ollama has been tokenizing successfully; until it
+// has, counts may come from the fallback
+func (o *ollama) Exact() bool {
+	return o.succeeded.Load() && !o.unavailable.Load()
+}
+
+// Name describes the tokenizer in use
+func (o *ollama) Name() string {
+	if !o.Exact() {
+		return o.fallback.Name()
+	}
+	return "Ollama tokenizer"
+}
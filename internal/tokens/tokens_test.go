@@ -0,0 +1,58 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOllamaExactOnlyAfterSuccess(t *testing.T) {
+	ctx := context.Background()
+	fallback := Estimate("llama3.2")
+
+	working := Ollama(func(ctx context.Context, text string) ([]int, error) {
+		return []int{1, 2, 3}, nil
+	}, fallback)
+	if working.Exact() {
+		t.Error("Exact() before any tokenize call = true, want false")
+	}
+	if got := working.Count(ctx, "some text"); got != 3 {
+		t.Errorf("Count() = %d, want 3 from the server", got)
+	}
+	if !working.Exact() || working.Name() != "Ollama tokenizer" {
+		t.Errorf("after a successful call Exact() = %v, Name() = %q", working.Exact(), working.Name())
+	}
+
+	calls := 0
+	missing := Ollama(func(ctx context.Context, text string) ([]int, error) {
+		calls++
+		return nil, errors.New("404 Not Found")
+	}, fallback)
+	text := "some text to count"
+	if got, want := missing.Count(ctx, text), fallback.Count(ctx, text); got != want {
+		t.Errorf("Count() without the endpoint = %d, want fallback %d", got, want)
+	}
+	missing.Count(ctx, text)
+	if calls != 1 {
+		t.Errorf("tokenize called %d times, want 1 before giving up", calls)
+	}
+	if missing.Exact() || missing.Name() != fallback.Name() {
+		t.Errorf("without the endpoint Exact() = %v, Name() = %q", missing.Exact(), missing.Name())
+	}
+}
+
+func TestOpenAIEncoding(t *testing.T) {
+	tests := map[string]string{
+		"gpt-4o":        "o200k_base",
+		"gpt-4o-mini":   "o200k_base",
+		"gpt-4.1":       "o200k_base",
+		"gpt-4":         "cl100k_base",
+		"gpt-4-turbo":   "cl100k_base",
+		"gpt-3.5-turbo": "cl100k_base",
+	}
+	for model, want := range tests {
+		if got := openAIEncoding(model); got != want {
+			t.Errorf("openAIEncoding(%q) = %q, want %q", model, got, want)
+		}
+	}
+}